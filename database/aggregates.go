@@ -0,0 +1,446 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// aggregateFunction — агрегатная функция: init создаёт начальное состояние,
+// step добавляет к нему очередное значение (NULL пропускаются), final
+// возвращает результат по накопленному состоянию. argTypes — допустимые типы
+// аргумента (nil — любой тип).
+type aggregateFunction struct {
+	init       func() interface{}
+	step       func(state, value interface{}) (interface{}, error)
+	final      func(state interface{}) (interface{}, error)
+	resultType func(argType DataType) DataType
+	argTypes   []DataType
+}
+
+var aggregateFunctions = map[string]*aggregateFunction{
+	"count": {
+		init:       func() interface{} { return 0 },
+		step:       func(state, value interface{}) (interface{}, error) { return state.(int) + 1, nil },
+		final:      finalIdentity,
+		resultType: func(DataType) DataType { return INTEGER },
+	},
+	"sum": {
+		init:       initNull,
+		step:       stepSum,
+		final:      finalIdentity,
+		resultType: func(argType DataType) DataType { return argType },
+	},
+	"avg": {
+		init:       func() interface{} { return &avgState{} },
+		step:       stepAvg,
+		final:      finalAvg,
+		resultType: avgType,
+	},
+	"min": {
+		init:       initNull,
+		step:       func(state, value interface{}) (interface{}, error) { return pickValue(state, value, -1), nil },
+		final:      finalIdentity,
+		resultType: func(argType DataType) DataType { return argType },
+	},
+	"max": {
+		init:       initNull,
+		step:       func(state, value interface{}) (interface{}, error) { return pickValue(state, value, 1), nil },
+		final:      finalIdentity,
+		resultType: func(argType DataType) DataType { return argType },
+	},
+}
+
+func initNull() interface{} { return nil }
+
+func finalIdentity(state interface{}) (interface{}, error) { return state, nil }
+
+func pickValue(current, value interface{}, direction int) interface{} {
+	if current == nil || compareValues(value, current)*direction > 0 {
+		return value
+	}
+	return current
+}
+
+func stepSum(state, value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case int, float64, Decimal, Interval:
+	default:
+		return nil, fmt.Errorf("функция 'sum' не применима к типу %s", typeNameOf(value))
+	}
+	if state == nil {
+		return value, nil
+	}
+	return applyArithmetic("+", state, value)
+}
+
+type avgState struct {
+	sum   interface{}
+	count int
+}
+
+func stepAvg(state, value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case int, float64, Decimal:
+	default:
+		return nil, fmt.Errorf("функция 'avg' не применима к типу %s", typeNameOf(value))
+	}
+	s := state.(*avgState)
+	sum, err := stepSum(s.sum, value)
+	if err != nil {
+		return nil, err
+	}
+	return &avgState{sum: sum, count: s.count + 1}, nil
+}
+
+// finalAvg делит сумму на количество. Среднее целых и DECIMAL вычисляется точно
+// с avgExtraScale дополнительными знаками, незначащие нули отбрасываются.
+func finalAvg(state interface{}) (interface{}, error) {
+	s := state.(*avgState)
+	switch sum := s.sum.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return sum / float64(s.count), nil
+	case int:
+		return decimalFromInt(sum).divInt(s.count, avgExtraScale).trim(0), nil
+	case Decimal:
+		return sum.divInt(s.count, sum.scale+avgExtraScale).trim(sum.scale), nil
+	}
+	return nil, fmt.Errorf("функция 'avg' не применима к типу %s", typeNameOf(s.sum))
+}
+
+func avgType(argType DataType) DataType {
+	if argType == FLOAT {
+		return FLOAT
+	}
+	return DECIMAL
+}
+
+func isAggregateCall(expr *Expr) bool {
+	if expr.Type != FunctionExpr {
+		return false
+	}
+	_, ok := expr.Value.(*aggregateFunction)
+	return ok
+}
+
+func containsAggregate(expr *Expr) bool {
+	if isAggregateCall(expr) {
+		return true
+	}
+	switch expr.Type {
+	case CaseExpr:
+		for _, cond := range expr.Value.([]*Condition) {
+			if conditionContainsAggregate(cond) {
+				return true
+			}
+		}
+	case ConditionExpr:
+		if conditionContainsAggregate(expr.Value.(*Condition)) {
+			return true
+		}
+	}
+	for _, arg := range expr.Args {
+		if containsAggregate(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+func conditionContainsAggregate(cond *Condition) bool {
+	if cond == nil {
+		return false
+	}
+	if cond.Type == Compound {
+		return conditionContainsAggregate(cond.Left) || conditionContainsAggregate(cond.Right)
+	}
+	return (cond.Expr != nil && containsAggregate(cond.Expr)) || (cond.ValueExpr != nil && containsAggregate(cond.ValueExpr))
+}
+
+// selectItem — элемент списка выборки: имя столбца результата (псевдоним или
+// текст выражения) и выражение.
+type selectItem struct {
+	name    string
+	expr    *Expr
+	aliased bool
+}
+
+// parseSelectItems разбирает список выборки в выражения с необязательными
+// псевдонимами: price * qty AS total, upper(name) title.
+func parseSelectItems(db *Database, selectColumns []string) ([]selectItem, error) {
+	var items []selectItem
+	for _, text := range selectColumns {
+		tokens := tokenize(text)
+		expr, next, err := parseValueExpression(db, tokens, 0, len(tokens))
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис списка выборки: %v", err)
+		}
+		item := selectItem{name: exprString(expr), expr: expr}
+		if next < len(tokens) && strings.ToUpper(tokens[next]) == "AS" {
+			next++
+		}
+		if next == len(tokens)-1 && isAlias(tokens[next]) {
+			item.name, item.aliased = strings.Trim(tokens[next], `"`), true
+			next++
+		}
+		if next != len(tokens) {
+			return nil, fmt.Errorf("неверный синтаксис списка выборки: неожиданный токен '%s'", tokens[next])
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// isAlias проверяет, что токен может быть псевдонимом: имя или имя в двойных кавычках.
+func isAlias(token string) bool {
+	if len(token) >= 2 && strings.HasPrefix(token, `"`) && strings.HasSuffix(token, `"`) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(token)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// resolveAliases заменяет в ORDER BY псевдонимы элементов выборки их выражениями.
+func resolveAliases(orderBy []OrderBy, items []selectItem) []OrderBy {
+	resolved := make([]OrderBy, len(orderBy))
+	for i, item := range orderBy {
+		resolved[i] = item
+		resolved[i].Expr = resolveAlias(item.Expr, items)
+	}
+	return resolved
+}
+
+// resolveAlias возвращает выражение элемента выборки, если expr — его псевдоним.
+func resolveAlias(expr *Expr, items []selectItem) *Expr {
+	if expr.Type != ColumnExpr {
+		return expr
+	}
+	for _, selected := range items {
+		if selected.aliased && strings.EqualFold(selected.name, expr.Name) {
+			return selected.expr
+		}
+	}
+	return expr
+}
+
+// aggregateRows группирует строки по выражениям groupBy и вычисляет для каждой
+// группы элементы списка выборки. Без GROUP BY все строки образуют одну группу.
+func aggregateRows(rows [][]interface{}, columnNames []string, columnTypes []DataType, items []selectItem, groupBy []*Expr) (*ResultSet, error) {
+	for _, expr := range groupBy {
+		if containsAggregate(expr) {
+			return nil, errors.New("агрегатные функции недопустимы в GROUP BY")
+		}
+	}
+	var groups [][][]interface{}
+	if len(groupBy) == 0 {
+		groups = append(groups, rows)
+	} else {
+		positions := make(map[string]int)
+		for _, row := range rows {
+			key := make([]interface{}, len(groupBy))
+			for i, expr := range groupBy {
+				value, err := evaluateExpression(row, columnNames, expr)
+				if err != nil {
+					return nil, err
+				}
+				key[i] = value
+			}
+			encoded := encodeKey(key)
+			pos, exists := positions[encoded]
+			if !exists {
+				pos = len(groups)
+				positions[encoded] = pos
+				groups = append(groups, nil)
+			}
+			groups[pos] = append(groups[pos], row)
+		}
+	}
+
+	var groupKeys []string
+	for _, expr := range groupBy {
+		groupKeys = append(groupKeys, strings.ToLower(exprString(expr)))
+		if expr.Type == ColumnExpr {
+			if index := findColumnIndex(columnNames, expr.Name); index != -1 {
+				groupKeys = append(groupKeys, strings.ToLower(columnNames[index]))
+			}
+		}
+	}
+
+	result := &ResultSet{}
+	for _, item := range items {
+		result.Columns = append(result.Columns, ResultColumn{Name: item.name})
+	}
+	for _, group := range groups {
+		row := make([]interface{}, len(items))
+		for i, item := range items {
+			value, err := evaluateGroupExpression(item.expr, group, columnNames, groupKeys)
+			if err != nil {
+				return nil, err
+			}
+			row[i] = value
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	for i, item := range items {
+		dataType, ok := expressionType(item.expr, columnNames, columnTypes)
+		if !ok {
+			dataType = inferColumnType(result.Rows, i)
+		}
+		result.Columns[i].Type = dataType
+	}
+	return result, nil
+}
+
+// evaluateGroupExpression вычисляет выражение для группы строк: агрегатные функции
+// считаются по всей группе, остальные столбцы должны входить в GROUP BY.
+func evaluateGroupExpression(expr *Expr, group [][]interface{}, columnNames []string, groupKeys []string) (interface{}, error) {
+	if isGroupKey(expr, columnNames, groupKeys) {
+		if len(group) == 0 {
+			return nil, nil
+		}
+		return evaluateExpression(group[0], columnNames, expr)
+	}
+	switch expr.Type {
+	case ColumnExpr:
+		return nil, fmt.Errorf("столбец '%s' должен входить в GROUP BY или использоваться в агрегатной функции", expr.Name)
+	case FunctionExpr:
+		if fn, ok := expr.Value.(*aggregateFunction); ok {
+			return computeAggregate(expr, fn, group, columnNames)
+		}
+	}
+	args := make([]*Expr, len(expr.Args))
+	for i, arg := range expr.Args {
+		value, err := evaluateGroupExpression(arg, group, columnNames, groupKeys)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = &Expr{Type: LiteralExpr, Value: value}
+	}
+	// Условия CASE и логических выражений тоже вычисляются по группе.
+	exprValue := expr.Value
+	switch expr.Type {
+	case CaseExpr:
+		conditions := expr.Value.([]*Condition)
+		grouped := make([]*Condition, len(conditions))
+		for i, cond := range conditions {
+			var err error
+			if grouped[i], err = groupCondition(cond, group, columnNames, groupKeys); err != nil {
+				return nil, err
+			}
+		}
+		exprValue = grouped
+	case ConditionExpr:
+		grouped, err := groupCondition(expr.Value.(*Condition), group, columnNames, groupKeys)
+		if err != nil {
+			return nil, err
+		}
+		exprValue = grouped
+	}
+	return evaluateExpression(nil, nil, &Expr{Type: expr.Type, Value: exprValue, Name: expr.Name, Args: args})
+}
+
+// groupCondition заменяет операнды условия их значениями для группы строк.
+func groupCondition(cond *Condition, group [][]interface{}, columnNames []string, groupKeys []string) (*Condition, error) {
+	if cond == nil {
+		return nil, nil
+	}
+	if cond.Type == Compound {
+		left, err := groupCondition(cond.Left, group, columnNames, groupKeys)
+		if err != nil {
+			return nil, err
+		}
+		right, err := groupCondition(cond.Right, group, columnNames, groupKeys)
+		if err != nil {
+			return nil, err
+		}
+		return &Condition{Type: Compound, LogicalOp: cond.LogicalOp, Left: left, Right: right}, nil
+	}
+	operand := cond.Expr
+	if operand == nil {
+		operand = &Expr{Type: ColumnExpr, Name: cond.Column}
+	}
+	value, err := evaluateGroupExpression(operand, group, columnNames, groupKeys)
+	if err != nil {
+		return nil, err
+	}
+	grouped := *cond
+	grouped.Expr = &Expr{Type: LiteralExpr, Value: value}
+	if cond.ValueExpr != nil {
+		value, err := evaluateGroupExpression(cond.ValueExpr, group, columnNames, groupKeys)
+		if err != nil {
+			return nil, err
+		}
+		grouped.ValueExpr = &Expr{Type: LiteralExpr, Value: value}
+	}
+	return &grouped, nil
+}
+
+func isGroupKey(expr *Expr, columnNames []string, groupKeys []string) bool {
+	if expr.Type == LiteralExpr {
+		return false
+	}
+	name := strings.ToLower(exprString(expr))
+	if expr.Type == ColumnExpr {
+		if index := findColumnIndex(columnNames, expr.Name); index != -1 {
+			name = strings.ToLower(columnNames[index])
+		}
+	}
+	for _, key := range groupKeys {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+func computeAggregate(expr *Expr, fn *aggregateFunction, group [][]interface{}, columnNames []string) (interface{}, error) {
+	if len(expr.Args) != 1 {
+		return nil, fmt.Errorf("агрегатная функция '%s' ожидает 1 аргумент, получено %d", expr.Name, len(expr.Args))
+	}
+	arg := expr.Args[0]
+	if containsAggregate(arg) {
+		return nil, errors.New("агрегатные функции не могут быть вложенными")
+	}
+	countRows := expr.Name == "count" && arg.Type == ColumnExpr && arg.Name == "*"
+	state := fn.init()
+	for _, row := range group {
+		var value interface{} = true
+		if !countRows {
+			var err error
+			value, err = evaluateExpression(row, columnNames, arg)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if value == nil {
+			continue
+		}
+		var err error
+		state, err = fn.step(state, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fn.final(state)
+}
+
+// groupedOrderBy переводит выражения ORDER BY запроса с агрегатами в ссылки
+// на столбцы результата: ORDER BY sum(amount) сортирует по столбцу sum(amount).
+func groupedOrderBy(orderBy []OrderBy, result *ResultSet) []OrderBy {
+	rewritten := make([]OrderBy, len(orderBy))
+	for i, item := range orderBy {
+		rewritten[i] = item
+		name := strings.ToLower(exprString(item.Expr))
+		for _, col := range result.Columns {
+			if strings.ToLower(col.Name) == name {
+				rewritten[i].Expr = &Expr{Type: ColumnExpr, Name: col.Name}
+				break
+			}
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bytes — значение BYTEA. Байты хранятся в строке, чтобы значения можно было
+// сравнивать как ключи; на диск сохраняются в base64, на экран выводятся
+// в шестнадцатеричном виде \x0102.
+type Bytes string
+
+func (b Bytes) String() string {
+	return `\x` + hex.EncodeToString([]byte(b))
+}
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + base64.StdEncoding.EncodeToString([]byte(b)) + `"`), nil
+}
+
+// parseBytea разбирает строку для BYTEA: '\xDEADBEEF' — шестнадцатеричная запись,
+// любая другая строка берётся как есть.
+func parseBytea(value string) (Bytes, error) {
+	if strings.HasPrefix(value, `\x`) || strings.HasPrefix(value, `\X`) {
+		return decodeHex(value[2:])
+	}
+	return Bytes(value), nil
+}
+
+func decodeHex(text string) (Bytes, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return "", fmt.Errorf("некорректная шестнадцатеричная запись '%s'", text)
+	}
+	return Bytes(data), nil
+}
+
+func decodeBase64(text string) (Bytes, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+	if err != nil {
+		return "", fmt.Errorf("некорректная запись base64 '%s'", text)
+	}
+	return Bytes(data), nil
+}
+
+// binaryLiteral разбирает литералы X'DEADBEEF' (hex) и B64'3q2+7w==' (base64).
+func binaryLiteral(token string) (Bytes, bool, error) {
+	upper := strings.ToUpper(token)
+	switch {
+	case strings.HasPrefix(upper, "X'") && isQuoted(token[1:]):
+		data, err := decodeHex(token[2 : len(token)-1])
+		return data, true, err
+	case strings.HasPrefix(upper, "B64'") && isQuoted(token[3:]):
+		data, err := decodeBase64(token[4 : len(token)-1])
+		return data, true, err
+	}
+	return "", false, nil
+}
+
+// UUIDValue — значение UUID. На диск сохраняется как 16 байт в base64, на экран
+// выводится в канонической записи 8-4-4-4-12.
+type UUIDValue [16]byte
+
+func (u UUIDValue) String() string {
+	text := hex.EncodeToString(u[:])
+	return text[:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:]
+}
+
+func (u UUIDValue) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + base64.StdEncoding.EncodeToString(u[:]) + `"`), nil
+}
+
+// parseUUID принимает каноническую запись, а также запись без дефисов и в фигурных скобках.
+func parseUUID(value string) (UUIDValue, error) {
+	var u UUIDValue
+	text := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "{"), "}")
+	text = strings.ReplaceAll(text, "-", "")
+	if len(text) != 32 {
+		return u, fmt.Errorf("некорректное значение UUID '%s'", value)
+	}
+	if _, err := hex.Decode(u[:], []byte(text)); err != nil {
+		return u, fmt.Errorf("некорректное значение UUID '%s'", value)
+	}
+	return u, nil
+}
+
+// loadUUID читает UUID из файла таблицы: 16 байт в base64 или каноническую запись.
+func loadUUID(value string) (UUIDValue, error) {
+	var u UUIDValue
+	if data, err := base64.StdEncoding.DecodeString(value); err == nil && len(data) == len(u) {
+		copy(u[:], data)
+		return u, nil
+	}
+	return parseUUID(value)
+}
+
+func newRandomUUID() (UUIDValue, error) {
+	var u UUIDValue
+	if _, err := rand.Read(u[:]); err != nil {
+		return u, fmt.Errorf("ошибка генерации UUID: %v", err)
+	}
+	u[6] = u[6]&0x0f | 0x40 // версия 4
+	u[8] = u[8]&0x3f | 0x80 // вариант RFC 4122
+	return u, nil
+}
+
+// compareBinary сравнивает значения BYTEA или UUID.
+func compareBinary(a, b interface{}) (int, error) {
+	switch av := a.(type) {
+	case Bytes:
+		if bv, ok := b.(Bytes); ok {
+			return strings.Compare(string(av), string(bv)), nil
+		}
+	case UUIDValue:
+		if bv, ok := b.(UUIDValue); ok {
+			return bytes.Compare(av[:], bv[:]), nil
+		}
+	}
+	return 0, fmt.Errorf("несоответствие типов: сравнение %s с %s", typeNameOf(a), typeNameOf(b))
+}
+
+func fnGenRandomUUID(args []interface{}) (interface{}, error) {
+	return newRandomUUID()
+}
+
+// fnEncode переводит BYTEA в текст: encode(data, 'hex' | 'base64').
+func fnEncode(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	data := []byte(args[0].(Bytes))
+	switch format := args[1].(string); strings.ToLower(format) {
+	case "hex":
+		return hex.EncodeToString(data), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(data), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат '%s': ожидалось hex или base64", format)
+	}
+}
+
+// fnDecode переводит текст в BYTEA: decode(text, 'hex' | 'base64').
+func fnDecode(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	text := args[0].(string)
+	switch format := args[1].(string); strings.ToLower(format) {
+	case "hex":
+		return decodeHex(text)
+	case "base64":
+		return decodeBase64(text)
+	default:
+		return nil, fmt.Errorf("неизвестный формат '%s': ожидалось hex или base64", format)
+	}
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestByteaAndUUIDColumns(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id UUID PRIMARY KEY DEFAULT gen_random_uuid(), name STRING, avatar BYTEA)",
+		"CREATE TABLE posts (id INTEGER PRIMARY KEY, author UUID REFERENCES users(id), title STRING)",
+		"INSERT INTO users (name, avatar) VALUES ('ann', X'89504E47')",
+		"INSERT INTO users (id, name, avatar) VALUES ('A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11', 'bob', B64'SGVsbG8=')",
+		`INSERT INTO users (id, name, avatar) VALUES ('{b0eebc999c0b4ef8bb6d6bb9bd380a11}', 'cid', '\x0001ff')`,
+		"INSERT INTO posts VALUES (1, 'a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'hello')",
+	)
+	mustFail(t, db, "INSERT INTO users (id, name) VALUES ('not-a-uuid', 'dan')")
+	mustFail(t, db, "INSERT INTO users (id, name) VALUES ('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'dup')")
+	mustFail(t, db, "INSERT INTO posts VALUES (2, 'c0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'orphan')")
+
+	cases := map[string]string{
+		"SELECT users.name, posts.title FROM users JOIN posts ON users.id = posts.author": "[[bob hello]]",
+		"SELECT name, encode(avatar, 'base64') FROM users WHERE avatar = X'48656C6C6F'":   "[[bob SGVsbG8=]]",
+		"SELECT avatar, encode(avatar, 'hex') FROM users WHERE name = 'ann'":              `[[\x89504e47 89504e47]]`,
+		"SELECT id, avatar FROM users WHERE id = 'b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11'":  `[[b0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11 \x0001ff]]`,
+		"SELECT decode('SGVsbG8=', 'base64') = decode('48656c6c6f', 'hex') FROM posts":    "[[true]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	rows := mustQuery(t, db, "SELECT id FROM users WHERE name = 'ann'")
+	generated, ok := rows[0][0].(UUIDValue)
+	if !ok || generated[6]>>4 != 4 {
+		t.Fatalf("gen_random_uuid: %v", rows[0][0])
+	}
+
+	before := fmt.Sprint(mustQuery(t, db, "SELECT * FROM users ORDER BY name"))
+	reopened := NewDatabase()
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT * FROM users ORDER BY name")); got != before {
+		t.Fatalf("после загрузки: %s, ожидалось %s", got, before)
+	}
+	rows = mustQuery(t, reopened, "SELECT id, avatar FROM users WHERE name = 'cid'")
+	if _, ok := rows[0][0].(UUIDValue); !ok {
+		t.Fatalf("тип id после загрузки: %T", rows[0][0])
+	}
+	if _, ok := rows[0][1].(Bytes); !ok {
+		t.Fatalf("тип avatar после загрузки: %T", rows[0][1])
+	}
+	mustFail(t, reopened, "INSERT INTO posts VALUES (3, 'c0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11', 'orphan')")
+}
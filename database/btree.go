@@ -0,0 +1,285 @@
+package database
+
+// Минимальная степень B-дерева: каждый узел, кроме корня, хранит
+// от btreeDegree-1 до 2*btreeDegree-1 ключей.
+const btreeDegree = 16
+
+type btreeItem struct {
+	key       []interface{}
+	positions []int
+}
+
+type btreeNode struct {
+	items    []*btreeItem
+	children []*btreeNode
+}
+
+type btree struct {
+	root *btreeNode
+	size int
+}
+
+// indexBound задаёт границу диапазона; key может быть префиксом ключа индекса.
+type indexBound struct {
+	key       []interface{}
+	inclusive bool
+}
+
+func (n *btreeNode) leaf() bool {
+	return len(n.children) == 0
+}
+
+// find возвращает позицию первого элемента, не меньшего key, и признак точного совпадения.
+func (n *btreeNode) find(key []interface{}) (int, bool) {
+	lo, hi := 0, len(n.items)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if compareKeys(n.items[mid].key, key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.items) && compareKeys(n.items[lo].key, key) == 0
+}
+
+func (t *btree) get(key []interface{}) *btreeItem {
+	n := t.root
+	for n != nil {
+		i, found := n.find(key)
+		if found {
+			return n.items[i]
+		}
+		if n.leaf() {
+			return nil
+		}
+		n = n.children[i]
+	}
+	return nil
+}
+
+func (t *btree) insert(key []interface{}, pos int) {
+	if item := t.get(key); item != nil {
+		item.positions = append(item.positions, pos)
+		return
+	}
+	item := &btreeItem{key: key, positions: []int{pos}}
+	t.size++
+	if t.root == nil {
+		t.root = &btreeNode{items: []*btreeItem{item}}
+		return
+	}
+	if len(t.root.items) == 2*btreeDegree-1 {
+		newRoot := &btreeNode{children: []*btreeNode{t.root}}
+		newRoot.splitChild(0)
+		t.root = newRoot
+	}
+	t.root.insertNonFull(item)
+}
+
+func (n *btreeNode) splitChild(i int) {
+	child := n.children[i]
+	middle := child.items[btreeDegree-1]
+	right := &btreeNode{items: append([]*btreeItem(nil), child.items[btreeDegree:]...)}
+	if !child.leaf() {
+		right.children = append([]*btreeNode(nil), child.children[btreeDegree:]...)
+		child.children = child.children[:btreeDegree]
+	}
+	child.items = child.items[:btreeDegree-1]
+
+	n.items = append(n.items, nil)
+	copy(n.items[i+1:], n.items[i:])
+	n.items[i] = middle
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = right
+}
+
+func (n *btreeNode) insertNonFull(item *btreeItem) {
+	i, _ := n.find(item.key)
+	if n.leaf() {
+		n.items = append(n.items, nil)
+		copy(n.items[i+1:], n.items[i:])
+		n.items[i] = item
+		return
+	}
+	if len(n.children[i].items) == 2*btreeDegree-1 {
+		n.splitChild(i)
+		if compareKeys(item.key, n.items[i].key) > 0 {
+			i++
+		}
+	}
+	n.children[i].insertNonFull(item)
+}
+
+// removePosition удаляет позицию строки из элемента key; пустой элемент удаляется из дерева.
+func (t *btree) removePosition(key []interface{}, pos int) {
+	item := t.get(key)
+	if item == nil {
+		return
+	}
+	for i, p := range item.positions {
+		if p == pos {
+			item.positions = append(item.positions[:i], item.positions[i+1:]...)
+			break
+		}
+	}
+	if len(item.positions) > 0 {
+		return
+	}
+	t.root.remove(key)
+	t.size--
+	if len(t.root.items) == 0 {
+		if t.root.leaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+}
+
+func (n *btreeNode) remove(key []interface{}) {
+	i, found := n.find(key)
+	if n.leaf() {
+		if found {
+			n.items = append(n.items[:i], n.items[i+1:]...)
+		}
+		return
+	}
+	if found {
+		switch {
+		case len(n.children[i].items) >= btreeDegree:
+			pred := n.children[i].max()
+			n.items[i] = pred
+			n.children[i].remove(pred.key)
+		case len(n.children[i+1].items) >= btreeDegree:
+			succ := n.children[i+1].min()
+			n.items[i] = succ
+			n.children[i+1].remove(succ.key)
+		default:
+			n.merge(i)
+			n.children[i].remove(key)
+		}
+		return
+	}
+	if len(n.children[i].items) < btreeDegree {
+		i = n.fill(i)
+	}
+	n.children[i].remove(key)
+}
+
+// fill гарантирует, что у потомка i не меньше btreeDegree ключей, и возвращает
+// индекс потомка, в котором теперь находится нужный диапазон.
+func (n *btreeNode) fill(i int) int {
+	switch {
+	case i > 0 && len(n.children[i-1].items) >= btreeDegree:
+		child, left := n.children[i], n.children[i-1]
+		child.items = append([]*btreeItem{n.items[i-1]}, child.items...)
+		n.items[i-1] = left.items[len(left.items)-1]
+		left.items = left.items[:len(left.items)-1]
+		if !left.leaf() {
+			child.children = append([]*btreeNode{left.children[len(left.children)-1]}, child.children...)
+			left.children = left.children[:len(left.children)-1]
+		}
+		return i
+	case i < len(n.children)-1 && len(n.children[i+1].items) >= btreeDegree:
+		child, right := n.children[i], n.children[i+1]
+		child.items = append(child.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = right.items[1:]
+		if !right.leaf() {
+			child.children = append(child.children, right.children[0])
+			right.children = right.children[1:]
+		}
+		return i
+	case i < len(n.children)-1:
+		n.merge(i)
+		return i
+	default:
+		n.merge(i - 1)
+		return i - 1
+	}
+}
+
+// merge объединяет потомков i и i+1 вместе с разделяющим ключом.
+func (n *btreeNode) merge(i int) {
+	child, right := n.children[i], n.children[i+1]
+	child.items = append(child.items, n.items[i])
+	child.items = append(child.items, right.items...)
+	child.children = append(child.children, right.children...)
+	n.items = append(n.items[:i], n.items[i+1:]...)
+	n.children = append(n.children[:i+1], n.children[i+2:]...)
+}
+
+func (n *btreeNode) min() *btreeItem {
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.items[0]
+}
+
+func (n *btreeNode) max() *btreeItem {
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	return n.items[len(n.items)-1]
+}
+
+func (b *indexBound) allowsLower(key []interface{}) bool {
+	cmp := compareKeyPrefix(key, b.key)
+	return cmp > 0 || (cmp == 0 && b.inclusive)
+}
+
+func (b *indexBound) allowsUpper(key []interface{}) bool {
+	cmp := compareKeyPrefix(key, b.key)
+	return cmp < 0 || (cmp == 0 && b.inclusive)
+}
+
+// ascend обходит элементы в порядке возрастания ключей в пределах [lower, upper].
+func (t *btree) ascend(lower, upper *indexBound, fn func(item *btreeItem) bool) {
+	if t.root != nil {
+		t.root.ascend(lower, upper, fn)
+	}
+}
+
+func (n *btreeNode) ascend(lower, upper *indexBound, fn func(item *btreeItem) bool) bool {
+	start := 0
+	if lower != nil {
+		for start < len(n.items) && !lower.allowsLower(n.items[start].key) {
+			start++
+		}
+	}
+	for i := start; i < len(n.items); i++ {
+		if !n.leaf() && !n.children[i].ascend(lower, upper, fn) {
+			return false
+		}
+		if upper != nil && !upper.allowsUpper(n.items[i].key) {
+			return false
+		}
+		if !fn(n.items[i]) {
+			return false
+		}
+	}
+	if !n.leaf() {
+		return n.children[len(n.items)].ascend(lower, upper, fn)
+	}
+	return true
+}
+
+func compareKeys(a, b []interface{}) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if cmp := compareValues(a[i], b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return len(a) - len(b)
+}
+
+func compareKeyPrefix(key, prefix []interface{}) int {
+	for i := 0; i < len(prefix) && i < len(key); i++ {
+		if cmp := compareValues(key[i], prefix[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
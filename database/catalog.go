@@ -0,0 +1,226 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Виртуальные таблицы information_schema строятся заново при каждом обращении
+// и доступны только для чтения.
+const informationSchemaPrefix = "information_schema."
+
+func isInformationSchema(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), informationSchemaPrefix)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "YES"
+	}
+	return "NO"
+}
+
+// informationSchemaTable возвращает виртуальную таблицу information_schema.<name>.
+func (db *Database) informationSchemaTable(name string) (*Table, error) {
+	switch strings.TrimPrefix(strings.ToLower(name), informationSchemaPrefix) {
+	case "tables":
+		return db.schemaTables(), nil
+	case "columns":
+		return db.schemaColumns(), nil
+	case "table_constraints":
+		return db.schemaTableConstraints(), nil
+	default:
+		return nil, fmt.Errorf("таблица '%s' не существует", name)
+	}
+}
+
+// relationKinds возвращает имена всех таблиц и представлений с их типом
+// в порядке имён.
+func (db *Database) relationKinds() ([]string, map[string]string) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	kinds := make(map[string]string)
+	for name := range db.Tables {
+		kinds[name] = "BASE TABLE"
+	}
+	for name, view := range db.Views {
+		if view.Materialized {
+			kinds[name] = "MATERIALIZED VIEW"
+		} else {
+			kinds[name] = "VIEW"
+		}
+	}
+	var names []string
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, kinds
+}
+
+func (db *Database) schemaTables() *Table {
+	table := &Table{
+		Name: "tables",
+		Columns: []Column{
+			{Name: "table_schema", Type: STRING},
+			{Name: "table_name", Type: STRING},
+			{Name: "table_type", Type: STRING},
+		},
+	}
+	names, kinds := db.relationKinds()
+	for _, name := range names {
+		table.Rows = append(table.Rows, []interface{}{"public", name, kinds[name]})
+	}
+	return table
+}
+
+func (db *Database) schemaColumns() *Table {
+	table := &Table{
+		Name: "columns",
+		Columns: []Column{
+			{Name: "table_schema", Type: STRING},
+			{Name: "table_name", Type: STRING},
+			{Name: "column_name", Type: STRING},
+			{Name: "ordinal_position", Type: INTEGER},
+			{Name: "data_type", Type: STRING},
+			{Name: "is_nullable", Type: STRING},
+			{Name: "column_default", Type: STRING},
+			{Name: "is_identity", Type: STRING},
+		},
+	}
+	names, _ := db.relationKinds()
+	for _, name := range names {
+		// Представление, ссылающееся на удалённую таблицу, просто не показывает столбцов.
+		relation, err := db.resolveRelation(name)
+		if err != nil {
+			continue
+		}
+		for i, col := range relation.Columns {
+			var columnDefault interface{}
+			if col.Default != "" {
+				columnDefault = col.Default
+			}
+			table.Rows = append(table.Rows, []interface{}{
+				"public", name, col.Name, i + 1, col.TypeName(), yesNo(!col.NotNull), columnDefault, yesNo(col.AutoIncrement),
+			})
+		}
+	}
+	return table
+}
+
+func (db *Database) schemaTableConstraints() *Table {
+	table := &Table{
+		Name: "table_constraints",
+		Columns: []Column{
+			{Name: "table_schema", Type: STRING},
+			{Name: "table_name", Type: STRING},
+			{Name: "constraint_name", Type: STRING},
+			{Name: "constraint_type", Type: STRING},
+			{Name: "is_deferrable", Type: STRING},
+			{Name: "initially_deferred", Type: STRING},
+		},
+	}
+	names, _ := db.relationKinds()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, name := range names {
+		relation, exists := db.Tables[name]
+		if !exists {
+			continue
+		}
+		for _, c := range relation.Constraints {
+			table.Rows = append(table.Rows, []interface{}{
+				"public", name, c.Name, c.Type.String(), yesNo(c.Deferrable), yesNo(c.InitiallyDeferred),
+			})
+		}
+	}
+	return table
+}
+
+// ShowTables возвращает имена и типы всех таблиц и представлений.
+func (db *Database) ShowTables() *ResultSet {
+	result := &ResultSet{Columns: []ResultColumn{{Name: "table_name", Type: STRING}, {Name: "table_type", Type: STRING}}}
+	names, kinds := db.relationKinds()
+	for _, name := range names {
+		result.Rows = append(result.Rows, []interface{}{name, kinds[name]})
+	}
+	return result
+}
+
+// DescribeTable возвращает описание столбцов таблицы или представления:
+// имя, тип, допустимость NULL, участие в ключе (PRI, UNI), DEFAULT и AUTO_INCREMENT.
+func (db *Database) DescribeTable(tableName string) (*ResultSet, error) {
+	table, err := db.resolveRelation(tableName)
+	if err != nil {
+		return nil, err
+	}
+	result := &ResultSet{Columns: []ResultColumn{
+		{Name: "column_name", Type: STRING},
+		{Name: "data_type", Type: STRING},
+		{Name: "is_nullable", Type: STRING},
+		{Name: "key", Type: STRING},
+		{Name: "column_default", Type: STRING},
+		{Name: "extra", Type: STRING},
+	}}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, col := range table.Columns {
+		key := ""
+		for _, c := range table.Constraints {
+			if !containsColumn(c.Columns, col.Name) {
+				continue
+			}
+			if c.Type == PrimaryKeyConstraint {
+				key = "PRI"
+			} else if c.Type == UniqueConstraint && key == "" && len(c.Columns) == 1 {
+				key = "UNI"
+			}
+		}
+		var columnDefault interface{}
+		if col.Default != "" {
+			columnDefault = col.Default
+		}
+		extra := ""
+		if col.AutoIncrement {
+			extra = "AUTO_INCREMENT"
+		}
+		result.Rows = append(result.Rows, []interface{}{col.Name, col.TypeName(), yesNo(!col.NotNull), key, columnDefault, extra})
+	}
+	return result, nil
+}
+
+// ShowIndexes возвращает индексы таблицы tableName или всех таблиц, если имя не задано.
+func (db *Database) ShowIndexes(tableName string) (*ResultSet, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var names []string
+	if tableName != "" {
+		tableName = strings.ToLower(tableName)
+		if _, exists := db.Tables[tableName]; !exists {
+			return nil, fmt.Errorf("таблица '%s' не существует", tableName)
+		}
+		names = []string{tableName}
+	} else {
+		for name := range db.Tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	result := &ResultSet{Columns: []ResultColumn{
+		{Name: "table_name", Type: STRING},
+		{Name: "index_name", Type: STRING},
+		{Name: "column_names", Type: STRING},
+		{Name: "index_method", Type: STRING},
+		{Name: "is_unique", Type: STRING},
+	}}
+	for _, name := range names {
+		for _, idx := range db.Tables[name].Indexes {
+			result.Rows = append(result.Rows, []interface{}{name, idx.Name, strings.Join(idx.Columns, ", "), idx.Method, yesNo(idx.Unique)})
+		}
+	}
+	return result, nil
+}
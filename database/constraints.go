@@ -0,0 +1,291 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ConstraintType int
+
+const (
+	PrimaryKeyConstraint ConstraintType = iota
+	UniqueConstraint
+	CheckConstraint
+	ForeignKeyConstraint
+)
+
+func (ct ConstraintType) String() string {
+	switch ct {
+	case PrimaryKeyConstraint:
+		return "PRIMARY KEY"
+	case UniqueConstraint:
+		return "UNIQUE"
+	case CheckConstraint:
+		return "CHECK"
+	case ForeignKeyConstraint:
+		return "FOREIGN KEY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type Constraint struct {
+	Name              string
+	Type              ConstraintType
+	Columns           []string
+	Expression        string
+	RefTable          string
+	RefColumns        []string
+	OnDelete          string
+	OnUpdate          string
+	Deferrable        bool
+	InitiallyDeferred bool
+	check             *Condition
+}
+
+// defaultValue обозначает ключевое слово DEFAULT в VALUES и SET.
+type defaultValue struct{}
+
+type ConstraintError struct {
+	Table      string
+	Constraint string
+	Message    string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("нарушение ограничения '%s' таблицы '%s': %s", e.Constraint, e.Table, e.Message)
+}
+
+// rowChange описывает новую версию строки: pos — позиция изменяемой строки
+// в table.Rows или -1 для вставляемой строки.
+type rowChange struct {
+	pos int
+	row []interface{}
+}
+
+func prepareConstraints(db *Database, tableName string, columns []Column, constraints []Constraint) ([]Constraint, []*Index, error) {
+	var prepared []Constraint
+	var indexes []*Index
+	hasPrimaryKey := false
+	for _, c := range constraints {
+		if len(c.Columns) == 0 && c.Type != CheckConstraint {
+			return nil, nil, fmt.Errorf("ограничение %s не содержит столбцов", c.Type)
+		}
+		for _, colName := range c.Columns {
+			found := false
+			for _, col := range columns {
+				if strings.ToLower(col.Name) == strings.ToLower(colName) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, nil, fmt.Errorf("столбец '%s' ограничения %s не найден в таблице '%s'", colName, c.Type, tableName)
+			}
+		}
+		switch c.Type {
+		case PrimaryKeyConstraint:
+			if hasPrimaryKey {
+				return nil, nil, fmt.Errorf("для таблицы '%s' задано несколько первичных ключей", tableName)
+			}
+			hasPrimaryKey = true
+			if c.Name == "" {
+				c.Name = tableName + "_pkey"
+			}
+		case UniqueConstraint:
+			if c.Name == "" {
+				c.Name = generateConstraintName(tableName+"_"+strings.ToLower(strings.Join(c.Columns, "_"))+"_key", prepared)
+			}
+		case CheckConstraint:
+			if c.Name == "" {
+				base := tableName + "_check"
+				if len(c.Columns) > 0 {
+					base = tableName + "_" + strings.ToLower(strings.Join(c.Columns, "_")) + "_check"
+				}
+				c.Name = generateConstraintName(base, prepared)
+			}
+			check, err := parseCheckExpression(db, c.Expression)
+			if err != nil {
+				return nil, nil, fmt.Errorf("неверное условие CHECK ограничения '%s': %v", c.Name, err)
+			}
+			var columnNames []string
+			for _, col := range columns {
+				columnNames = append(columnNames, col.Name)
+			}
+			_, _, err = evaluateConditionNull(make([]interface{}, len(columns)), columnNames, check)
+			if err != nil {
+				return nil, nil, fmt.Errorf("неверное условие CHECK ограничения '%s': %v", c.Name, err)
+			}
+		case ForeignKeyConstraint:
+			if c.Name == "" {
+				c.Name = generateConstraintName(tableName+"_"+strings.ToLower(strings.Join(c.Columns, "_"))+"_fkey", prepared)
+			}
+			if c.InitiallyDeferred && !c.Deferrable {
+				return nil, nil, fmt.Errorf("ограничение '%s' с INITIALLY DEFERRED должно быть DEFERRABLE", c.Name)
+			}
+		}
+		for _, other := range prepared {
+			if strings.ToLower(other.Name) == strings.ToLower(c.Name) {
+				return nil, nil, fmt.Errorf("ограничение '%s' уже существует", c.Name)
+			}
+		}
+		prepared = append(prepared, c)
+		if c.Type == PrimaryKeyConstraint || c.Type == UniqueConstraint {
+			indexes = append(indexes, &Index{
+				Name:    c.Name,
+				Columns: c.Columns,
+				Unique:  true,
+				Method:  "BTREE",
+				tree:    &btree{},
+			})
+		}
+	}
+	for _, col := range columns {
+		if col.Default == "" {
+			continue
+		}
+		if _, err := evaluateDefault(db, col); err != nil {
+			return nil, nil, err
+		}
+	}
+	return prepared, indexes, nil
+}
+
+func generateConstraintName(base string, existing []Constraint) string {
+	name := base
+	for n := 1; ; n++ {
+		taken := false
+		for _, c := range existing {
+			if strings.ToLower(c.Name) == name {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, n)
+	}
+}
+
+func parseCheckExpression(db *Database, expression string) (*Condition, error) {
+	tokens := tokenize(expression)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("пустое условие")
+	}
+	return parseWhere(db, tokens, 0)
+}
+
+func evaluateDefault(db *Database, col Column) (interface{}, error) {
+	if col.Default == "" {
+		return nil, nil
+	}
+	expr, err := parseExpressionString(db, col.Default)
+	if err != nil {
+		return nil, fmt.Errorf("неверное значение DEFAULT столбца '%s': %v", col.Name, err)
+	}
+	value, err := evaluateExpression(nil, nil, expr)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка вычисления DEFAULT столбца '%s': %v", col.Name, err)
+	}
+	value, err = coerceColumnValue(value, col)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка вычисления DEFAULT столбца '%s': %v", col.Name, err)
+	}
+	return value, nil
+}
+
+// validateRow проверяет ограничения NOT NULL и CHECK для новой версии строки.
+func validateRow(db *Database, table *Table, row []interface{}) error {
+	for i, col := range table.Columns {
+		if col.NotNull && row[i] == nil {
+			return &ConstraintError{
+				Table:      table.Name,
+				Constraint: table.Name + "_" + strings.ToLower(col.Name) + "_not_null",
+				Message:    fmt.Sprintf("значение NULL в столбце '%s' нарушает ограничение NOT NULL", col.Name),
+			}
+		}
+	}
+	var columnNames []string
+	for i := range table.Constraints {
+		c := &table.Constraints[i]
+		if c.Type != CheckConstraint {
+			continue
+		}
+		if c.check == nil {
+			check, err := parseCheckExpression(db, c.Expression)
+			if err != nil {
+				return fmt.Errorf("неверное условие CHECK ограничения '%s': %v", c.Name, err)
+			}
+			c.check = check
+		}
+		if columnNames == nil {
+			columnNames = table.columnNames()
+		}
+		result, unknown, err := evaluateConditionNull(row, columnNames, c.check)
+		if err != nil {
+			return fmt.Errorf("ошибка проверки ограничения '%s': %v", c.Name, err)
+		}
+		if !result && !unknown {
+			return &ConstraintError{
+				Table:      table.Name,
+				Constraint: c.Name,
+				Message:    fmt.Sprintf("строка не удовлетворяет условию CHECK (%s)", c.Expression),
+			}
+		}
+	}
+	return nil
+}
+
+// checkUniqueConstraints проверяет PRIMARY KEY, UNIQUE и уникальные индексы для набора
+// новых версий строк. Старые значения изменяемых строк не считаются конфликтующими.
+func checkUniqueConstraints(table *Table, changes []rowChange) error {
+	replaced := make(map[int]bool)
+	for _, ch := range changes {
+		if ch.pos >= 0 {
+			replaced[ch.pos] = true
+		}
+	}
+	for _, idx := range table.Indexes {
+		if !idx.Unique {
+			continue
+		}
+		isPrimaryKey := false
+		for _, c := range table.Constraints {
+			if c.Type == PrimaryKeyConstraint && strings.ToLower(c.Name) == strings.ToLower(idx.Name) {
+				isPrimaryKey = true
+			}
+		}
+		seen := make(map[string]bool)
+		for _, ch := range changes {
+			values := idx.keyValues(table, ch.row)
+			if hasNullValue(values) {
+				if isPrimaryKey {
+					return &ConstraintError{
+						Table:      table.Name,
+						Constraint: idx.Name,
+						Message:    fmt.Sprintf("столбцы первичного ключа (%s) не могут содержать NULL", strings.Join(idx.Columns, ", ")),
+					}
+				}
+				continue
+			}
+			key := encodeKey(values)
+			conflict := seen[key]
+			for _, pos := range idx.lookup(values) {
+				if !replaced[pos] {
+					conflict = true
+					break
+				}
+			}
+			if conflict {
+				return &ConstraintError{
+					Table:      table.Name,
+					Constraint: idx.Name,
+					Message:    fmt.Sprintf("значение (%s)=(%s) уже существует", strings.Join(idx.Columns, ", "), formatKeyValues(values)),
+				}
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCheckConstraintComparesColumns(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE ranges (id INTEGER, lo INTEGER, hi INTEGER, CHECK (lo < hi))",
+		"INSERT INTO ranges VALUES (1, 1, 5)",
+	)
+	mustFail(t, db, "INSERT INTO ranges VALUES (2, 5, 5)")
+	mustFail(t, db, "INSERT INTO ranges VALUES (3, 7, 2)")
+	mustFail(t, db, "UPDATE ranges SET lo = 9 WHERE id = 1")
+
+	rows := mustQuery(t, db, "SELECT id, lo, hi FROM ranges")
+	if got := fmt.Sprint(rows); got != "[[1 1 5]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestWhereComparesColumns(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE pairs (a INTEGER, b INTEGER)",
+		"INSERT INTO pairs VALUES (1, 2)",
+		"INSERT INTO pairs VALUES (3, 2)",
+		"INSERT INTO pairs VALUES (NULL, 2)",
+	)
+	rows := mustQuery(t, db, "SELECT a FROM pairs WHERE a < b + 0")
+	if got := fmt.Sprint(rows); got != "[[1]]" {
+		t.Fatalf("SELECT ... WHERE a < b + 0: %s", got)
+	}
+}
+
+func TestPrimaryKeyAndUniqueEnforced(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER AUTO_INCREMENT PRIMARY KEY, email STRING UNIQUE, name STRING)",
+		"CREATE TABLE order_items (order_id INTEGER, line INTEGER, product_name STRING, PRIMARY KEY (order_id, line))",
+		"INSERT INTO users (email, name) VALUES ('a@x', 'Alice')",
+		"INSERT INTO users (email, name) VALUES (NULL, 'Bob')",
+		"INSERT INTO users (email, name) VALUES (NULL, 'Carol')",
+		"INSERT INTO order_items VALUES (1, 1, 'Laptop')",
+		"INSERT INTO order_items VALUES (1, 2, 'Mouse')",
+	)
+	mustFail(t, db, "INSERT INTO users (email, name) VALUES ('a@x', 'Dave')")
+	mustFail(t, db, "INSERT INTO users (id, email, name) VALUES (1, 'd@x', 'Dave')")
+	mustFail(t, db, "UPDATE users SET email = 'a@x' WHERE name = 'Bob'")
+	mustFail(t, db, "INSERT INTO order_items VALUES (1, 1, 'Tablet')")
+	mustFail(t, db, "INSERT INTO order_items VALUES (NULL, 3, 'Tablet')")
+	mustFail(t, db, "UPDATE order_items SET line = 1 WHERE line = 2")
+
+	rows := mustQuery(t, db, "SELECT id, email, name FROM users ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[1 a@x Alice] [2 <nil> Bob] [3 <nil> Carol]]" {
+		t.Fatalf("строки users: %s", got)
+	}
+	rows = mustQuery(t, db, "SELECT order_id, line, product_name FROM order_items ORDER BY line")
+	if got := fmt.Sprint(rows); got != "[[1 1 Laptop] [1 2 Mouse]]" {
+		t.Fatalf("строки order_items: %s", got)
+	}
+}
+
+func TestUniqueCheckedAgainstWholeStatement(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE seq (n INTEGER PRIMARY KEY)",
+		"INSERT INTO seq VALUES (1)",
+		"INSERT INTO seq VALUES (2)",
+	)
+	mustFail(t, db, "UPDATE seq SET n = 5")
+
+	rows := mustQuery(t, db, "SELECT n FROM seq ORDER BY n")
+	if got := fmt.Sprint(rows); got != "[[1] [2]]" {
+		t.Fatalf("после неудачного UPDATE: %s", got)
+	}
+}
+
+func TestNotNullAndDefault(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE products (id INTEGER AUTO_INCREMENT PRIMARY KEY, name STRING NOT NULL, qty INTEGER DEFAULT 1, status STRING DEFAULT 'active', CONSTRAINT qty_range CHECK (qty >= 0 AND qty <= 1000))",
+		"INSERT INTO products (name) VALUES ('Laptop')",
+		"INSERT INTO products (name, qty, status) VALUES ('Mouse', NULL, 'hidden')",
+		"UPDATE products SET status = DEFAULT WHERE name = 'Mouse'",
+	)
+	mustFail(t, db, "INSERT INTO products (qty) VALUES (3)")
+	mustFail(t, db, "UPDATE products SET name = NULL")
+	mustFail(t, db, "INSERT INTO products (name, qty) VALUES ('Tablet', 5000)")
+
+	rows := mustQuery(t, db, "SELECT name, qty, status FROM products ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[Laptop 1 active] [Mouse <nil> active]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestForeignKeyActions(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name STRING)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE)",
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE SET NULL)",
+		"CREATE TABLE badges (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE RESTRICT)",
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"INSERT INTO users VALUES (2, 'Bob')",
+		"INSERT INTO users VALUES (3, 'Carol')",
+		"INSERT INTO orders VALUES (10, 1)",
+		"INSERT INTO orders VALUES (11, 2)",
+		"INSERT INTO notes VALUES (20, 2)",
+		"INSERT INTO badges VALUES (30, 3)",
+	)
+	mustFail(t, db, "INSERT INTO orders VALUES (12, 42)")
+	mustFail(t, db, "DELETE FROM users WHERE id = 3")
+
+	mustExec(t, db,
+		"UPDATE users SET id = 100 WHERE id = 1",
+		"DELETE FROM users WHERE id = 2",
+	)
+
+	rows := mustQuery(t, db, "SELECT id, user_id FROM orders ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[10 100]]" {
+		t.Fatalf("строки orders: %s", got)
+	}
+	rows = mustQuery(t, db, "SELECT id, user_id FROM notes")
+	if got := fmt.Sprint(rows); got != "[[20 <nil>]]" {
+		t.Fatalf("строки notes: %s", got)
+	}
+	rows = mustQuery(t, db, "SELECT id FROM users ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[3] [100]]" {
+		t.Fatalf("строки users: %s", got)
+	}
+}
+
+func TestForeignKeyDeferredUntilCommit(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name STRING)",
+		"CREATE TABLE payments (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED)",
+		"BEGIN",
+		"INSERT INTO payments VALUES (1, 50)",
+		"INSERT INTO users VALUES (50, 'Late')",
+		"COMMIT",
+		"BEGIN",
+		"INSERT INTO payments VALUES (2, 60)",
+	)
+	mustFail(t, db, "COMMIT")
+
+	rows := mustQuery(t, db, "SELECT id, user_id FROM payments ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[1 50]]" {
+		t.Fatalf("после отката при COMMIT: %s", got)
+	}
+
+	mustExec(t, db,
+		"BEGIN",
+		"SET CONSTRAINTS ALL IMMEDIATE",
+	)
+	mustFail(t, db, "INSERT INTO payments VALUES (3, 70)")
+	mustExec(t, db, "ROLLBACK")
+}
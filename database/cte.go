@@ -0,0 +1,212 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxRecursionDepth ограничивает число итераций рекурсивного CTE, чтобы запрос
+// по данным с циклом и UNION ALL не выполнялся бесконечно.
+const maxRecursionDepth = 1000
+
+// relationScope — результаты CTE из WITH, видимые в запросе как таблицы.
+type relationScope map[string]*Table
+
+// resolve возвращает отношение из FROM или JOIN: CTE запроса скрывают одноимённые
+// таблицы и представления.
+func (scope relationScope) resolve(db *Database, name string) (*Table, error) {
+	if table, exists := scope[strings.ToLower(name)]; exists {
+		return table, nil
+	}
+	return db.resolveRelation(name)
+}
+
+// commonTableExpr — определение CTE: name [(columns)] AS (query).
+type commonTableExpr struct {
+	name    string
+	columns []string
+	query   []string
+}
+
+// executeQuery выполняет запрос (SELECT или составной запрос), которому может
+// предшествовать WITH; scope — CTE внешнего запроса.
+func executeQuery(db *Database, tokens []string, scope relationScope) (*ResultSet, error) {
+	if len(tokens) == 0 || strings.ToUpper(tokens[0]) != "WITH" {
+		return executeCompound(db, tokens, scope)
+	}
+	recursive, ctes, next, err := parseWith(tokens)
+	if err != nil {
+		return nil, err
+	}
+	inner := make(relationScope, len(scope)+len(ctes))
+	for name, table := range scope {
+		inner[name] = table
+	}
+	for _, cte := range ctes {
+		var table *Table
+		if recursive && referencesRelation(cte.query, cte.name) {
+			table, err = evaluateRecursiveCTE(db, cte, inner)
+		} else {
+			table, err = evaluateCTE(db, cte, inner)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inner[cte.name] = table
+	}
+	return executeQuery(db, tokens[next:], inner)
+}
+
+// parseWith разбирает WITH [RECURSIVE] name [(col, ...)] AS (query) [, ...] и возвращает
+// позицию основного запроса.
+func parseWith(tokens []string) (bool, []commonTableExpr, int, error) {
+	i := 1
+	recursive := i < len(tokens) && strings.ToUpper(tokens[i]) == "RECURSIVE"
+	if recursive {
+		i++
+	}
+	var ctes []commonTableExpr
+	seen := make(map[string]bool)
+	for {
+		if i >= len(tokens) {
+			return false, nil, 0, errors.New("неверный синтаксис WITH: отсутствует имя запроса")
+		}
+		cte := commonTableExpr{name: strings.ToLower(tokens[i])}
+		if seen[cte.name] {
+			return false, nil, 0, fmt.Errorf("имя запроса WITH '%s' указано более одного раза", cte.name)
+		}
+		seen[cte.name] = true
+		i++
+		if i < len(tokens) && tokens[i] == "(" {
+			columns, next, err := parseIdentifierList(tokens, i)
+			if err != nil {
+				return false, nil, 0, fmt.Errorf("неверный синтаксис WITH: %v", err)
+			}
+			cte.columns, i = columns, next
+		}
+		if i+1 >= len(tokens) || strings.ToUpper(tokens[i]) != "AS" || tokens[i+1] != "(" {
+			return false, nil, 0, fmt.Errorf("неверный синтаксис WITH: ожидалось '%s AS (запрос)'", cte.name)
+		}
+		closing := matchingParen(tokens, i+1, len(tokens))
+		if closing == -1 {
+			return false, nil, 0, fmt.Errorf("неверный синтаксис WITH: отсутствует закрывающая скобка запроса '%s'", cte.name)
+		}
+		cte.query = tokens[i+2 : closing]
+		if len(cte.query) == 0 {
+			return false, nil, 0, fmt.Errorf("неверный синтаксис WITH: пустой запрос '%s'", cte.name)
+		}
+		ctes = append(ctes, cte)
+		i = closing + 1
+		if i < len(tokens) && tokens[i] == "," {
+			i++
+			continue
+		}
+		break
+	}
+	if i >= len(tokens) || !isQueryStart(tokens[i]) {
+		return false, nil, 0, errors.New("неверный синтаксис WITH: после определений ожидается SELECT")
+	}
+	return recursive, ctes, i, nil
+}
+
+// referencesRelation сообщает, упоминается ли отношение name после FROM или JOIN.
+func referencesRelation(tokens []string, name string) bool {
+	for _, relation := range relationNames(tokens) {
+		if strings.EqualFold(relation, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateCTE(db *Database, cte commonTableExpr, scope relationScope) (*Table, error) {
+	result, err := executeQuery(db, cte.query, scope)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса WITH '%s': %v", cte.name, err)
+	}
+	columns, err := resultTableColumns(cte.columns, result)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения запроса WITH '%s': %v", cte.name, err)
+	}
+	return &Table{Name: cte.name, Columns: columns, Rows: result.Rows}, nil
+}
+
+// evaluateRecursiveCTE выполняет рекурсивный CTE вида
+// нерекурсивная часть UNION [ALL] рекурсивная часть. Рекурсивная часть выполняется
+// по строкам, полученным на предыдущей итерации, пока они появляются. С UNION
+// повторяющиеся строки отбрасываются, поэтому обход графа с циклом завершается;
+// с UNION ALL число итераций ограничено maxRecursionDepth.
+func evaluateRecursiveCTE(db *Database, cte commonTableExpr, scope relationScope) (*Table, error) {
+	// Рекурсивная часть — после последнего UNION верхнего уровня.
+	unionIndex := len(cte.query)
+	for i := findClause(cte.query, 0, "UNION"); i < len(cte.query); i = findClause(cte.query, i+1, "UNION") {
+		unionIndex = i
+	}
+	if unionIndex == len(cte.query) {
+		return nil, fmt.Errorf("рекурсивный запрос '%s' должен иметь вид 'запрос UNION [ALL] рекурсивный запрос'", cte.name)
+	}
+	anchor, step := cte.query[:unionIndex], cte.query[unionIndex+1:]
+	unionAll := len(step) > 0 && strings.ToUpper(step[0]) == "ALL"
+	if unionAll {
+		step = step[1:]
+	}
+	if referencesRelation(anchor, cte.name) {
+		return nil, fmt.Errorf("нерекурсивная часть запроса '%s' не может ссылаться на него самого", cte.name)
+	}
+
+	table, err := evaluateCTE(db, commonTableExpr{name: cte.name, columns: cte.columns, query: anchor}, scope)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var rows [][]interface{}
+	addRows := func(candidates [][]interface{}) [][]interface{} {
+		var added [][]interface{}
+		for _, row := range candidates {
+			if !unionAll {
+				key := encodeKey(row)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+			}
+			added = append(added, row)
+		}
+		rows = append(rows, added...)
+		return added
+	}
+	working := addRows(table.Rows)
+
+	inner := make(relationScope, len(scope)+1)
+	for name, t := range scope {
+		inner[name] = t
+	}
+	for iteration := 0; len(working) > 0; iteration++ {
+		if iteration >= maxRecursionDepth {
+			return nil, fmt.Errorf("рекурсивный запрос '%s' превысил предел в %d итераций: возможно, данные содержат цикл (UNION вместо UNION ALL отбрасывает повторы)", cte.name, maxRecursionDepth)
+		}
+		inner[cte.name] = &Table{Name: cte.name, Columns: table.Columns, Rows: working}
+		result, err := executeQuery(db, step, inner)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка выполнения запроса WITH '%s': %v", cte.name, err)
+		}
+		if len(result.Columns) != len(table.Columns) {
+			return nil, fmt.Errorf("рекурсивная часть запроса '%s' возвращает %d столбцов вместо %d", cte.name, len(result.Columns), len(table.Columns))
+		}
+		converted := make([][]interface{}, len(result.Rows))
+		for i, values := range result.Rows {
+			row := make([]interface{}, len(table.Columns))
+			for j, col := range table.Columns {
+				value, err := coerceColumnValue(values[j], col)
+				if err != nil {
+					return nil, fmt.Errorf("рекурсивный запрос '%s', столбец '%s': %v", cte.name, col.Name, err)
+				}
+				row[j] = value
+			}
+			converted[i] = row
+		}
+		working = addRows(converted)
+	}
+	return &Table{Name: cte.name, Columns: table.Columns, Rows: rows}, nil
+}
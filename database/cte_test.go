@@ -0,0 +1,66 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTreeDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE employees (id INTEGER, name STRING, manager_id INTEGER)",
+		"INSERT INTO employees VALUES (1, 'Boss', NULL)",
+		"INSERT INTO employees VALUES (2, 'Ann', 1)",
+		"INSERT INTO employees VALUES (3, 'Bob', 1)",
+		"INSERT INTO employees VALUES (4, 'Cid', 2)",
+		"CREATE TABLE categories (id INTEGER, parent INTEGER)",
+		"INSERT INTO categories VALUES (1, 3)",
+		"INSERT INTO categories VALUES (2, 1)",
+		"INSERT INTO categories VALUES (3, 2)",
+	)
+	return db
+}
+
+func TestCommonTableExpressions(t *testing.T) {
+	db := newTreeDatabase(t)
+	cases := map[string]string{
+		"WITH managers AS (SELECT id, name FROM employees WHERE manager_id IS NULL), reports AS (SELECT employees.name FROM employees JOIN managers ON employees.manager_id = managers.id) SELECT name FROM reports ORDER BY name":                                                                                                 "[[Ann] [Bob]]",
+		"WITH employees (n) AS (SELECT name FROM employees WHERE id = 4) SELECT n FROM employees":                                                                                                                                                                                                                                  "[[Cid]]",
+		"WITH RECURSIVE subordinates (id, name, depth) AS (SELECT id, name, 1 FROM employees WHERE manager_id IS NULL UNION ALL SELECT employees.id, employees.name, subordinates.depth + 1 FROM employees JOIN subordinates ON employees.manager_id = subordinates.id) SELECT name, depth FROM subordinates ORDER BY depth, name": "[[Boss 1] [Ann 2] [Bob 2] [Cid 3]]",
+		"WITH RECURSIVE nums (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM nums WHERE n < 10) SELECT sum(n) FROM nums":                                                                                                                                                                                                              "[[55]]",
+		"WITH RECURSIVE walk (id) AS (SELECT 1 UNION SELECT categories.parent FROM categories JOIN walk ON categories.id = walk.id) SELECT id FROM walk ORDER BY id":                                                                                                                                                               "[[1] [2] [3]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "WITH missing AS (SELECT id FROM nosuch) SELECT id FROM missing")
+	mustFail(t, db, "WITH a AS (SELECT id FROM b), b AS (SELECT id FROM employees) SELECT id FROM a")
+}
+
+func TestRecursiveQueryIterationLimit(t *testing.T) {
+	db := newTreeDatabase(t)
+	mustFail(t, db, "WITH RECURSIVE nums (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM nums) SELECT count(*) FROM nums")
+	mustFail(t, db, "WITH RECURSIVE walk (id) AS (SELECT 1 UNION ALL SELECT categories.parent FROM categories JOIN walk ON categories.id = walk.id) SELECT id FROM walk")
+
+	rows := mustQuery(t, db, "WITH RECURSIVE nums (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM nums WHERE n < 1000) SELECT count(*), max(n) FROM nums")
+	if got := fmt.Sprint(rows); got != "[[1000 1000]]" {
+		t.Fatalf("1000 итераций: %s", got)
+	}
+}
+
+func TestWithInViewsAndCreateTableAs(t *testing.T) {
+	db := newTreeDatabase(t)
+	mustExec(t, db,
+		"CREATE VIEW chain AS WITH RECURSIVE up (id, depth) AS (SELECT 4, 0 UNION ALL SELECT employees.manager_id, up.depth + 1 FROM employees JOIN up ON employees.id = up.id WHERE employees.manager_id IS NOT NULL) SELECT id, depth FROM up",
+		"CREATE TABLE squares AS WITH RECURSIVE nums (n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM nums WHERE n < 3) SELECT n, n * n AS sq FROM nums",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, depth FROM chain ORDER BY depth")); got != "[[4 0] [2 1] [1 2]]" {
+		t.Fatalf("представление chain: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT sq FROM squares ORDER BY n")); got != "[[1] [4] [9]]" {
+		t.Fatalf("таблица squares: %s", got)
+	}
+}
@@ -13,6 +13,16 @@ const (
 	STRING DataType = iota
 	INTEGER
 	FLOAT
+	BOOLEAN
+	DATE
+	TIME
+	TIMESTAMP
+	TIMESTAMPTZ
+	INTERVAL
+	DECIMAL
+	JSON
+	BYTEA
+	UUID
 )
 
 func (dt DataType) String() string {
@@ -23,33 +33,80 @@ func (dt DataType) String() string {
 		return "INTEGER"
 	case FLOAT:
 		return "FLOAT"
+	case BOOLEAN:
+		return "BOOLEAN"
+	case DATE:
+		return "DATE"
+	case TIME:
+		return "TIME"
+	case TIMESTAMP:
+		return "TIMESTAMP"
+	case TIMESTAMPTZ:
+		return "TIMESTAMPTZ"
+	case INTERVAL:
+		return "INTERVAL"
+	case DECIMAL:
+		return "DECIMAL"
+	case JSON:
+		return "JSON"
+	case BYTEA:
+		return "BYTEA"
+	case UUID:
+		return "UUID"
 	default:
 		return "UNKNOWN"
 	}
 }
 
+// Column описывает столбец таблицы. Precision и Scale задаются только для
+// DECIMAL(p, s); нулевая точность означает DECIMAL без ограничений.
 type Column struct {
 	Name          string
 	Type          DataType
+	Precision     int
+	Scale         int
 	AutoIncrement bool
+	NotNull       bool
+	Default       string
+}
+
+// TypeName возвращает тип столбца в виде SQL, например DECIMAL(10,2).
+func (col Column) TypeName() string {
+	if col.Type == DECIMAL && col.Precision > 0 {
+		return fmt.Sprintf("DECIMAL(%d,%d)", col.Precision, col.Scale)
+	}
+	return col.Type.String()
 }
 
 type Table struct {
 	Name            string
 	Columns         []Column
 	Rows            [][]interface{}
+	Constraints     []Constraint
+	Indexes         []*Index
 	autoIncrementID map[string]int
 }
 
 type Database struct {
 	Tables      map[string]*Table
+	Views       map[string]*View
 	mu          sync.RWMutex
 	transaction *Transaction
+
+	// Функции, зарегистрированные через RegisterFunction и RegisterAggregate.
+	// Защищены отдельной блокировкой: их ищут при разборе запроса, когда mu
+	// может быть уже захвачен.
+	functions   map[string]*builtinFunction
+	aggregates  map[string]*aggregateFunction
+	functionsMu sync.RWMutex
+	// loading выставляется на время LoadFromDisk, когда функции ещё не зарегистрированы.
+	loading bool
 }
 
 func NewDatabase() *Database {
 	db := &Database{
 		Tables: make(map[string]*Table),
+		Views:  make(map[string]*View),
 	}
 	err := db.LoadFromDisk()
 	if err != nil {
@@ -62,13 +119,118 @@ func (db *Database) ExecuteSQL(query string) ([][]interface{}, error) {
 	return ParseAndExecute(db, query)
 }
 
+// Query выполняет запрос так же, как ExecuteSQL, но возвращает результат вместе
+// с именами и типами столбцов и числом затронутых строк. Для INSERT, UPDATE и
+// DELETE строки результата — список RETURNING; для TRUNCATE, CREATE TABLE AS и
+// REFRESH MATERIALIZED VIEW RowsAffected — число удалённых или записанных строк.
+func (db *Database) Query(query string) (*ResultSet, error) {
+	return executeStatement(db, query)
+}
+
 func (db *Database) CreateTable(tableName string, columns []Column) error {
+	return db.CreateTableWithConstraints(tableName, columns, nil)
+}
+
+func (db *Database) CreateTableWithConstraints(tableName string, columns []Column, constraints []Constraint) error {
+	return db.CreateTableWithIndexes(tableName, columns, constraints, nil)
+}
+
+// CreateTableWithIndexes создаёт таблицу вместе с дополнительными индексами;
+// индексам без имени назначается имя вида <таблица>_<столбцы>_idx.
+func (db *Database) CreateTableWithIndexes(tableName string, columns []Column, constraints []Constraint, indexes []*Index) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
+	return db.atomic(func() error {
+		return db.createTable(tableName, columns, constraints, indexes)
+	})
+}
+
+// CreateTableAs создаёт таблицу по результату запроса (CREATE TABLE ... AS SELECT).
+// Типы столбцов берутся из метаданных результата; columnNames переопределяет имена.
+func (db *Database) CreateTableAs(tableName string, columnNames []string, result *ResultSet, withData bool) error {
+	columns, err := resultTableColumns(columnNames, result)
+	if err != nil {
+		return err
+	}
 
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.atomic(func() error {
+		err := db.createTable(tableName, columns, nil, nil)
+		if err != nil || !withData {
+			return err
+		}
+		return db.insertResultRows(db.Tables[strings.ToLower(tableName)], result.Rows)
+	})
+}
+
+// resultTableColumns строит столбцы таблицы по метаданным результата запроса:
+// имена берутся из columnNames или из результата без префикса таблицы.
+func resultTableColumns(columnNames []string, result *ResultSet) ([]Column, error) {
+	if len(columnNames) > len(result.Columns) {
+		return nil, fmt.Errorf("указано больше имён столбцов (%d), чем столбцов в запросе (%d)", len(columnNames), len(result.Columns))
+	}
+	var columns []Column
+	for i, rc := range result.Columns {
+		name := rc.Name
+		if i < len(columnNames) {
+			name = columnNames[i]
+		} else if dot := strings.LastIndex(name, "."); dot != -1 {
+			name = name[dot+1:]
+		}
+		if findColumn(columns, name) != nil {
+			return nil, fmt.Errorf("столбец '%s' указан более одного раза", name)
+		}
+		columns = append(columns, Column{Name: name, Type: rc.Type, Precision: rc.Precision, Scale: rc.Scale})
+	}
+	return columns, nil
+}
+
+func (db *Database) insertResultRows(table *Table, rows [][]interface{}) error {
+	for _, values := range rows {
+		if len(values) != len(table.Columns) {
+			return fmt.Errorf("количество столбцов результата (%d) не совпадает с таблицей '%s' (%d)", len(values), table.Name, len(table.Columns))
+		}
+		row := make([]interface{}, len(table.Columns))
+		for i, col := range table.Columns {
+			value, err := coerceColumnValue(values[i], col)
+			if err != nil {
+				return fmt.Errorf("ошибка преобразования значения столбца '%s': %v", col.Name, err)
+			}
+			row[i] = value
+		}
+		if err := db.insertRow(table.Name, table, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) createTable(tableName string, columns []Column, constraints []Constraint, extraIndexes []*Index) error {
 	tableName = strings.ToLower(tableName)
-	if _, exists := db.Tables[tableName]; exists {
-		return fmt.Errorf("таблица '%s' уже существует", tableName)
+	if err := db.checkNameFree(tableName); err != nil {
+		return err
+	}
+
+	preparedConstraints, indexes, err := prepareConstraints(db, tableName, columns, constraints)
+	if err != nil {
+		return err
+	}
+	err = db.validateForeignKeys(tableName, columns, preparedConstraints)
+	if err != nil {
+		return err
+	}
+	for _, c := range preparedConstraints {
+		if c.Type != PrimaryKeyConstraint {
+			continue
+		}
+		for i := range columns {
+			for _, colName := range c.Columns {
+				if strings.ToLower(columns[i].Name) == strings.ToLower(colName) {
+					columns[i].NotNull = true
+				}
+			}
+		}
 	}
 
 	autoIncMap := make(map[string]int)
@@ -82,12 +244,24 @@ func (db *Database) CreateTable(tableName string, columns []Column) error {
 		Name:            tableName,
 		Columns:         columns,
 		Rows:            [][]interface{}{},
+		Constraints:     preparedConstraints,
+		Indexes:         indexes,
 		autoIncrementID: autoIncMap,
 	}
+	for _, idx := range extraIndexes {
+		if idx.Name == "" {
+			idx.Name = db.generateIndexName(table, tableName+"_"+strings.ToLower(strings.Join(idx.Columns, "_"))+"_idx")
+		}
+		if err := idx.rebuild(db, table); err != nil {
+			return err
+		}
+		table.Indexes = append(table.Indexes, idx)
+	}
 
+	db.logSchemaChange(tableName)
 	db.Tables[tableName] = table
 
-	err := db.saveTableToDisk(tableName)
+	err = db.saveTableToDisk(tableName)
 	if err != nil {
 		return err
 	}
@@ -100,113 +274,232 @@ func (db *Database) Insert(tableName string, values []string) error {
 	defer db.mu.Unlock()
 
 	tableName = strings.ToLower(tableName)
-	table, exists := db.Tables[tableName]
-	if !exists {
-		return fmt.Errorf("таблица '%s' не существует", tableName)
+	table, err := db.writableTable(tableName)
+	if err != nil {
+		return err
 	}
 
+	newValues := make([]interface{}, len(table.Columns))
+	provided := make([]bool, len(table.Columns))
 	if len(values) < len(table.Columns) {
-		newValues := make([]interface{}, len(table.Columns))
 		valueIndex := 0
 		for i, col := range table.Columns {
-			if col.AutoIncrement {
-				table.autoIncrementID[col.Name]++
-				newValues[i] = table.autoIncrementID[col.Name]
-			} else {
-				if valueIndex >= len(values) {
-					newValues[i] = nil
-				} else {
-					val, err := parseValue(values[valueIndex], col.Type)
-					if err != nil {
-						return err
-					}
-					newValues[i] = val
-					valueIndex++
-				}
+			if col.AutoIncrement || valueIndex >= len(values) {
+				continue
+			}
+			val, err := parseValue(values[valueIndex], col.Type)
+			if err != nil {
+				return err
 			}
+			newValues[i] = val
+			provided[i] = true
+			valueIndex++
 		}
-		table.Rows = append(table.Rows, newValues)
 	} else {
-		newValues := make([]interface{}, len(table.Columns))
 		for i, col := range table.Columns {
 			if col.AutoIncrement {
-				table.autoIncrementID[col.Name]++
-				newValues[i] = table.autoIncrementID[col.Name]
-			} else {
-				if i >= len(values) {
-					newValues[i] = nil
-				} else {
-					val, err := parseValue(values[i], col.Type)
-					if err != nil {
-						return err
-					}
-					newValues[i] = val
-				}
+				continue
+			}
+			val, err := parseValue(values[i], col.Type)
+			if err != nil {
+				return err
 			}
+			newValues[i] = val
+			provided[i] = true
 		}
-		table.Rows = append(table.Rows, newValues)
 	}
 
-	// Сохранение на диск
-	err := db.saveTableToDisk(tableName)
-	if err != nil {
-		return err
-	}
+	return db.atomic(func() error {
+		row, err := db.buildRow(table, newValues, provided)
+		if err != nil {
+			return err
+		}
+		return db.insertRow(tableName, table, row)
+	})
+}
 
-	return nil
+// InsertValues вставляет строку с уже типизированными значениями. Если columns
+// не задан, значения сопоставляются со столбцами по порядку (при нехватке значений
+// AUTO_INCREMENT-столбцы пропускаются); незаполненные столбцы получают DEFAULT.
+func (db *Database) InsertValues(tableName string, columns []string, values []interface{}) error {
+	return db.InsertOnConflict(tableName, columns, values, nil)
 }
 
-func parseValue(value string, dataType DataType) (interface{}, error) {
-	switch dataType {
-	case INTEGER:
-		return strconv.Atoi(value)
-	case FLOAT:
-		return strconv.ParseFloat(value, 64)
-	case STRING:
-		return value, nil
-	default:
-		return nil, fmt.Errorf("неизвестный тип данных %v", dataType)
-	}
+// InsertOnConflict вставляет строку так же, как InsertValues, но если строка
+// нарушает ограничение уникальности из conflict, вместо ошибки выполняется
+// действие conflict. Поиск конфликта и вставка или обновление выполняются под
+// одной блокировкой базы. conflict == nil означает обычную вставку.
+func (db *Database) InsertOnConflict(tableName string, columns []string, values []interface{}, conflict *OnConflict) error {
+	_, err := db.insertValues(tableName, columns, values, conflict, nil)
+	return err
 }
 
-func (db *Database) Select(tableName string, condition *Condition) ([][]interface{}, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+// insertValues вставляет строку и возвращает список RETURNING по вставленной или
+// обновлённой при конфликте строке вместе с числом затронутых строк.
+func (db *Database) insertValues(tableName string, columns []string, values []interface{}, conflict *OnConflict, returning []selectItem) (*ResultSet, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
-	table, exists := db.Tables[strings.ToLower(tableName)]
-	if !exists {
-		return nil, fmt.Errorf("таблица '%s' не существует", tableName)
+	tableName = strings.ToLower(tableName)
+	table, err := db.writableTable(tableName)
+	if err != nil {
+		return nil, err
 	}
 
-	var columnNames []string
-	for _, col := range table.Columns {
-		columnNames = append(columnNames, col.Name)
+	newValues := make([]interface{}, len(table.Columns))
+	provided := make([]bool, len(table.Columns))
+	if len(columns) > 0 {
+		if len(columns) != len(values) {
+			return nil, fmt.Errorf("количество столбцов (%d) не совпадает с количеством значений (%d)", len(columns), len(values))
+		}
+		for i, colName := range columns {
+			colIndex := getColumnIndex(table, colName)
+			if colIndex == -1 {
+				return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", colName, tableName)
+			}
+			if provided[colIndex] {
+				return nil, fmt.Errorf("столбец '%s' указан более одного раза", colName)
+			}
+			newValues[colIndex] = values[i]
+			provided[colIndex] = true
+		}
+	} else if len(values) > len(table.Columns) {
+		return nil, fmt.Errorf("слишком много значений для таблицы '%s'", tableName)
+	} else if len(values) < len(table.Columns) {
+		valueIndex := 0
+		for i, col := range table.Columns {
+			if col.AutoIncrement || valueIndex >= len(values) {
+				continue
+			}
+			newValues[i] = values[valueIndex]
+			provided[i] = true
+			valueIndex++
+		}
+	} else {
+		for i := range table.Columns {
+			newValues[i] = values[i]
+			provided[i] = true
+		}
 	}
 
-	var result [][]interface{}
-	for _, row := range table.Rows {
-		if condition != nil {
-			match, err := evaluateCondition(row, columnNames, condition)
+	var result *ResultSet
+	err = db.atomic(func() error {
+		row, err := db.buildRow(table, newValues, provided)
+		if err != nil {
+			return err
+		}
+		written := [][]interface{}{row}
+		handled := false
+		if conflict != nil {
+			var updated []interface{}
+			if updated, handled, err = db.resolveConflict(tableName, table, row, conflict); err != nil {
+				return err
+			}
+			if handled {
+				written = nil
+				if updated != nil {
+					written = append(written, updated)
+				}
+			}
+		}
+		if !handled {
+			if err := db.insertRow(tableName, table, row); err != nil {
+				return err
+			}
+		}
+		result, err = returningResult(table, returning, written)
+		return err
+	})
+	return result, err
+}
+
+// buildRow приводит значения к типам столбцов и заполняет пропущенные столбцы
+// значениями AUTO_INCREMENT и DEFAULT.
+func (db *Database) buildRow(table *Table, values []interface{}, provided []bool) ([]interface{}, error) {
+	row := make([]interface{}, len(table.Columns))
+	for i, col := range table.Columns {
+		value := values[i]
+		_, isDefault := value.(defaultValue)
+		if provided[i] && !isDefault {
+			val, err := coerceColumnValue(value, col)
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("столбец '%s': %v", col.Name, err)
 			}
-			if match {
-				result = append(result, row)
+			row[i] = val
+			if id, ok := val.(int); ok && col.AutoIncrement && id > table.autoIncrementID[col.Name] {
+				table.autoIncrementID[col.Name] = id
 			}
-		} else {
-			result = append(result, row)
+			continue
+		}
+		if col.AutoIncrement {
+			table.autoIncrementID[col.Name]++
+			row[i] = table.autoIncrementID[col.Name]
+			continue
 		}
+		val, err := evaluateDefault(db, col)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = val
 	}
-	return result, nil
+	return row, nil
+}
+
+func (db *Database) insertRow(tableName string, table *Table, row []interface{}) error {
+	err := validateRow(db, table, row)
+	if err != nil {
+		return err
+	}
+	err = checkUniqueConstraints(table, []rowChange{{pos: -1, row: row}})
+	if err != nil {
+		return err
+	}
+
+	table.Rows = append(table.Rows, row)
+	table.indexRow(len(table.Rows) - 1)
+	if db.transaction != nil {
+		op := Operation{
+			Type:      "INSERT",
+			TableName: tableName,
+			RowIndex:  len(table.Rows) - 1,
+		}
+		db.transaction.operations = append(db.transaction.operations, op)
+	}
+
+	// Внешние ключи проверяются после вставки, чтобы строка могла ссылаться на себя
+	err = db.checkForeignKeys(table, [][]interface{}{row})
+	if err != nil {
+		return err
+	}
+
+	// Сохранение на диск
+	return db.saveTableToDisk(tableName)
+}
+
+func (db *Database) Select(tableName string, condition *Condition) ([][]interface{}, error) {
+	table, err := db.resolveRelation(tableName)
+	if err != nil {
+		return nil, err
+	}
+	rows, _, err := db.selectOrdered(table, condition, nil)
+	return rows, err
+}
+
+// selectOrdered выбирает строки таблицы; ordered сообщает, что строки уже
+// отсортированы по orderBy с помощью индекса.
+func (db *Database) selectOrdered(table *Table, condition *Condition, orderBy []OrderBy) ([][]interface{}, bool, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return scanTable(table, condition, orderBy)
 }
 
 func (db *Database) Update(tableName string, columnName string, newValue string, condition *Condition) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	tableName = strings.ToLower(tableName)
-	table, exists := db.Tables[tableName]
-	if !exists {
-		return fmt.Errorf("таблица '%s' не существует", tableName)
+	table, err := db.writableTable(tableName)
+	if err != nil {
+		return err
 	}
 	var colIndex int = -1
 	var colType DataType
@@ -221,98 +514,271 @@ func (db *Database) Update(tableName string, columnName string, newValue string,
 		return fmt.Errorf("столбец '%s' не найден в таблице '%s'", columnName, tableName)
 	}
 
-	var columnNames []string
-	for _, col := range table.Columns {
-		columnNames = append(columnNames, col.Name)
+	var val interface{}
+	switch colType {
+	case STRING:
+		val = newValue
+	case INTEGER:
+		intval, err := strconv.Atoi(newValue)
+		if err != nil {
+			return fmt.Errorf("ошибка преобразования '%s' в INTEGER: %v", newValue, err)
+		}
+		val = intval
+	case FLOAT:
+		floatval, err := strconv.ParseFloat(newValue, 64)
+		if err != nil {
+			return fmt.Errorf("ошибка преобразования '%s' в FLOAT: %v", newValue, err)
+		}
+		val = floatval
+	default:
+		parsed, err := parseValue(newValue, colType)
+		if err != nil {
+			return err
+		}
+		val = parsed
+	}
+	assignments := []Assignment{{Column: columnName, Value: &Expr{Type: LiteralExpr, Value: val}}}
+	return db.atomic(func() error {
+		_, err := db.updateRows(tableName, table, assignments, condition)
+		return err
+	})
+}
+
+type Assignment struct {
+	Column string
+	Value  *Expr
+}
+
+// UpdateValues выполняет UPDATE с выражениями, которые вычисляются для каждой строки.
+func (db *Database) UpdateValues(tableName string, assignments []Assignment, condition *Condition) error {
+	_, err := db.updateValues(tableName, assignments, condition, nil)
+	return err
+}
+
+// updateValues выполняет UPDATE и возвращает список RETURNING по новым версиям
+// строк вместе с числом изменённых строк.
+func (db *Database) updateValues(tableName string, assignments []Assignment, condition *Condition, returning []selectItem) (*ResultSet, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tableName = strings.ToLower(tableName)
+	table, err := db.writableTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+	var result *ResultSet
+	err = db.atomic(func() error {
+		rows, err := db.updateRows(tableName, table, assignments, condition)
+		if err != nil {
+			return err
+		}
+		result, err = returningResult(table, returning, rows)
+		return err
+	})
+	return result, err
+}
+
+// updateRows изменяет строки, удовлетворяющие condition, и возвращает их новые версии.
+func (db *Database) updateRows(tableName string, table *Table, assignments []Assignment, condition *Condition) ([][]interface{}, error) {
+	colIndexes, err := assignmentColumns(tableName, table, assignments)
+	if err != nil {
+		return nil, err
 	}
 
+	columnNames := table.columnNames()
+
+	var changes []rowChange
+	var newRows [][]interface{}
 	for rowIdx, row := range table.Rows {
 		if condition != nil {
 			match, err := evaluateCondition(row, columnNames, condition)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			if !match {
 				continue
 			}
 		}
 
+		newRow, err := db.assignValues(table, row, columnNames, assignments, colIndexes)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, rowChange{pos: rowIdx, row: newRow})
+		newRows = append(newRows, newRow)
+	}
+
+	return newRows, db.applyRowChanges(tableName, table, changes)
+}
+
+// assignmentColumns возвращает позиции столбцов, которым присваиваются значения.
+func assignmentColumns(tableName string, table *Table, assignments []Assignment) ([]int, error) {
+	colIndexes := make([]int, len(assignments))
+	for i, a := range assignments {
+		colIndexes[i] = getColumnIndex(table, a.Column)
+		if colIndexes[i] == -1 {
+			return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", a.Column, tableName)
+		}
+	}
+	return colIndexes, nil
+}
+
+// assignValues возвращает новую версию строки после присваиваний SET. Выражения
+// вычисляются по env — значениям с именами columnNames, первые из которых —
+// текущая версия строки.
+func (db *Database) assignValues(table *Table, env []interface{}, columnNames []string, assignments []Assignment, colIndexes []int) ([]interface{}, error) {
+	newRow := make([]interface{}, len(table.Columns))
+	copy(newRow, env)
+	for i, a := range assignments {
+		col := table.Columns[colIndexes[i]]
 		var val interface{}
-		switch colType {
-		case STRING:
-			val = newValue
-		case INTEGER:
-			intval, err := strconv.Atoi(newValue)
-			if err != nil {
-				return fmt.Errorf("ошибка преобразования '%s' в INTEGER: %v", newValue, err)
-			}
-			val = intval
-		case FLOAT:
-			floatval, err := strconv.ParseFloat(newValue, 64)
-			if err != nil {
-				return fmt.Errorf("ошибка преобразования '%s' в FLOAT: %v", newValue, err)
+		var err error
+		if a.Value.Type == DefaultExpr {
+			val, err = evaluateDefault(db, col)
+		} else {
+			val, err = evaluateExpression(env, columnNames, a.Value)
+			if err == nil {
+				val, err = coerceColumnValue(val, col)
 			}
-			val = floatval
 		}
-		oldValue := row[colIndex]
-		table.Rows[rowIdx][colIndex] = val
+		if err != nil {
+			return nil, fmt.Errorf("столбец '%s': %v", col.Name, err)
+		}
+		newRow[colIndexes[i]] = val
+	}
+	if err := validateRow(db, table, newRow); err != nil {
+		return nil, err
+	}
+	return newRow, nil
+}
+
+// applyRowChanges заменяет строки новыми версиями, проверяя ограничения
+// и выполняя действия внешних ключей, ссылающихся на изменённые строки.
+func (db *Database) applyRowChanges(tableName string, table *Table, changes []rowChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	err := checkUniqueConstraints(table, changes)
+	if err != nil {
+		return err
+	}
+
+	oldRows := make([][]interface{}, len(changes))
+	newRows := make([][]interface{}, len(changes))
+	for i, ch := range changes {
+		oldRow := table.Rows[ch.pos]
+		oldRows[i] = oldRow
+		newRows[i] = ch.row
+		table.unindexRow(ch.pos)
+		table.Rows[ch.pos] = ch.row
+		table.indexRow(ch.pos)
 		if db.transaction != nil {
 			op := Operation{
-				Type:       "UPDATE",
-				TableName:  tableName,
-				RowIndex:   rowIdx,
-				ColumnName: columnName,
-				NewValue:   val,
-				Data:       oldValue,
+				Type:      "UPDATE",
+				TableName: tableName,
+				RowIndex:  ch.pos,
+				NewValue:  ch.row,
+				Data:      oldRow,
 			}
 			db.transaction.operations = append(db.transaction.operations, op)
 		}
 	}
-	return db.saveTableToDisk(tableName)
+
+	err = db.saveTableToDisk(tableName)
+	if err != nil {
+		return err
+	}
+	err = db.checkForeignKeys(table, newRows)
+	if err != nil {
+		return err
+	}
+	return db.onReferencedRowsUpdated(table, oldRows, newRows)
 }
 
 func (db *Database) Delete(tableName string, condition *Condition) error {
+	_, err := db.deleteValues(tableName, condition, nil)
+	return err
+}
+
+// deleteValues выполняет DELETE и возвращает список RETURNING по удалённым
+// строкам вместе с их числом.
+func (db *Database) deleteValues(tableName string, condition *Condition, returning []selectItem) (*ResultSet, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	tableName = strings.ToLower(tableName)
-	table, exists := db.Tables[tableName]
-	if !exists {
-		return fmt.Errorf("таблица '%s' не существует", tableName)
+	table, err := db.writableTable(tableName)
+	if err != nil {
+		return nil, err
 	}
 
 	// Создаем список имен столбцов
-	var columnNames []string
-	for _, col := range table.Columns {
-		columnNames = append(columnNames, col.Name)
-	}
+	columnNames := table.columnNames()
 
-	var newRows [][]interface{}
-	for _, row := range table.Rows {
-		deleteRow := false
+	var positions []int
+	var deleted [][]interface{}
+	for rowIdx, row := range table.Rows {
 		if condition != nil {
 			match, err := evaluateCondition(row, columnNames, condition)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			if match {
-				deleteRow = true
+			if !match {
+				continue
 			}
+			positions = append(positions, rowIdx)
+			deleted = append(deleted, row)
 		}
-		if deleteRow {
+	}
+	var result *ResultSet
+	err = db.atomic(func() error {
+		if err := db.deleteRows(tableName, table, positions); err != nil {
+			return err
+		}
+		result, err = returningResult(table, returning, deleted)
+		return err
+	})
+	return result, err
+}
+
+// deleteRows удаляет строки по возрастающим позициям и выполняет действия
+// внешних ключей, ссылающихся на удалённые строки.
+func (db *Database) deleteRows(tableName string, table *Table, positions []int) error {
+	if len(positions) == 0 {
+		return nil
+	}
+	toDelete := make(map[int]bool, len(positions))
+	for _, pos := range positions {
+		toDelete[pos] = true
+	}
+
+	var newRows [][]interface{}
+	var deletedRows [][]interface{}
+	deleted := 0
+	for rowIdx, row := range table.Rows {
+		if toDelete[rowIdx] {
 			if db.transaction != nil {
 				op := Operation{
 					Type:      "DELETE",
 					TableName: tableName,
+					RowIndex:  rowIdx - deleted,
 					Data:      row,
 				}
 				db.transaction.operations = append(db.transaction.operations, op)
 			}
+			deletedRows = append(deletedRows, row)
+			deleted++
 			continue
 		}
 		newRows = append(newRows, row)
 	}
 	table.Rows = newRows
-	return db.saveTableToDisk(tableName)
+	if err := table.rebuildIndexes(db); err != nil {
+		return err
+	}
+	err := db.saveTableToDisk(tableName)
+	if err != nil {
+		return err
+	}
+	return db.onReferencedRowsDeleted(table, deletedRows)
 }
 
 func (db *Database) BeginTransaction() error {
@@ -332,6 +798,12 @@ func (db *Database) Commit() error {
 	if db.transaction == nil {
 		return fmt.Errorf("нет активной транзакции")
 	}
+	err := db.checkDeferredConstraints()
+	if err != nil {
+		db.undoOperations(0)
+		db.transaction = nil
+		return fmt.Errorf("транзакция откатана: %v", err)
+	}
 	db.transaction = nil
 	return nil
 }
@@ -342,28 +814,7 @@ func (db *Database) Rollback() error {
 	if db.transaction == nil {
 		return fmt.Errorf("нет активной транзакции")
 	}
-	for i := len(db.transaction.operations) - 1; i >= 0; i-- {
-		op := db.transaction.operations[i]
-		switch op.Type {
-		case "INSERT":
-			table, _ := db.Tables[op.TableName]
-			if len(table.Rows) > 0 {
-				table.Rows = table.Rows[:len(table.Rows)-1]
-				db.saveTableToDisk(op.TableName)
-			}
-		case "DELETE":
-			table, _ := db.Tables[op.TableName]
-			table.Rows = append(table.Rows, op.Data.([]interface{}))
-			db.saveTableToDisk(op.TableName)
-		case "UPDATE":
-			table, _ := db.Tables[op.TableName]
-			colIndex := getColumnIndex(table, op.ColumnName)
-			if colIndex != -1 && op.RowIndex < len(table.Rows) {
-				table.Rows[op.RowIndex][colIndex] = op.Data
-				db.saveTableToDisk(op.TableName)
-			}
-		}
-	}
+	db.undoOperations(0)
 	db.transaction = nil
 	return nil
 }
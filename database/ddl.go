@@ -0,0 +1,582 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Все операции изменения схемы выполняются через db.atomic: перед изменением таблицы
+// в журнал транзакции записывается её снимок (logSchemaChange), поэтому DDL внутри
+// BEGIN ... ROLLBACK отменяется вместе с изменениями данных.
+
+func (db *Database) DropTable(tableName string, cascade bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	if _, isView := db.Views[tableName]; isView {
+		return fmt.Errorf("'%s' является материализованным представлением: используйте DROP MATERIALIZED VIEW", tableName)
+	}
+	return db.atomic(func() error {
+		err := db.dropDependentViews(tableName, "", cascade,
+			"невозможно удалить таблицу '%s': от неё зависит представление '%s'")
+		if err != nil {
+			return err
+		}
+		err = db.dropReferencingConstraints(table, cascade, func(ref foreignKeyRef) bool { return true },
+			"невозможно удалить таблицу '%s': на неё ссылается ограничение '%s' таблицы '%s'")
+		if err != nil {
+			return err
+		}
+		db.logSchemaChange(tableName)
+		delete(db.Tables, tableName)
+		return db.removeTableFromDisk(tableName)
+	})
+}
+
+// TruncateTable удаляет все строки таблицы. При restartIdentity счётчики
+// AUTO_INCREMENT начинаются заново.
+func (db *Database) TruncateTable(tableName string, restartIdentity bool) error {
+	_, err := db.truncateTable(tableName, restartIdentity)
+	return err
+}
+
+// truncateTable очищает таблицу и возвращает число удалённых строк.
+func (db *Database) truncateTable(tableName string, restartIdentity bool) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return 0, fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	if _, isView := db.Views[tableName]; isView {
+		return 0, fmt.Errorf("материализованное представление '%s' недоступно для изменения", tableName)
+	}
+	for _, ref := range db.referencingConstraints(table) {
+		if ref.child != table {
+			return 0, fmt.Errorf("невозможно очистить таблицу '%s': на неё ссылается ограничение '%s' таблицы '%s'", tableName, ref.constraint.Name, ref.child.Name)
+		}
+	}
+	removed := len(table.Rows)
+	err := db.atomic(func() error {
+		return db.alterTable(tableName, func(altered *Table) error {
+			altered.Rows = [][]interface{}{}
+			if restartIdentity {
+				for name := range altered.autoIncrementID {
+					altered.autoIncrementID[name] = 0
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// AddColumn добавляет столбец; существующие строки получают значение DEFAULT
+// (или очередное значение AUTO_INCREMENT).
+func (db *Database) AddColumn(tableName string, column Column, constraints []Constraint) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	return db.atomic(func() error {
+		return db.alterTable(tableName, func(table *Table) error {
+			if findColumn(table.Columns, column.Name) != nil {
+				return fmt.Errorf("столбец '%s' уже существует в таблице '%s'", column.Name, tableName)
+			}
+			existing := len(table.Constraints)
+			table.Columns = append(table.Columns, column)
+			prepared, indexes, err := prepareConstraints(db, tableName, table.Columns, append(table.Constraints, constraints...))
+			if err != nil {
+				return err
+			}
+			table.Constraints = prepared
+			for _, c := range prepared[existing:] {
+				if c.Type == PrimaryKeyConstraint {
+					for _, colName := range c.Columns {
+						findColumn(table.Columns, colName).NotNull = true
+					}
+				}
+			}
+			for _, idx := range indexes {
+				if table.findIndex(idx.Name) == nil {
+					table.Indexes = append(table.Indexes, idx)
+				}
+			}
+
+			if column.AutoIncrement {
+				table.autoIncrementID[column.Name] = 0
+			}
+			for i, row := range table.Rows {
+				var value interface{}
+				if column.AutoIncrement {
+					table.autoIncrementID[column.Name]++
+					value = table.autoIncrementID[column.Name]
+				} else if value, err = evaluateDefault(db, column); err != nil {
+					return err
+				}
+				table.Rows[i] = append(append(make([]interface{}, 0, len(row)+1), row...), value)
+			}
+			return nil
+		})
+	})
+}
+
+// DropColumn удаляет столбец вместе с ограничениями и индексами, в которые он входит.
+// Внешние ключи других таблиц, ссылающиеся на столбец, удаляются только при cascade.
+func (db *Database) DropColumn(tableName, columnName string, cascade bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	colIndex := getColumnIndex(table, columnName)
+	if colIndex == -1 {
+		return fmt.Errorf("столбец '%s' не найден в таблице '%s'", columnName, tableName)
+	}
+	if len(table.Columns) == 1 {
+		return fmt.Errorf("невозможно удалить единственный столбец таблицы '%s'", tableName)
+	}
+	columnName = table.Columns[colIndex].Name
+
+	return db.atomic(func() error {
+		referencesColumn := func(ref foreignKeyRef) bool {
+			return ref.child != table && containsColumn(ref.constraint.RefColumns, columnName)
+		}
+		err := db.dropDependentViews(tableName, columnName, cascade,
+			"невозможно удалить столбец '%s': от него зависит представление '%s'")
+		if err != nil {
+			return err
+		}
+		err = db.dropReferencingConstraints(table, cascade, referencesColumn,
+			"невозможно удалить столбец: на таблицу '%s' ссылается ограничение '%s' таблицы '%s'")
+		if err != nil {
+			return err
+		}
+		return db.alterTable(tableName, func(table *Table) error {
+			var constraints []Constraint
+			for _, c := range table.Constraints {
+				if containsColumn(c.Columns, columnName) ||
+					(c.Type == ForeignKeyConstraint && c.RefTable == tableName && containsColumn(c.RefColumns, columnName)) ||
+					(c.Type == CheckConstraint && expressionReferences(c.Expression, columnName)) {
+					continue
+				}
+				constraints = append(constraints, c)
+			}
+			table.Constraints = constraints
+
+			var indexes []*Index
+			for _, idx := range table.Indexes {
+				if !indexReferences(idx, columnName) {
+					indexes = append(indexes, idx)
+				}
+			}
+			table.Indexes = indexes
+
+			table.Columns = append(table.Columns[:colIndex:colIndex], table.Columns[colIndex+1:]...)
+			delete(table.autoIncrementID, columnName)
+			for i, row := range table.Rows {
+				newRow := make([]interface{}, 0, len(row)-1)
+				newRow = append(newRow, row[:colIndex]...)
+				table.Rows[i] = append(newRow, row[colIndex+1:]...)
+			}
+			return nil
+		})
+	})
+}
+
+// RenameColumn переименовывает столбец во всех ограничениях, индексах и внешних ключах,
+// которые на него ссылаются.
+func (db *Database) RenameColumn(tableName, oldName, newName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	colIndex := getColumnIndex(table, oldName)
+	if colIndex == -1 {
+		return fmt.Errorf("столбец '%s' не найден в таблице '%s'", oldName, tableName)
+	}
+	if getColumnIndex(table, newName) != -1 {
+		return fmt.Errorf("столбец '%s' уже существует в таблице '%s'", newName, tableName)
+	}
+	oldName = table.Columns[colIndex].Name
+	if views := db.dependentViews(tableName, oldName); len(views) > 0 {
+		return fmt.Errorf("невозможно переименовать столбец '%s': от него зависит представление '%s'", oldName, views[0])
+	}
+
+	return db.atomic(func() error {
+		for _, ref := range db.referencingConstraints(table) {
+			if ref.child == table || !containsColumn(ref.constraint.RefColumns, oldName) {
+				continue
+			}
+			db.logSchemaChange(ref.child.Name)
+			ref.constraint.RefColumns = renameInList(ref.constraint.RefColumns, oldName, newName)
+			if err := db.saveTableToDisk(ref.child.Name); err != nil {
+				return err
+			}
+		}
+		return db.alterTable(tableName, func(table *Table) error {
+			table.Columns[colIndex].Name = newName
+			for i := range table.Constraints {
+				c := &table.Constraints[i]
+				c.Columns = renameInList(c.Columns, oldName, newName)
+				if c.Type == ForeignKeyConstraint && c.RefTable == tableName {
+					c.RefColumns = renameInList(c.RefColumns, oldName, newName)
+				}
+				if c.Type == CheckConstraint {
+					c.Expression = renameInExpression(c.Expression, oldName, newName)
+					c.check = nil
+				}
+			}
+			for _, idx := range table.Indexes {
+				for i, col := range idx.Columns {
+					idx.Columns[i] = renameInExpression(col, oldName, newName)
+				}
+			}
+			if id, ok := table.autoIncrementID[oldName]; ok {
+				delete(table.autoIncrementID, oldName)
+				table.autoIncrementID[newName] = id
+			}
+			return nil
+		})
+	})
+}
+
+// AlterColumnType меняет тип столбца, преобразуя существующие значения так же,
+// как при вставке (coerceValue).
+func (db *Database) AlterColumnType(tableName, columnName string, dataType DataType) error {
+	return db.AlterColumnTypeWithPrecision(tableName, columnName, dataType, 0, 0)
+}
+
+// AlterColumnTypeWithPrecision меняет тип столбца с учётом точности и масштаба DECIMAL(p, s).
+func (db *Database) AlterColumnTypeWithPrecision(tableName, columnName string, dataType DataType, precision, scale int) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	return db.atomic(func() error {
+		return db.alterTable(tableName, func(table *Table) error {
+			colIndex := getColumnIndex(table, columnName)
+			if colIndex == -1 {
+				return fmt.Errorf("столбец '%s' не найден в таблице '%s'", columnName, tableName)
+			}
+			column := &table.Columns[colIndex]
+			if column.AutoIncrement && dataType != INTEGER {
+				return fmt.Errorf("столбец '%s' с AUTO_INCREMENT должен иметь тип INTEGER", column.Name)
+			}
+			column.Type, column.Precision, column.Scale = dataType, precision, scale
+			for i, row := range table.Rows {
+				value, err := coerceColumnValue(row[colIndex], *column)
+				if err != nil {
+					return fmt.Errorf("невозможно преобразовать значение '%v' столбца '%s' в %s: %v", row[colIndex], column.Name, column.TypeName(), err)
+				}
+				newRow := append([]interface{}(nil), row...)
+				newRow[colIndex] = value
+				table.Rows[i] = newRow
+			}
+			return nil
+		})
+	})
+}
+
+// RenameTable переименовывает таблицу и её файл; внешние ключи других таблиц
+// переводятся на новое имя.
+func (db *Database) RenameTable(oldName, newName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	oldName = strings.ToLower(oldName)
+	newName = strings.ToLower(newName)
+	table, exists := db.Tables[oldName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", oldName)
+	}
+	if _, isView := db.Views[oldName]; isView {
+		return fmt.Errorf("'%s' является материализованным представлением", oldName)
+	}
+	if err := db.checkNameFree(newName); err != nil {
+		return err
+	}
+	if views := db.dependentViews(oldName, ""); len(views) > 0 {
+		return fmt.Errorf("невозможно переименовать таблицу '%s': от неё зависит представление '%s'", oldName, views[0])
+	}
+
+	return db.atomic(func() error {
+		for _, ref := range db.referencingConstraints(table) {
+			if ref.child == table {
+				continue
+			}
+			db.logSchemaChange(ref.child.Name)
+			ref.constraint.RefTable = newName
+			if err := db.saveTableToDisk(ref.child.Name); err != nil {
+				return err
+			}
+		}
+		db.logSchemaChange(oldName)
+		db.logSchemaChange(newName)
+		renamed := table.snapshot()
+		renamed.Name = newName
+		for i := range renamed.Constraints {
+			if c := &renamed.Constraints[i]; c.Type == ForeignKeyConstraint && c.RefTable == oldName {
+				c.RefTable = newName
+			}
+		}
+		if err := renamed.rebuildIndexes(db); err != nil {
+			return err
+		}
+		delete(db.Tables, oldName)
+		db.Tables[newName] = renamed
+		if err := db.removeTableFromDisk(oldName); err != nil {
+			return err
+		}
+		var pending []string
+		for key := range db.transaction.deferredChecks {
+			if strings.HasPrefix(key, oldName+".") {
+				pending = append(pending, key)
+			}
+		}
+		for _, key := range pending {
+			delete(db.transaction.deferredChecks, key)
+			db.transaction.deferredChecks[newName+strings.TrimPrefix(key, oldName)] = true
+		}
+		return db.saveTableToDisk(newName)
+	})
+}
+
+// alterTable применяет alter к копии таблицы, проверяет, что данные удовлетворяют
+// новой схеме, и подменяет ею исходную таблицу.
+func (db *Database) alterTable(tableName string, alter func(table *Table) error) error {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	altered := table.snapshot()
+	if err := alter(altered); err != nil {
+		return err
+	}
+	db.logSchemaChange(tableName)
+	db.Tables[tableName] = altered
+	if err := db.validateTable(altered); err != nil {
+		return err
+	}
+	return db.saveTableToDisk(tableName)
+}
+
+// validateTable проверяет все строки таблицы на соответствие её ограничениям,
+// а также внешние ключи, ссылающиеся на таблицу.
+func (db *Database) validateTable(table *Table) error {
+	if err := table.rebuildIndexes(db); err != nil {
+		return err
+	}
+	columnNames := table.columnNames()
+	for _, col := range table.Columns {
+		if _, err := evaluateDefault(db, col); err != nil {
+			return err
+		}
+	}
+	for i := range table.Constraints {
+		c := &table.Constraints[i]
+		if c.Type != CheckConstraint {
+			continue
+		}
+		check, err := parseCheckExpression(db, c.Expression)
+		if err == nil {
+			_, _, err = evaluateConditionNull(make([]interface{}, len(table.Columns)), columnNames, check)
+		}
+		if err != nil {
+			return fmt.Errorf("неверное условие CHECK ограничения '%s': %v", c.Name, err)
+		}
+		c.check = check
+	}
+
+	changes := make([]rowChange, len(table.Rows))
+	for pos, row := range table.Rows {
+		if err := validateRow(db, table, row); err != nil {
+			return err
+		}
+		changes[pos] = rowChange{pos: pos, row: row}
+	}
+	if err := checkUniqueConstraints(table, changes); err != nil {
+		return err
+	}
+
+	if err := db.validateForeignKeys(table.Name, table.Columns, table.Constraints); err != nil {
+		return err
+	}
+	for i := range table.Constraints {
+		if c := &table.Constraints[i]; c.Type == ForeignKeyConstraint {
+			if err := db.verifyForeignKey(table, c); err != nil {
+				return err
+			}
+		}
+	}
+	for _, ref := range db.referencingConstraints(table) {
+		if ref.child == table {
+			continue
+		}
+		if err := db.validateForeignKeys(ref.child.Name, ref.child.Columns, ref.child.Constraints); err != nil {
+			return err
+		}
+		if err := db.verifyForeignKey(ref.child, ref.constraint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropReferencingConstraints удаляет внешние ключи других таблиц, выбранные match,
+// или возвращает ошибку errFormat, если cascade не задан.
+func (db *Database) dropReferencingConstraints(parent *Table, cascade bool, match func(ref foreignKeyRef) bool, errFormat string) error {
+	for _, ref := range db.referencingConstraints(parent) {
+		if ref.child == parent || !match(ref) {
+			continue
+		}
+		if !cascade {
+			return fmt.Errorf(errFormat, parent.Name, ref.constraint.Name, ref.child.Name)
+		}
+	}
+	if !cascade {
+		return nil
+	}
+	for _, ref := range db.referencingConstraints(parent) {
+		if ref.child == parent || !match(ref) {
+			continue
+		}
+		child := ref.child
+		name := ref.constraint.Name
+		db.logSchemaChange(child.Name)
+		var constraints []Constraint
+		for _, c := range child.Constraints {
+			if c.Name != name {
+				constraints = append(constraints, c)
+			}
+		}
+		child.Constraints = constraints
+		fmt.Printf("Удалено ограничение '%s' таблицы '%s'.\n", name, child.Name)
+		if err := db.saveTableToDisk(child.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, col := range columns {
+		if strings.ToLower(col) == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func renameInList(columns []string, oldName, newName string) []string {
+	renamed := make([]string, len(columns))
+	for i, col := range columns {
+		if strings.ToLower(col) == strings.ToLower(oldName) {
+			col = newName
+		}
+		renamed[i] = col
+	}
+	return renamed
+}
+
+func expressionReferences(expression, columnName string) bool {
+	for _, token := range tokenize(expression) {
+		if columnMatches(token, columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexReferences сообщает, что столбец входит в индекс сам или через выражение.
+func indexReferences(idx *Index, columnName string) bool {
+	for _, col := range idx.Columns {
+		if expressionReferences(col, columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+func renameInExpression(expression, oldName, newName string) string {
+	if !expressionReferences(expression, oldName) {
+		return expression
+	}
+	tokens := tokenize(expression)
+	for i, token := range tokens {
+		if columnMatches(token, oldName) {
+			tokens[i] = newName
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+// likeDefinition копирует определение таблицы для CREATE TABLE ... (LIKE name).
+// Имена, типы (с точностью DECIMAL) и NOT NULL копируются всегда; остальное — по параметрам INCLUDING:
+// DEFAULTS, IDENTITY (AUTO_INCREMENT), CONSTRAINTS (CHECK), INDEXES (PRIMARY KEY, UNIQUE и индексы).
+// Внешние ключи не копируются.
+func (db *Database) likeDefinition(tableName string, including map[string]bool) ([]Column, []Constraint, []*Index, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	table, exists := db.Tables[strings.ToLower(tableName)]
+	if !exists {
+		return nil, nil, nil, fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	var columns []Column
+	for _, col := range table.Columns {
+		copied := Column{Name: col.Name, Type: col.Type, Precision: col.Precision, Scale: col.Scale, NotNull: col.NotNull}
+		if including["DEFAULTS"] {
+			copied.Default = col.Default
+		}
+		if including["IDENTITY"] {
+			copied.AutoIncrement = col.AutoIncrement
+		}
+		columns = append(columns, copied)
+	}
+	var constraints []Constraint
+	for _, c := range table.Constraints {
+		switch {
+		case c.Type == CheckConstraint && including["CONSTRAINTS"]:
+			constraints = append(constraints, Constraint{Name: c.Name, Type: c.Type, Columns: append([]string(nil), c.Columns...), Expression: c.Expression})
+		case (c.Type == PrimaryKeyConstraint || c.Type == UniqueConstraint) && including["INDEXES"]:
+			constraints = append(constraints, Constraint{Type: c.Type, Columns: append([]string(nil), c.Columns...)})
+		}
+	}
+	var indexes []*Index
+	if including["INDEXES"] {
+		for _, idx := range table.Indexes {
+			if hasConstraint(table, idx.Name) {
+				continue
+			}
+			indexes = append(indexes, &Index{Columns: append([]string(nil), idx.Columns...), Unique: idx.Unique, Method: idx.Method})
+		}
+	}
+	return columns, constraints, indexes, nil
+}
+
+func hasConstraint(table *Table, name string) bool {
+	for _, c := range table.Constraints {
+		if strings.ToLower(c.Name) == strings.ToLower(name) {
+			return true
+		}
+	}
+	return false
+}
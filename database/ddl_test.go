@@ -0,0 +1,188 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCreateTableLikeKeepsDecimalPrecision(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE prices (id INTEGER, amount DECIMAL(6,2))",
+		"CREATE TABLE prices_copy (LIKE prices INCLUDING ALL)",
+		"INSERT INTO prices_copy VALUES (1, 1.23456)",
+	)
+	if got := db.Tables["prices_copy"].Columns[1].TypeName(); got != "DECIMAL(6,2)" {
+		t.Fatalf("тип столбца amount: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT amount FROM prices_copy")); got != "[[1.23]]" {
+		t.Fatalf("значение amount: %s", got)
+	}
+}
+
+func TestCreateTableAsKeepsDecimalPrecision(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE prices (id INTEGER, amount DECIMAL(6,2))",
+		"INSERT INTO prices VALUES (1, 2.5)",
+		"CREATE TABLE prices_copy AS SELECT id, amount FROM prices",
+		"INSERT INTO prices_copy VALUES (2, 1.23456)",
+	)
+	if got := db.Tables["prices_copy"].Columns[1].TypeName(); got != "DECIMAL(6,2)" {
+		t.Fatalf("тип столбца amount: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT amount FROM prices_copy ORDER BY id")); got != "[[2.50] [1.23]]" {
+		t.Fatalf("значения amount: %s", got)
+	}
+}
+
+func TestDropTruncateAndAlter(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER AUTO_INCREMENT PRIMARY KEY, name STRING, age INTEGER)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id), amount INTEGER)",
+		"INSERT INTO users (name, age) VALUES ('Alice', 30)",
+		"INSERT INTO users (name, age) VALUES ('Bob', 25)",
+		"INSERT INTO orders VALUES (1, 1, 10)",
+	)
+	mustFail(t, db, "DROP TABLE users")
+	mustFail(t, db, "TRUNCATE users")
+	mustFail(t, db, "ALTER TABLE users ADD COLUMN nickname STRING NOT NULL")
+	mustFail(t, db, "ALTER TABLE users ALTER COLUMN name TYPE INTEGER")
+
+	mustExec(t, db,
+		"ALTER TABLE users ADD COLUMN email STRING DEFAULT 'unknown'",
+		"ALTER TABLE users RENAME COLUMN age TO years",
+		"ALTER TABLE orders ALTER COLUMN amount TYPE FLOAT",
+		"ALTER TABLE orders RENAME TO purchases",
+	)
+	rows := mustQuery(t, db, "SELECT name, years, email FROM users ORDER BY id")
+	if got := fmt.Sprint(rows); got != "[[Alice 30 unknown] [Bob 25 unknown]]" {
+		t.Fatalf("строки users: %s", got)
+	}
+	mustFail(t, db, "INSERT INTO purchases VALUES (2, 42, 1.5)")
+
+	mustExec(t, db,
+		"ALTER TABLE users DROP COLUMN email",
+		"DROP TABLE users CASCADE",
+		"INSERT INTO purchases VALUES (2, 42, 1.5)",
+		"TRUNCATE TABLE purchases",
+		"DROP TABLE IF EXISTS users",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT * FROM purchases")); got != "[]" {
+		t.Fatalf("после TRUNCATE: %s", got)
+	}
+	mustFail(t, db, "SELECT * FROM users")
+}
+
+func TestTruncateRestartIdentity(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE items (id INTEGER AUTO_INCREMENT PRIMARY KEY, name STRING)",
+		"INSERT INTO items (name) VALUES ('a')",
+		"INSERT INTO items (name) VALUES ('b')",
+		"TRUNCATE items",
+		"INSERT INTO items (name) VALUES ('c')",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id FROM items")); got != "[[3]]" {
+		t.Fatalf("после TRUNCATE: %s", got)
+	}
+	mustExec(t, db,
+		"TRUNCATE TABLE items RESTART IDENTITY",
+		"INSERT INTO items (name) VALUES ('d')",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id FROM items")); got != "[[1]]" {
+		t.Fatalf("после RESTART IDENTITY: %s", got)
+	}
+}
+
+func TestRolledBackDDLRestoresFiles(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name STRING)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, amount INTEGER)",
+		"CREATE INDEX orders_amount ON orders (amount)",
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"INSERT INTO orders VALUES (1, 10)",
+		"BEGIN",
+		"ALTER TABLE users RENAME TO people",
+		"ALTER TABLE people ADD COLUMN age INTEGER",
+		"DROP TABLE orders",
+		"CREATE TABLE extra (id INTEGER)",
+		"ROLLBACK",
+	)
+	for _, name := range []string{"users.json", "orders.json"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatalf("файл %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"people.json", "extra.json"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("файл %s остался после ROLLBACK", name)
+		}
+	}
+
+	mustExec(t, db,
+		"BEGIN",
+		"TRUNCATE orders",
+		"DROP INDEX orders_amount",
+		"ROLLBACK",
+	)
+
+	reopened := NewDatabase()
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT * FROM users")); got != "[[1 Alice]]" {
+		t.Fatalf("users после загрузки: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT * FROM orders")); got != "[[1 10]]" {
+		t.Fatalf("orders после загрузки: %s", got)
+	}
+	if reopened.Tables["orders"].findIndex("orders_amount") == nil {
+		t.Fatal("индекс orders_amount не восстановлен")
+	}
+	mustFail(t, reopened, "SELECT * FROM people")
+}
+
+func TestCreateTableIfNotExists(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name STRING)",
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"CREATE TABLE IF NOT EXISTS users (id INTEGER, email STRING)",
+	)
+	mustFail(t, db, "CREATE TABLE users (id INTEGER)")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, name FROM users")); got != "[[1 Alice]]" {
+		t.Fatalf("таблица изменилась: %s", got)
+	}
+}
+
+func TestCreateTableAsSelect(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name STRING, age INTEGER)",
+		"CREATE TABLE orders (id INTEGER, user_id INTEGER, product_name STRING)",
+		"INSERT INTO users VALUES (1, 'Alice', 30)",
+		"INSERT INTO users VALUES (2, 'Bob', 12)",
+		"INSERT INTO orders VALUES (1, 1, 'Laptop')",
+		"CREATE TABLE adults AS SELECT name, age FROM users WHERE age >= 18",
+		"CREATE TABLE user_orders (customer, product) AS SELECT users.name, orders.product_name FROM users JOIN orders ON users.id = orders.user_id",
+		"CREATE TABLE users_archive AS SELECT * FROM users WITH NO DATA",
+		// Ограничения исходной таблицы не переносятся.
+		"INSERT INTO users_archive VALUES (1, 'Alice', 30)",
+		"INSERT INTO users_archive VALUES (1, 'Alice', 30)",
+	)
+	cases := map[string]string{
+		"SELECT name, age FROM adults":              "[[Alice 30]]",
+		"SELECT customer, product FROM user_orders": "[[Alice Laptop]]",
+		"SELECT count(*) FROM users_archive":        "[[2]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	if got := db.Tables["adults"].Columns[1].TypeName(); got != "INTEGER" {
+		t.Fatalf("тип столбца age: %s", got)
+	}
+	mustFail(t, db, "INSERT INTO adults VALUES ('Carol', 'old')")
+}
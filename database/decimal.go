@@ -0,0 +1,258 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal — точное десятичное число: целое unscaled, делённое на 10^scale.
+// Значения неизменяемы: операции всегда создают новый big.Int.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// maxDecimalPrecision ограничивает точность DECIMAL(p, s), как в PostgreSQL.
+const maxDecimalPrecision = 1000
+
+// avgExtraScale — сколько знаков после запятой добавляет AVG к масштабу аргумента.
+const avgExtraScale = 16
+
+func (d Decimal) coefficient() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.coefficient()).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.coefficient().Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+func (d Decimal) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+// parseDecimal разбирает запись вида [-]123.45 или 1.5e3 без потери точности.
+func parseDecimal(s string) (Decimal, bool) {
+	s = strings.TrimSpace(s)
+	exponent := 0
+	if i := strings.IndexAny(s, "eE"); i != -1 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return Decimal{}, false
+		}
+		s, exponent = s[:i], exp
+	}
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart+fracPart == "" || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return Decimal{}, false
+	}
+	unscaled, _ := new(big.Int).SetString(sign+intPart+fracPart, 10)
+	d := Decimal{unscaled: unscaled, scale: len(fracPart) - exponent}
+	if d.scale < 0 {
+		return d.round(0), true
+	}
+	return d, true
+}
+
+func decimalFromInt(v int) Decimal {
+	return Decimal{unscaled: big.NewInt(int64(v))}
+}
+
+// decimalFromFloat переводит число в DECIMAL по его кратчайшей десятичной записи,
+// поэтому 0.1 становится ровно 0.1.
+func decimalFromFloat(v float64) (Decimal, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Decimal{}, false
+	}
+	return parseDecimal(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// toDecimal приводит INTEGER, FLOAT или DECIMAL к DECIMAL.
+func toDecimal(value interface{}) (Decimal, bool) {
+	switch v := value.(type) {
+	case Decimal:
+		return v, true
+	case int:
+		return decimalFromInt(v), true
+	case float64:
+		return decimalFromFloat(v)
+	}
+	return Decimal{}, false
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// round приводит число к масштабу scale, округляя половину от нуля.
+func (d Decimal) round(scale int) Decimal {
+	if scale >= d.scale {
+		return Decimal{unscaled: new(big.Int).Mul(d.coefficient(), pow10(scale-d.scale)), scale: scale}
+	}
+	divisor := pow10(d.scale - scale)
+	quotient, remainder := new(big.Int).QuoRem(d.coefficient(), divisor, new(big.Int))
+	if remainder.Abs(remainder).Lsh(remainder, 1).Cmp(divisor) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(d.coefficient().Sign())))
+	}
+	return Decimal{unscaled: quotient, scale: scale}
+}
+
+// trim убирает незначащие нули в дробной части, оставляя не меньше minScale знаков.
+func (d Decimal) trim(minScale int) Decimal {
+	coefficient := new(big.Int).Set(d.coefficient())
+	scale := d.scale
+	ten := big.NewInt(10)
+	remainder := new(big.Int)
+	for scale > minScale && coefficient.Sign() != 0 {
+		quotient, r := new(big.Int).QuoRem(coefficient, ten, remainder)
+		if r.Sign() != 0 {
+			break
+		}
+		coefficient, scale = quotient, scale-1
+	}
+	if coefficient.Sign() == 0 && scale > minScale {
+		scale = minScale
+	}
+	return Decimal{unscaled: coefficient, scale: scale}
+}
+
+func alignDecimals(a, b Decimal) (*big.Int, *big.Int, int) {
+	scale := max(a.scale, b.scale)
+	return a.round(scale).coefficient(), b.round(scale).coefficient(), scale
+}
+
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := alignDecimals(d, other)
+	return a.Cmp(b)
+}
+
+func (d Decimal) add(other Decimal) Decimal {
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) sub(other Decimal) Decimal {
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Sub(a, b), scale: scale}
+}
+
+func (d Decimal) neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.coefficient()), scale: d.scale}
+}
+
+func (d Decimal) abs() Decimal {
+	return Decimal{unscaled: new(big.Int).Abs(d.coefficient()), scale: d.scale}
+}
+
+// floor округляет число вниз до целого.
+func (d Decimal) floor() Decimal {
+	if d.scale <= 0 {
+		return d
+	}
+	// Div с положительным делителем округляет частное в сторону минус бесконечности.
+	return Decimal{unscaled: new(big.Int).Div(d.coefficient(), pow10(d.scale))}
+}
+
+func (d Decimal) ceil() Decimal {
+	return d.neg().floor().neg()
+}
+
+// roundTo округляет число до places знаков после запятой; при отрицательном places —
+// до десятков, сотен и так далее.
+func (d Decimal) roundTo(places int) Decimal {
+	if places >= 0 {
+		return d.round(places)
+	}
+	shifted := Decimal{unscaled: d.coefficient(), scale: d.scale - places}.round(0)
+	return Decimal{unscaled: new(big.Int).Mul(shifted.coefficient(), pow10(-places))}
+}
+
+func (d Decimal) mul(other Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.coefficient(), other.coefficient()), scale: d.scale + other.scale}
+}
+
+// quo делит число на other с avgExtraScale дополнительными знаками; лишние нули
+// после запятой отбрасываются до масштаба операндов.
+func (d Decimal) quo(other Decimal) Decimal {
+	scale := max(d.scale, other.scale)
+	a, b, _ := alignDecimals(d, other)
+	shifted := new(big.Int).Mul(a, pow10(scale+avgExtraScale+1))
+	quotient := new(big.Int).Quo(shifted, b)
+	return Decimal{unscaled: quotient, scale: scale + avgExtraScale + 1}.round(scale + avgExtraScale).trim(scale)
+}
+
+// rem возвращает остаток от деления со знаком делимого.
+func (d Decimal) rem(other Decimal) Decimal {
+	a, b, scale := alignDecimals(d, other)
+	return Decimal{unscaled: new(big.Int).Rem(a, b), scale: scale}
+}
+
+// divInt делит число на n с масштабом результата scale.
+func (d Decimal) divInt(n int, scale int) Decimal {
+	shifted := d.round(scale + 1).coefficient()
+	quotient := new(big.Int).Quo(shifted, big.NewInt(int64(n)))
+	return Decimal{unscaled: quotient, scale: scale + 1}.round(scale)
+}
+
+func (d Decimal) isInteger() bool {
+	return d.trim(0).scale == 0
+}
+
+func (d Decimal) float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// toInt округляет число до целого; ok = false, если оно не помещается в INTEGER.
+func (d Decimal) toInt() (int, bool) {
+	rounded := d.round(0).coefficient()
+	if !rounded.IsInt64() || rounded.Int64() != int64(int(rounded.Int64())) {
+		return 0, false
+	}
+	return int(rounded.Int64()), true
+}
+
+// fitDecimal приводит число к масштабу столбца DECIMAL(precision, scale) и проверяет,
+// что целая часть помещается в precision - scale цифр. precision == 0 означает
+// DECIMAL без ограничений.
+func fitDecimal(d Decimal, precision, scale int) (Decimal, error) {
+	if precision == 0 {
+		return d, nil
+	}
+	rounded := d.round(scale)
+	if new(big.Int).Abs(rounded.coefficient()).Cmp(pow10(precision)) >= 0 {
+		target := Column{Type: DECIMAL, Precision: precision, Scale: scale}.TypeName()
+		return Decimal{}, &CoercionError{Value: d, Target: target, Reason: fmt.Sprintf("целая часть длиннее %d знаков", precision-scale)}
+	}
+	return rounded, nil
+}
+
+// decimalKey возвращает ключ индекса для DECIMAL так, чтобы равные числа
+// INTEGER, FLOAT и DECIMAL попадали в одну хеш-корзину.
+func decimalKey(d Decimal) string {
+	if n, ok := d.toInt(); ok && d.isInteger() {
+		return "n" + strconv.Itoa(n)
+	}
+	f := d.float64()
+	if exact, ok := decimalFromFloat(f); ok && exact.Cmp(d) == 0 {
+		return "f" + strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return "d" + d.trim(0).String()
+}
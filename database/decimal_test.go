@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDecimalRoundingAndArithmetic(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE payments (id INTEGER PRIMARY KEY, amount DECIMAL(10, 2), rate NUMERIC)",
+		"INSERT INTO payments VALUES (1, 10.005, 0.1)",
+		"INSERT INTO payments VALUES (2, 0.1, 0.2)",
+		"INSERT INTO payments VALUES (3, -2.345, 12345678901234567890.123456789)",
+		"INSERT INTO payments VALUES (4, 2, NULL)",
+	)
+	mustFail(t, db, "INSERT INTO payments (id, amount) VALUES (5, 123456789)")
+	mustFail(t, db, "UPDATE payments SET amount = 99999999.995 WHERE id = 1")
+
+	cases := map[string]string{
+		"SELECT amount FROM payments ORDER BY id":                    "[[10.01] [0.10] [-2.35] [2.00]]",
+		"SELECT id FROM payments WHERE amount = 0.1":                 "[[2]]",
+		"SELECT id FROM payments WHERE amount = 2":                   "[[4]]",
+		"SELECT rate + 0.2 FROM payments WHERE id = 1":               "[[0.3]]",
+		"SELECT rate FROM payments WHERE id = 3":                     "[[12345678901234567890.123456789]]",
+		"SELECT sum(amount), avg(amount) FROM payments WHERE id < 3": "[[10.11 5.055]]",
+		"SELECT id FROM payments ORDER BY amount":                    "[[3] [2] [4] [1]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	before := fmt.Sprint(mustQuery(t, db, "SELECT * FROM payments ORDER BY id"))
+	reopened := NewDatabase()
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT * FROM payments ORDER BY id")); got != before {
+		t.Fatalf("после загрузки: %s, ожидалось %s", got, before)
+	}
+	if got := reopened.Tables["payments"].Columns[1].TypeName(); got != "DECIMAL(10,2)" {
+		t.Fatalf("тип после загрузки: %s", got)
+	}
+	mustExec(t, reopened, "INSERT INTO payments (id, amount) VALUES (6, 1.999)")
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT amount FROM payments WHERE id = 6")); got != "[[2.00]]" {
+		t.Fatalf("округление после загрузки: %s", got)
+	}
+}
+
+func TestDecimalJoinsAndIndexesAcrossNumericTypes(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE prices (code INTEGER, price DECIMAL(6,2))",
+		"CREATE INDEX prices_price ON prices (price)",
+		"CREATE TABLE limits (name STRING, cap FLOAT)",
+		"INSERT INTO prices VALUES (1, 2.50)",
+		"INSERT INTO prices VALUES (2, 3)",
+		"INSERT INTO limits VALUES ('low', 2.5)",
+		"INSERT INTO limits VALUES ('high', 3.0)",
+	)
+	cases := map[string]string{
+		"SELECT code FROM prices WHERE price = 3":                                                                   "[[2]]",
+		"SELECT code FROM prices WHERE price >= 2.5 ORDER BY code":                                                  "[[1] [2]]",
+		"SELECT limits.name, prices.code FROM limits JOIN prices ON limits.cap = prices.price ORDER BY prices.code": "[[low 1] [high 2]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// parseDistinct разбирает модификатор после SELECT: DISTINCT, DISTINCT ON (expr, ...)
+// или ALL. Возвращает выражения DISTINCT ON и позицию начала списка выборки.
+func parseDistinct(db *Database, tokens []string) (bool, []*Expr, int, error) {
+	if len(tokens) < 2 {
+		return false, nil, 1, nil
+	}
+	switch strings.ToUpper(tokens[1]) {
+	case "ALL":
+		return false, nil, 2, nil
+	case "DISTINCT":
+	default:
+		return false, nil, 1, nil
+	}
+	if len(tokens) < 3 || strings.ToUpper(tokens[2]) != "ON" {
+		return true, nil, 2, nil
+	}
+	if len(tokens) < 4 || tokens[3] != "(" {
+		return false, nil, 0, errors.New("неверный синтаксис DISTINCT ON: ожидался список выражений в скобках")
+	}
+	closing := matchingParen(tokens, 3, len(tokens))
+	if closing == -1 {
+		return false, nil, 0, errors.New("неверный синтаксис DISTINCT ON: отсутствует закрывающая скобка")
+	}
+	on, err := parseExpressionList(db, tokens, 4, closing, "DISTINCT ON")
+	if err != nil {
+		return false, nil, 0, err
+	}
+	return true, on, closing + 1, nil
+}
+
+// distinctRows оставляет первую из равных строк. Значения сравниваются так же,
+// как ключи индексов: 1, 1.0 и DECIMAL 1 равны, NULL равен NULL.
+func distinctRows(rows [][]interface{}) [][]interface{} {
+	seen := make(map[string]bool)
+	var distinct [][]interface{}
+	for _, row := range rows {
+		key := encodeKey(row)
+		if !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, row)
+		}
+	}
+	return distinct
+}
+
+// distinctOnRows оставляет для каждого значения выражений DISTINCT ON первую
+// строку; строки к этому моменту уже упорядочены по ORDER BY.
+func distinctOnRows(rows [][]interface{}, columnNames []string, on []*Expr) ([][]interface{}, error) {
+	seen := make(map[string]bool)
+	var distinct [][]interface{}
+	key := make([]interface{}, len(on))
+	for _, row := range rows {
+		for i, expr := range on {
+			value, err := evaluateExpression(row, columnNames, expr)
+			if err != nil {
+				return nil, err
+			}
+			key[i] = value
+		}
+		encoded := encodeKey(key)
+		if !seen[encoded] {
+			seen[encoded] = true
+			distinct = append(distinct, row)
+		}
+	}
+	return distinct, nil
+}
+
+// checkDistinctOrder проверяет, что результат DISTINCT не зависит от порядка строк,
+// как в PostgreSQL: первые выражения ORDER BY должны совпадать с выражениями
+// DISTINCT ON, а при обычном DISTINCT выражения ORDER BY — входить в список выборки.
+func checkDistinctOrder(distinct bool, distinctOn []*Expr, orderBy []OrderBy, items []selectItem, star bool) error {
+	if !distinct || len(orderBy) == 0 {
+		return nil
+	}
+	if len(distinctOn) > 0 {
+		covered := make([]bool, len(distinctOn))
+		remaining := len(distinctOn)
+		for _, item := range orderBy {
+			if remaining == 0 {
+				break
+			}
+			expr := resolveAlias(item.Expr, items)
+			found := false
+			for i, on := range distinctOn {
+				if sameExpression(expr, resolveAlias(on, items)) {
+					found = true
+					if !covered[i] {
+						covered[i] = true
+						remaining--
+					}
+				}
+			}
+			if !found {
+				return errors.New("выражения DISTINCT ON должны совпадать с начальными выражениями ORDER BY")
+			}
+		}
+		return nil
+	}
+	for _, item := range orderBy {
+		expr := resolveAlias(item.Expr, items)
+		if star && expr.Type == ColumnExpr {
+			continue
+		}
+		found := false
+		for _, selected := range items {
+			if sameExpression(expr, selected.expr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("в запросе с SELECT DISTINCT выражение ORDER BY '%s' должно входить в список выборки", exprString(item.Expr))
+		}
+	}
+	return nil
+}
+
+// sameExpression сравнивает выражения по тексту без учёта регистра; столбцы
+// сравниваются без префикса таблицы.
+func sameExpression(a, b *Expr) bool {
+	if a.Type == ColumnExpr && b.Type == ColumnExpr {
+		return columnMatches(a.Name, b.Name[strings.LastIndex(b.Name, ".")+1:])
+	}
+	return strings.EqualFold(exprString(a), exprString(b))
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDistinctRequiresDeterministicOrder(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE orders (id INTEGER, user_id INTEGER, amount INTEGER)",
+		"INSERT INTO orders VALUES (1, 1, 10)",
+		"INSERT INTO orders VALUES (2, 1, 30)",
+		"INSERT INTO orders VALUES (3, 2, 20)",
+	)
+	for _, query := range []string{
+		"SELECT DISTINCT ON (user_id) user_id, amount FROM orders ORDER BY amount",
+		"SELECT DISTINCT ON (user_id) user_id, amount FROM orders ORDER BY amount, user_id",
+		"SELECT DISTINCT user_id FROM orders ORDER BY amount",
+		"SELECT DISTINCT user_id FROM orders ORDER BY user_id, id",
+	} {
+		mustFail(t, db, query)
+	}
+	for query, want := range map[string]string{
+		"SELECT DISTINCT ON (user_id) user_id, amount FROM orders ORDER BY user_id, amount DESC": "[[1 30] [2 20]]",
+		"SELECT DISTINCT ON (user_id) user_id AS u, amount FROM orders ORDER BY u, amount":       "[[1 10] [2 20]]",
+		"SELECT DISTINCT ON (user_id, id) user_id, id FROM orders ORDER BY id, user_id":          "[[1 1] [1 2] [2 3]]",
+		"SELECT DISTINCT user_id FROM orders ORDER BY user_id DESC":                              "[[2] [1]]",
+		"SELECT DISTINCT amount * 2 AS a FROM orders ORDER BY a":                                 "[[20] [40] [60]]",
+		"SELECT DISTINCT * FROM orders ORDER BY amount":                                          "[[1 1 10] [3 2 20] [2 1 30]]",
+	} {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Fatalf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+}
+
+func TestDistinctRemovesDuplicates(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE readings (id INTEGER, sensor STRING, value FLOAT, exact DECIMAL(4,1))",
+		"INSERT INTO readings VALUES (1, 'a', 1, 1)",
+		"INSERT INTO readings VALUES (2, 'a', 1.0, 1.0)",
+		"INSERT INTO readings VALUES (3, NULL, NULL, NULL)",
+		"INSERT INTO readings VALUES (4, NULL, NULL, NULL)",
+		"INSERT INTO readings VALUES (5, 'b', 2.5, 2.5)",
+		"INSERT INTO readings VALUES (6, 'b', 3, 3)",
+	)
+	cases := map[string]string{
+		"SELECT DISTINCT sensor FROM readings ORDER BY sensor":                                                       "[[a] [b] [<nil>]]",
+		"SELECT DISTINCT sensor, value FROM readings ORDER BY sensor, value":                                         "[[a 1] [b 2.5] [b 3] [<nil> <nil>]]",
+		"SELECT DISTINCT exact = value FROM readings WHERE id < 3":                                                   "[[true]]",
+		"SELECT DISTINCT value, exact FROM readings WHERE id < 3":                                                    "[[1 1.0]]",
+		"SELECT DISTINCT sensor FROM readings ORDER BY sensor LIMIT 1 OFFSET 1":                                      "[[b]]",
+		"SELECT DISTINCT ON (sensor) sensor, id FROM readings ORDER BY sensor, id DESC":                              "[[a 2] [b 6] [<nil> 4]]",
+		"SELECT DISTINCT ON (lower(sensor)) id FROM readings WHERE sensor IS NOT NULL ORDER BY lower(sensor), value": "[[1] [5]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT DISTINCT ON (sensor) sensor, count(*) FROM readings GROUP BY sensor")
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestInvalidWhereChangesNoRows(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE t (id INTEGER, a INTEGER, b INTEGER, s STRING)",
+		"INSERT INTO t VALUES (1, 1, 2, 'one')",
+		"INSERT INTO t VALUES (2, 5, 3, 'two')",
+	)
+	for _, query := range []string{
+		"UPDATE t SET s = 'x' WHERE nosuch(id) = 1 RETURNING *",
+		"UPDATE t SET s = 'x' WHERE nosuch > 1",
+		"UPDATE t SET s = 'x' WHERE",
+		"DELETE FROM t WHERE nosuch(id) = 1",
+		"DELETE FROM t WHERE id = = 1",
+	} {
+		mustFail(t, db, query)
+	}
+	mustExec(t, db, "UPDATE t SET s = 'ZAPPED' WHERE a < b")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, s FROM t ORDER BY id")); got != "[[1 ZAPPED] [2 two]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
@@ -0,0 +1,898 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type ExprType int
+
+const (
+	LiteralExpr ExprType = iota
+	ColumnExpr
+	FunctionExpr
+	DefaultExpr
+	CastExpr
+	BinaryExpr
+	UnaryExpr
+	CaseExpr
+	WindowExpr
+	ConditionExpr
+)
+
+// Expr — выражение в списке выборки, VALUES, SET и DEFAULT. Для CastExpr целевой тип
+// (Column без имени) хранится в Value, а приводимое выражение — в Args[0]; для BinaryExpr
+// и UnaryExpr оператор хранится в Name, а операнды — в Args. Для CaseExpr условия веток
+// WHEN ([]*Condition) хранятся в Value, результаты THEN — в Args в том же порядке,
+// а результат ELSE, если он есть, — последним элементом Args. WindowExpr — вызов функции
+// с OVER: имя и аргументы как у FunctionExpr, окно (*windowSpec) — в Value. ConditionExpr —
+// логическое выражение (NOT active, price > 10): условие (*Condition) хранится в Value.
+type Expr struct {
+	Type  ExprType
+	Value interface{}
+	Name  string
+	Args  []*Expr
+}
+
+// Ключевые слова, которые в SQL вызываются без скобок.
+var niladicFunctions = map[string]string{
+	"CURRENT_TIMESTAMP": "current_timestamp",
+	"CURRENT_DATE":      "current_date",
+	"CURRENT_TIME":      "current_time",
+	"LOCALTIMESTAMP":    "localtimestamp",
+}
+
+// Типы, значения которых можно записать литералом вида DATE '2023-01-15' или UUID '...'.
+var typedLiterals = map[string]DataType{
+	"DATE":        DATE,
+	"TIME":        TIME,
+	"TIMESTAMP":   TIMESTAMP,
+	"TIMESTAMPTZ": TIMESTAMPTZ,
+	"INTERVAL":    INTERVAL,
+	"BYTEA":       BYTEA,
+	"UUID":        UUID,
+}
+
+// Приоритет бинарных операторов, как в PostgreSQL: * / % связывают сильнее + и -,
+// а конкатенация || и операторы JSON — слабее.
+func operatorPrecedence(op string) int {
+	switch op {
+	case "*", "/", "%":
+		return 3
+	case "+", "-":
+		return 2
+	case "||":
+		return 1
+	}
+	if isJSONOperator(op) {
+		return 1
+	}
+	return 0
+}
+
+// parseExpression разбирает выражение с арифметикой (+ - * / %), конкатенацией ||,
+// операторами JSON (->, ->>, #>, #>>, @>, <@), унарным минусом и скобками.
+func parseExpression(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	return parseBinary(db, tokens, start, end, 1)
+}
+
+// parseBinary разбирает левоассоциативную цепочку операторов с приоритетом не ниже precedence.
+func parseBinary(db *Database, tokens []string, start, end int, precedence int) (*Expr, int, error) {
+	var left *Expr
+	var next int
+	var err error
+	if precedence == 3 {
+		left, next, err = parseUnary(db, tokens, start, end)
+	} else {
+		left, next, err = parseBinary(db, tokens, start, end, precedence+1)
+	}
+	for err == nil && next < end && operatorPrecedence(tokens[next]) == precedence {
+		var right *Expr
+		op := tokens[next]
+		if precedence == 3 {
+			right, next, err = parseUnary(db, tokens, next+1, end)
+		} else {
+			right, next, err = parseBinary(db, tokens, next+1, end, precedence+1)
+		}
+		left = &Expr{Type: BinaryExpr, Name: op, Args: []*Expr{left, right}}
+	}
+	if err != nil {
+		return nil, next, err
+	}
+	return left, next, nil
+}
+
+// parseValueExpression разбирает выражение списка выборки или SET, которое может быть
+// и логическим: NOT active, a > b, x IS NULL AND y. Такое выражение становится
+// ConditionExpr и даёт TRUE, FALSE или NULL.
+func parseValueExpression(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	var expr *Expr
+	next := start
+	var err error
+	if start >= end || strings.ToUpper(tokens[start]) != "NOT" {
+		expr, next, err = parseExpression(db, tokens, start, end)
+		if err == nil && (next >= end || !isConditionOperator(tokens[next])) {
+			return expr, next, nil
+		}
+	}
+	cond, condNext, condErr := parseCondition(db, tokens, start, end)
+	if condErr != nil {
+		if err != nil {
+			return nil, next, err
+		}
+		return nil, condNext, condErr
+	}
+	return &Expr{Type: ConditionExpr, Value: cond}, condNext, nil
+}
+
+func isConditionOperator(token string) bool {
+	switch strings.ToUpper(token) {
+	case "IS", "AND", "OR":
+		return true
+	}
+	return isComparisonOperator(token)
+}
+
+func isComparisonOperator(token string) bool {
+	switch token {
+	case "=", "<>", "!=", "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// parseUnary разбирает унарные + и -. Минус перед числовым литералом сразу даёт
+// отрицательный литерал.
+func parseUnary(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	if start >= end || (tokens[start] != "-" && tokens[start] != "+") {
+		return parseOperand(db, tokens, start, end)
+	}
+	operand, next, err := parseUnary(db, tokens, start+1, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if tokens[start] == "+" {
+		return operand, next, nil
+	}
+	if operand.Type == LiteralExpr && isNumericValue(operand.Value) {
+		value, err := negateValue(operand.Value)
+		return &Expr{Type: LiteralExpr, Value: value}, next, err
+	}
+	return &Expr{Type: UnaryExpr, Name: "-", Args: []*Expr{operand}}, next, nil
+}
+
+// parseOperand разбирает операнд с необязательным приведением expr::type.
+func parseOperand(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	expr, next, err := parsePrimary(db, tokens, start, end)
+	for err == nil && next < end && tokens[next] == "::" {
+		var target Column
+		target, next, err = parseColumnType(tokens[:end], next+1)
+		expr = &Expr{Type: CastExpr, Value: target, Args: []*Expr{expr}}
+	}
+	if err != nil {
+		return nil, next, err
+	}
+	return expr, next, nil
+}
+
+func parsePrimary(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	if start >= end {
+		return nil, start, errors.New("неверный синтаксис выражения: отсутствует значение")
+	}
+	token := tokens[start]
+	upperToken := strings.ToUpper(token)
+
+	if token == "(" {
+		expr, next, err := parseExpression(db, tokens, start+1, end)
+		if err != nil {
+			return nil, next, err
+		}
+		if next >= end || tokens[next] != ")" {
+			return nil, next, errors.New("неверный синтаксис выражения: отсутствует закрывающая скобка")
+		}
+		return expr, next + 1, nil
+	}
+	if upperToken == "NULL" {
+		return &Expr{Type: LiteralExpr, Value: nil}, start + 1, nil
+	}
+	if upperToken == "DEFAULT" {
+		return &Expr{Type: DefaultExpr}, start + 1, nil
+	}
+	if upperToken == "CAST" && start+1 < end && tokens[start+1] == "(" {
+		return parseCast(db, tokens, start+2, end)
+	}
+	if upperToken == "EXTRACT" && start+1 < end && tokens[start+1] == "(" {
+		return parseExtract(db, tokens, start+2, end)
+	}
+	if upperToken == "CASE" {
+		return parseCase(db, tokens, start+1, end)
+	}
+	if upperToken == "POSITION" && start+1 < end && tokens[start+1] == "(" {
+		return parsePosition(db, tokens, start+2, end)
+	}
+	if dataType, ok := typedLiterals[upperToken]; ok && start+1 < end && isQuoted(tokens[start+1]) {
+		text := tokens[start+1]
+		value, err := coerceValue(text[1:len(text)-1], dataType)
+		if err != nil {
+			return nil, start, err
+		}
+		return &Expr{Type: LiteralExpr, Value: value}, start + 2, nil
+	}
+	if value, ok, err := binaryLiteral(token); ok {
+		if err != nil {
+			return nil, start, err
+		}
+		return &Expr{Type: LiteralExpr, Value: value}, start + 1, nil
+	}
+	if name, ok := niladicFunctions[upperToken]; ok {
+		expr := &Expr{Type: FunctionExpr, Name: name}
+		return expr, start + 1, db.bindFunction(expr)
+	}
+	if value, ok := parseLiteralToken(token); ok {
+		return &Expr{Type: LiteralExpr, Value: value}, start + 1, nil
+	}
+	if start+1 < end && tokens[start+1] == "(" {
+		expr := &Expr{Type: FunctionExpr, Name: strings.ToLower(token)}
+		current := start + 2
+		if current < end && tokens[current] == ")" {
+			return finishCall(db, tokens, expr, current+1, end)
+		}
+		for current < end {
+			arg, next, err := parseExpression(db, tokens, current, end)
+			if err != nil {
+				return nil, next, err
+			}
+			expr.Args = append(expr.Args, arg)
+			if next >= end {
+				break
+			}
+			if tokens[next] == ")" {
+				return finishCall(db, tokens, expr, next+1, end)
+			}
+			if tokens[next] != "," {
+				return nil, next, fmt.Errorf("неверный синтаксис вызова функции '%s': неожиданный токен '%s'", token, tokens[next])
+			}
+			current = next + 1
+		}
+		return nil, current, fmt.Errorf("неверный синтаксис вызова функции '%s': отсутствует закрывающая скобка", token)
+	}
+	return &Expr{Type: ColumnExpr, Name: token}, start + 1, nil
+}
+
+// finishCall завершает разбор вызова функции: проверяет вызов или, если за ним
+// следует OVER, разбирает оконную функцию.
+func finishCall(db *Database, tokens []string, call *Expr, next, end int) (*Expr, int, error) {
+	if next < end && strings.ToUpper(tokens[next]) == "OVER" {
+		return parseOver(db, tokens, call, next+1, end)
+	}
+	return call, next, db.bindFunction(call)
+}
+
+// parseCast разбирает CAST(expr AS type) начиная с токена после открывающей скобки.
+func parseCast(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	arg, next, err := parseExpression(db, tokens, start, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if next+1 >= end || strings.ToUpper(tokens[next]) != "AS" {
+		return nil, next, errors.New("неверный синтаксис CAST: ожидалось CAST(выражение AS тип)")
+	}
+	target, next, err := parseColumnType(tokens[:end], next+1)
+	if err != nil {
+		return nil, next, err
+	}
+	if next >= end || tokens[next] != ")" {
+		return nil, next, errors.New("неверный синтаксис CAST: ожидалось CAST(выражение AS тип)")
+	}
+	return &Expr{Type: CastExpr, Value: target, Args: []*Expr{arg}}, next + 1, nil
+}
+
+// parseExtract разбирает EXTRACT(field FROM expr) в вызов date_part.
+func parseExtract(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	if start+1 >= end || strings.ToUpper(tokens[start+1]) != "FROM" {
+		return nil, start, errors.New("неверный синтаксис EXTRACT: ожидалось EXTRACT(поле FROM выражение)")
+	}
+	field := strings.Trim(tokens[start], "'")
+	arg, next, err := parseExpression(db, tokens, start+2, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if next >= end || tokens[next] != ")" {
+		return nil, next, errors.New("неверный синтаксис EXTRACT: отсутствует закрывающая скобка")
+	}
+	fieldExpr := &Expr{Type: LiteralExpr, Value: strings.ToLower(field)}
+	expr := &Expr{Type: FunctionExpr, Name: "date_part", Args: []*Expr{fieldExpr, arg}}
+	return expr, next + 1, db.bindFunction(expr)
+}
+
+// parseCase разбирает CASE WHEN условие THEN выражение ... [ELSE выражение] END
+// и простую форму CASE x WHEN значение THEN ..., начиная с токена после CASE.
+// Простая форма сводится к условиям x = значение.
+func parseCase(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	expr := &Expr{Type: CaseExpr}
+	var operand *Expr
+	next := start
+	if next < end && strings.ToUpper(tokens[next]) != "WHEN" {
+		var err error
+		operand, next, err = parseExpression(db, tokens, next, end)
+		if err != nil {
+			return nil, next, err
+		}
+	}
+	var conditions []*Condition
+	for next < end && strings.ToUpper(tokens[next]) == "WHEN" {
+		thenIndex := findCaseKeyword(tokens, next+1, end, "THEN")
+		if thenIndex >= end {
+			return nil, next, errors.New("неверный синтаксис CASE: отсутствует THEN")
+		}
+		var cond *Condition
+		var after int
+		var err error
+		if operand == nil {
+			cond, after, err = parseCondition(db, tokens, next+1, thenIndex)
+		} else {
+			cond, after, err = caseValueCondition(db, operand, tokens, next+1, thenIndex)
+		}
+		if err != nil {
+			return nil, after, err
+		}
+		if cond == nil || after != thenIndex {
+			return nil, after, fmt.Errorf("неверный синтаксис CASE: неожиданный токен '%s'", tokens[after])
+		}
+		result, resultEnd, err := parseExpression(db, tokens, thenIndex+1, end)
+		if err != nil {
+			return nil, resultEnd, err
+		}
+		conditions = append(conditions, cond)
+		expr.Args = append(expr.Args, result)
+		next = resultEnd
+	}
+	if len(conditions) == 0 {
+		return nil, next, errors.New("неверный синтаксис CASE: ожидалось WHEN")
+	}
+	expr.Value = conditions
+	if next < end && strings.ToUpper(tokens[next]) == "ELSE" {
+		result, resultEnd, err := parseExpression(db, tokens, next+1, end)
+		if err != nil {
+			return nil, resultEnd, err
+		}
+		expr.Args = append(expr.Args, result)
+		next = resultEnd
+	}
+	if next >= end || strings.ToUpper(tokens[next]) != "END" {
+		return nil, next, errors.New("неверный синтаксис CASE: отсутствует END")
+	}
+	return expr, next + 1, nil
+}
+
+// caseValueCondition разбирает значение ветки простой формы CASE в условие operand = значение.
+func caseValueCondition(db *Database, operand *Expr, tokens []string, start, end int) (*Condition, int, error) {
+	valueExpr, next, err := parseExpression(db, tokens, start, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if !isConstantExpression(valueExpr) {
+		return nil, next, fmt.Errorf("неверный синтаксис CASE: значение WHEN '%s' должно быть константой", exprString(valueExpr))
+	}
+	value, err := evaluateExpression(nil, nil, valueExpr)
+	if err != nil {
+		return nil, next, err
+	}
+	cond := &Condition{Type: Simple, Column: operand.Name, Operator: "=", Value: value}
+	if operand.Type != ColumnExpr {
+		cond.Column, cond.Expr = exprString(operand), operand
+	}
+	return cond, next, nil
+}
+
+// findCaseKeyword ищет ключевое слово ветки CASE вне скобок и вложенных CASE ... END.
+func findCaseKeyword(tokens []string, start, end int, keyword string) int {
+	depth := 0
+	for i := start; i < end; i++ {
+		switch upperToken := strings.ToUpper(tokens[i]); {
+		case upperToken == "(" || upperToken == "CASE":
+			depth++
+		case upperToken == ")" || upperToken == "END":
+			depth--
+		case depth == 0 && upperToken == keyword:
+			return i
+		}
+	}
+	return end
+}
+
+// parsePosition разбирает POSITION(substring IN string) и POSITION(substring, string)
+// в вызов position.
+func parsePosition(db *Database, tokens []string, start, end int) (*Expr, int, error) {
+	substring, next, err := parseExpression(db, tokens, start, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if next >= end || (strings.ToUpper(tokens[next]) != "IN" && tokens[next] != ",") {
+		return nil, next, errors.New("неверный синтаксис POSITION: ожидалось POSITION(подстрока IN строка)")
+	}
+	str, next, err := parseExpression(db, tokens, next+1, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if next >= end || tokens[next] != ")" {
+		return nil, next, errors.New("неверный синтаксис POSITION: отсутствует закрывающая скобка")
+	}
+	expr := &Expr{Type: FunctionExpr, Name: "position", Args: []*Expr{substring, str}}
+	return expr, next + 1, db.bindFunction(expr)
+}
+
+func isQuoted(token string) bool {
+	return len(token) >= 2 && strings.HasPrefix(token, "'") && strings.HasSuffix(token, "'")
+}
+
+// isConstantExpression сообщает, что выражение не ссылается на столбцы.
+func isConstantExpression(expr *Expr) bool {
+	switch expr.Type {
+	case ColumnExpr, DefaultExpr, WindowExpr:
+		return false
+	case CaseExpr:
+		for _, cond := range expr.Value.([]*Condition) {
+			if len(conditionColumns(cond, nil)) > 0 {
+				return false
+			}
+		}
+	case ConditionExpr:
+		return len(conditionColumns(expr.Value.(*Condition), nil)) == 0
+	}
+	for _, arg := range expr.Args {
+		if !isConstantExpression(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseExpressionString разбирает выражение, сохранённое в схеме (DEFAULT, индекс).
+func parseExpressionString(db *Database, text string) (*Expr, error) {
+	tokens := tokenize(text)
+	expr, next, err := parseExpression(db, tokens, 0, len(tokens))
+	if err != nil {
+		return nil, err
+	}
+	if next != len(tokens) {
+		return nil, fmt.Errorf("неверный синтаксис выражения: неожиданный токен '%s'", tokens[next])
+	}
+	return expr, nil
+}
+
+func parseLiteralToken(token string) (interface{}, bool) {
+	if isQuoted(token) {
+		return token[1 : len(token)-1], true
+	}
+	if intVal, err := strconv.Atoi(token); err == nil {
+		return intVal, true
+	}
+	if floatVal, err := strconv.ParseFloat(token, 64); err == nil {
+		// Число, которое FLOAT не хранит точно, остаётся DECIMAL.
+		if d, ok := parseDecimal(token); ok {
+			if exact, _ := decimalFromFloat(floatVal); exact.Cmp(d) != 0 {
+				return d, true
+			}
+		}
+		return floatVal, true
+	}
+	switch strings.ToUpper(token) {
+	case "TRUE":
+		return true, true
+	case "FALSE":
+		return false, true
+	}
+	return nil, false
+}
+
+func evaluateExpression(row []interface{}, columnNames []string, expr *Expr) (interface{}, error) {
+	switch expr.Type {
+	case LiteralExpr:
+		return expr.Value, nil
+	case ColumnExpr:
+		colIndex := findColumnIndex(columnNames, expr.Name)
+		if colIndex == -1 {
+			return nil, fmt.Errorf("столбец '%s' не найден в результате", expr.Name)
+		}
+		if colIndex >= len(row) {
+			return nil, nil
+		}
+		return row[colIndex], nil
+	case WindowExpr:
+		// Значения оконных функций вычисляются заранее и дописываются к строке.
+		spec := expr.Value.(*windowSpec)
+		index := -1
+		if spec.column != "" {
+			index = findColumnIndex(columnNames, spec.column)
+		}
+		if index == -1 || index >= len(row) {
+			return nil, fmt.Errorf("оконная функция '%s' допустима только в списке выборки и ORDER BY", expr.Name)
+		}
+		return row[index], nil
+	case FunctionExpr:
+		args := make([]interface{}, len(expr.Args))
+		for i, arg := range expr.Args {
+			value, err := evaluateExpression(row, columnNames, arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = value
+		}
+		switch fn := expr.Value.(type) {
+		case *builtinFunction:
+			return fn.call(expr.Name, args)
+		case *aggregateFunction:
+			return nil, fmt.Errorf("агрегатная функция '%s' недопустима в этом контексте", expr.Name)
+		}
+		return nil, fmt.Errorf("неизвестная функция '%s'", expr.Name)
+	case CastExpr:
+		value, err := evaluateExpression(row, columnNames, expr.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return castValue(value, expr.Value.(Column))
+	case BinaryExpr:
+		left, err := evaluateExpression(row, columnNames, expr.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		right, err := evaluateExpression(row, columnNames, expr.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		if isJSONOperator(expr.Name) {
+			return applyJSONOperator(expr.Name, left, right)
+		}
+		if expr.Name == "||" {
+			return concatValues(left, right)
+		}
+		return applyArithmetic(expr.Name, left, right)
+	case UnaryExpr:
+		value, err := evaluateExpression(row, columnNames, expr.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		return negateValue(value)
+	case CaseExpr:
+		for i, cond := range expr.Value.([]*Condition) {
+			match, err := evaluateCondition(row, columnNames, cond)
+			if err != nil {
+				return nil, err
+			}
+			if match {
+				return evaluateExpression(row, columnNames, expr.Args[i])
+			}
+		}
+		if len(expr.Args) > len(expr.Value.([]*Condition)) {
+			return evaluateExpression(row, columnNames, expr.Args[len(expr.Args)-1])
+		}
+		return nil, nil
+	case ConditionExpr:
+		result, unknown, err := evaluateConditionNull(row, columnNames, expr.Value.(*Condition))
+		if err != nil || unknown {
+			return nil, err
+		}
+		return result, nil
+	case DefaultExpr:
+		return nil, errors.New("DEFAULT допустимо только в VALUES и SET")
+	default:
+		return nil, errors.New("неизвестный тип выражения")
+	}
+}
+
+// applyArithmetic вычисляет a op b для операторов + - * / %; NULL в любом операнде даёт NULL.
+// Деление целых, как в PostgreSQL, отбрасывает дробную часть.
+func applyArithmetic(op string, a, b interface{}) (interface{}, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	if result, handled, err := temporalArithmetic(op, a, b); handled {
+		return result, err
+	}
+	if pa, pb, ok := promoteNumeric(a, b); ok {
+		if (op == "/" || op == "%") && isZero(pb) {
+			return nil, errors.New("деление на ноль")
+		}
+		switch av := pa.(type) {
+		case int:
+			return intArithmetic(op, av, pb.(int))
+		case float64:
+			bv := pb.(float64)
+			switch op {
+			case "+":
+				return av + bv, nil
+			case "-":
+				return av - bv, nil
+			case "*":
+				return av * bv, nil
+			case "/":
+				return av / bv, nil
+			case "%":
+				return math.Mod(av, bv), nil
+			}
+		case Decimal:
+			bv := pb.(Decimal)
+			switch op {
+			case "+":
+				return av.add(bv), nil
+			case "-":
+				return av.sub(bv), nil
+			case "*":
+				return av.mul(bv), nil
+			case "/":
+				return av.quo(bv), nil
+			case "%":
+				return av.rem(bv), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("оператор '%s' не применим к типам %s и %s", op, typeNameOf(a), typeNameOf(b))
+}
+
+// intArithmetic вычисляет a op b для INTEGER; переполнение, как и при вставке
+// слишком большого значения, считается ошибкой.
+func intArithmetic(op string, a, b int) (interface{}, error) {
+	var result int
+	overflow := false
+	switch op {
+	case "+":
+		result = a + b
+		overflow = (b > 0 && result < a) || (b < 0 && result > a)
+	case "-":
+		result = a - b
+		overflow = (b < 0 && result < a) || (b > 0 && result > a)
+	case "*":
+		result = a * b
+		overflow = a != 0 && (result/a != b || (a == -1 && b == math.MinInt))
+	case "/":
+		overflow = a == math.MinInt && b == -1
+		if !overflow {
+			result = a / b
+		}
+	case "%":
+		if b == -1 {
+			return 0, nil
+		}
+		return a % b, nil
+	}
+	if overflow {
+		return nil, fmt.Errorf("значение %d %s %d вне диапазона INTEGER", a, op, b)
+	}
+	return result, nil
+}
+
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case int:
+		return v == 0
+	case float64:
+		return v == 0
+	case Decimal:
+		return v.coefficient().Sign() == 0
+	}
+	return false
+}
+
+// negateValue вычисляет унарный минус для чисел и интервалов.
+func negateValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case int:
+		return -v, nil
+	case float64:
+		return -v, nil
+	case Decimal:
+		return v.neg(), nil
+	case Interval:
+		return v.negate(), nil
+	}
+	return nil, fmt.Errorf("унарный минус не применим к типу %s", typeNameOf(value))
+}
+
+// concatValues вычисляет a || b: конкатенацию строк (значение другого типа
+// записывается текстом) или значений BYTEA. NULL в любом операнде даёт NULL.
+func concatValues(a, b interface{}) (interface{}, error) {
+	if a == nil || b == nil {
+		return nil, nil
+	}
+	if ab, ok := a.(Bytes); ok {
+		if bb, ok := b.(Bytes); ok {
+			return ab + bb, nil
+		}
+	}
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if !aString && !bString {
+		return nil, fmt.Errorf("оператор '||' не применим к типам %s и %s", typeNameOf(a), typeNameOf(b))
+	}
+	return fmt.Sprintf("%v%v", a, b), nil
+}
+
+// exprString восстанавливает текст выражения; используется как имя столбца результата.
+func exprString(expr *Expr) string {
+	switch expr.Type {
+	case LiteralExpr:
+		switch v := expr.Value.(type) {
+		case nil:
+			return "NULL"
+		case string:
+			return "'" + v + "'"
+		}
+		return fmt.Sprintf("%v", expr.Value)
+	case ColumnExpr:
+		return expr.Name
+	case CastExpr:
+		return fmt.Sprintf("CAST(%s AS %s)", exprString(expr.Args[0]), expr.Value.(Column).TypeName())
+	case BinaryExpr:
+		// Скобки нужны операнду с более слабым оператором, а справа — и с равным.
+		precedence := operatorPrecedence(expr.Name)
+		left, right := exprString(expr.Args[0]), exprString(expr.Args[1])
+		if expr.Args[0].Type == BinaryExpr && operatorPrecedence(expr.Args[0].Name) < precedence {
+			left = "(" + left + ")"
+		}
+		if expr.Args[1].Type == BinaryExpr && operatorPrecedence(expr.Args[1].Name) <= precedence {
+			right = "(" + right + ")"
+		}
+		return left + " " + expr.Name + " " + right
+	case CaseExpr:
+		var b strings.Builder
+		b.WriteString("CASE")
+		conditions := expr.Value.([]*Condition)
+		for i, cond := range conditions {
+			b.WriteString(" WHEN " + conditionString(cond) + " THEN " + exprString(expr.Args[i]))
+		}
+		if len(expr.Args) > len(conditions) {
+			b.WriteString(" ELSE " + exprString(expr.Args[len(expr.Args)-1]))
+		}
+		b.WriteString(" END")
+		return b.String()
+	case UnaryExpr:
+		if expr.Args[0].Type == BinaryExpr {
+			return expr.Name + "(" + exprString(expr.Args[0]) + ")"
+		}
+		return expr.Name + exprString(expr.Args[0])
+	case DefaultExpr:
+		return "DEFAULT"
+	case ConditionExpr:
+		return conditionString(expr.Value.(*Condition))
+	case WindowExpr:
+		return exprString(&Expr{Type: FunctionExpr, Name: expr.Name, Args: expr.Args}) + " " + windowString(expr.Value.(*windowSpec))
+	}
+	args := make([]string, len(expr.Args))
+	for i, arg := range expr.Args {
+		args[i] = exprString(arg)
+	}
+	return expr.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// expressionType определяет тип выражения по типам столбцов; ok = false, если
+// тип можно узнать только по вычисленным значениям.
+func expressionType(expr *Expr, columnNames []string, columnTypes []DataType) (DataType, bool) {
+	switch expr.Type {
+	case LiteralExpr:
+		return valueType(expr.Value)
+	case ColumnExpr:
+		if index := findColumnIndex(columnNames, expr.Name); index != -1 {
+			return columnTypes[index], true
+		}
+	case CastExpr:
+		return expr.Value.(Column).Type, true
+	case CaseExpr:
+		for _, arg := range expr.Args {
+			if dataType, ok := expressionType(arg, columnNames, columnTypes); ok {
+				return dataType, true
+			}
+		}
+	case WindowExpr:
+		return windowType(expr, columnNames, columnTypes)
+	case ConditionExpr:
+		return BOOLEAN, true
+	case FunctionExpr:
+		if fn, ok := expr.Value.(*builtinFunction); ok && fn.typed {
+			return fn.returnType, true
+		}
+		fn, ok := expr.Value.(*aggregateFunction)
+		if !ok || len(expr.Args) != 1 {
+			return 0, false
+		}
+		if expr.Name == "count" {
+			return INTEGER, true
+		}
+		if argType, ok := expressionType(expr.Args[0], columnNames, columnTypes); ok {
+			return fn.resultType(argType), true
+		}
+	}
+	return 0, false
+}
+
+// expressionColumns собирает имена столбцов, на которые ссылается выражение.
+func expressionColumns(expr *Expr, names []string) []string {
+	if expr.Type == ColumnExpr {
+		return append(names, expr.Name)
+	}
+	if expr.Type == CaseExpr {
+		for _, cond := range expr.Value.([]*Condition) {
+			names = conditionColumns(cond, names)
+		}
+	}
+	if expr.Type == ConditionExpr {
+		return conditionColumns(expr.Value.(*Condition), names)
+	}
+	if expr.Type == WindowExpr {
+		for _, operand := range windowOperands(expr) {
+			names = expressionColumns(operand, names)
+		}
+		return names
+	}
+	for _, arg := range expr.Args {
+		names = expressionColumns(arg, names)
+	}
+	return names
+}
+
+// conditionColumns собирает имена столбцов, на которые ссылается условие.
+func conditionColumns(cond *Condition, names []string) []string {
+	if cond == nil {
+		return names
+	}
+	if cond.Type == Compound {
+		return conditionColumns(cond.Right, conditionColumns(cond.Left, names))
+	}
+	if cond.ValueExpr != nil {
+		names = expressionColumns(cond.ValueExpr, names)
+	}
+	if cond.Expr != nil {
+		return expressionColumns(cond.Expr, names)
+	}
+	return append(names, cond.Column)
+}
+
+// conditionString восстанавливает текст условия.
+func conditionString(cond *Condition) string {
+	if cond.Type == Simple {
+		if strings.HasPrefix(cond.Operator, "IS ") {
+			return cond.Column + " " + cond.Operator
+		}
+		if cond.ValueExpr != nil {
+			return cond.Column + " " + cond.Operator + " " + exprString(cond.ValueExpr)
+		}
+		return cond.Column + " " + cond.Operator + " " + exprString(&Expr{Type: LiteralExpr, Value: cond.Value})
+	}
+	if cond.LogicalOp == "NOT" {
+		return "NOT (" + conditionString(cond.Left) + ")"
+	}
+	left, right := conditionString(cond.Left), conditionString(cond.Right)
+	if cond.LogicalOp == "AND" {
+		if cond.Left.Type == Compound && cond.Left.LogicalOp == "OR" {
+			left = "(" + left + ")"
+		}
+		if cond.Right.Type == Compound && cond.Right.LogicalOp == "OR" {
+			right = "(" + right + ")"
+		}
+	}
+	return left + " " + cond.LogicalOp + " " + right
+}
+
+// inferColumnType определяет тип столбца результата по первому значению не NULL.
+func inferColumnType(rows [][]interface{}, column int) DataType {
+	for _, row := range rows {
+		if dataType, ok := valueType(row[column]); ok {
+			return dataType
+		}
+	}
+	return STRING
+}
+
+func findColumnIndex(columnNames []string, name string) int {
+	for i, col := range columnNames {
+		if strings.ToLower(col) == strings.ToLower(name) || (strings.Contains(col, ".") && strings.ToLower(col[strings.LastIndex(col, ".")+1:]) == strings.ToLower(name)) {
+			return i
+		}
+	}
+	return -1
+}
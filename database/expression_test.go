@@ -0,0 +1,141 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestIntegerArithmeticOverflow(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE bounds (id INTEGER, n INTEGER)",
+		fmt.Sprintf("INSERT INTO bounds VALUES (1, %d)", math.MaxInt),
+		fmt.Sprintf("INSERT INTO bounds VALUES (2, %d)", math.MinInt),
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT n - 1 FROM bounds WHERE id = 1")); got != fmt.Sprintf("[[%d]]", math.MaxInt-1) {
+		t.Fatalf("MaxInt - 1: %s", got)
+	}
+	for _, query := range []string{
+		"SELECT n + 1 FROM bounds WHERE id = 1",
+		"SELECT n - 1 FROM bounds WHERE id = 2",
+		"SELECT n * 2 FROM bounds WHERE id = 1",
+		"SELECT n * -1 FROM bounds WHERE id = 2",
+		"SELECT n / -1 FROM bounds WHERE id = 2",
+		"UPDATE bounds SET n = n + 1 WHERE id = 1",
+	} {
+		mustFail(t, db, query)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT n FROM bounds WHERE id = 1")); got != fmt.Sprintf("[[%d]]", math.MaxInt) {
+		t.Fatalf("значение изменилось: %s", got)
+	}
+}
+
+func TestBooleanValueExpressions(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE f (id INTEGER, active BOOLEAN, a INTEGER, b INTEGER)",
+		"INSERT INTO f VALUES (1, TRUE, 1, 2)",
+		"INSERT INTO f VALUES (2, NULL, 3, NULL)",
+		"UPDATE f SET active = NOT active WHERE id = 1",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, active, a < b, (a > 0) AS pos, a IS NULL OR b IS NULL FROM f ORDER BY id")); got != "[[1 false true true false] [2 <nil> <nil> true true]]" {
+		t.Fatalf("логические выражения: %s", got)
+	}
+	result, err := db.Query("UPDATE f SET active = a < b WHERE id = 1 RETURNING active, NOT active AS inactive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(result.Rows); got != "[[true false]]" || result.Columns[1].Type != BOOLEAN {
+		t.Fatalf("RETURNING: %v, столбцы %+v", got, result.Columns)
+	}
+	mustFail(t, db, "UPDATE f SET active = NOT WHERE id = 1")
+	mustFail(t, db, "SELECT a < FROM f")
+}
+
+func TestSelectListExpressions(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE orders (id INTEGER, product_name STRING, price FLOAT, quantity INTEGER)",
+		"INSERT INTO orders VALUES (1, 'Laptop', 999.5, 2)",
+		"INSERT INTO orders VALUES (2, 'Mouse', 20, 7)",
+		"INSERT INTO orders VALUES (3, 'Cable', 5.25, NULL)",
+	)
+	cases := map[string]string{
+		"SELECT 2 + 3 * 4, (2 + 3) * 4, 7 / 2, 7.0 / 2, 7 % 3, -(1 - 4)":                         "[[14 20 3 3.5 1 3]]",
+		"SELECT product_name, price * quantity AS total FROM orders ORDER BY total DESC":         "[[Cable <nil>] [Laptop 1999] [Mouse 140]]",
+		"SELECT product_name || ' x' || quantity label FROM orders WHERE id = 2":                 "[[Mouse x7]]",
+		"SELECT id FROM orders WHERE price * 2 > quantity * 10 OR quantity IS NULL ORDER BY -id": "[[3] [1]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "UPDATE orders SET quantity = quantity * 2 + 1 WHERE id = 2 RETURNING quantity")); got != "[[15]]" {
+		t.Fatalf("UPDATE SET: %s", got)
+	}
+	mustFail(t, db, "SELECT quantity / 0 FROM orders")
+	mustFail(t, db, "SELECT price + product_name FROM orders")
+
+	result, err := db.Query("SELECT price * quantity, id + 1 AS next FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Columns[0].Name != "price * quantity" || result.Columns[1].Name != "next" || result.Columns[1].Type != INTEGER {
+		t.Fatalf("столбцы результата: %+v", result.Columns)
+	}
+}
+
+func TestCaseExpressions(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING, age INTEGER, status INTEGER)",
+		"INSERT INTO users VALUES (1, 'Ann', 12, 1)",
+		"INSERT INTO users VALUES (2, 'Bob', 40, 2)",
+		"INSERT INTO users VALUES (3, 'Cid', 70, 3)",
+		"INSERT INTO users VALUES (4, 'Dan', NULL, NULL)",
+		"INSERT INTO users VALUES (5, 'Eve', 30, 1)",
+	)
+	cases := map[string]string{
+		"SELECT id, CASE WHEN age < 18 THEN 'kid' WHEN age < 65 THEN 'adult' ELSE 'senior' END FROM users ORDER BY id":                                                   "[[1 kid] [2 adult] [3 senior] [4 senior] [5 adult]]",
+		"SELECT CASE status WHEN 1 THEN 'new' WHEN 2 THEN 'paid' END FROM users ORDER BY id":                                                                             "[[new] [paid] [<nil>] [<nil>] [new]]",
+		"SELECT CASE WHEN age IS NULL OR NOT age > 18 THEN 0 ELSE age * 2 END AS x FROM users ORDER BY x DESC, id":                                                       "[[140] [80] [60] [0] [0]]",
+		"SELECT CASE WHEN age < 18 THEN 'kid' ELSE 'other' END AS bracket, count(*) FROM users GROUP BY CASE WHEN age < 18 THEN 'kid' ELSE 'other' END ORDER BY bracket": "[[kid 1] [other 4]]",
+		"SELECT id FROM users WHERE CASE WHEN status = 1 THEN age ELSE 0 END > 20":                                                                                       "[[5]]",
+		"SELECT id FROM users ORDER BY CASE status WHEN 3 THEN 0 ELSE 1 END, id LIMIT 2":                                                                                 "[[3] [1]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	mustExec(t, db, "UPDATE users SET status = CASE WHEN age >= 65 THEN 9 ELSE status END")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, status FROM users WHERE status >= 3 ORDER BY id")); got != "[[3 9]]" {
+		t.Fatalf("после UPDATE: %s", got)
+	}
+	mustFail(t, db, "SELECT CASE WHEN age > 1 THEN 'x' FROM users")
+}
+
+func TestCaseConditionsInGroupedQuery(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE posts (id INTEGER, author STRING, rating INTEGER)",
+		"INSERT INTO posts VALUES (1, 'ann', 2)",
+		"INSERT INTO posts VALUES (2, 'bob', 3)",
+		"INSERT INTO posts VALUES (3, 'bob', NULL)",
+	)
+	cases := map[string]string{
+		"SELECT author, CASE WHEN author = 'ann' THEN sum(id) END FROM posts GROUP BY author ORDER BY author":                                   "[[ann 1] [bob <nil>]]",
+		"SELECT author, CASE WHEN sum(rating) > 2 THEN 'many' ELSE 'few' END, count(*) > 1 AS multi FROM posts GROUP BY author ORDER BY author": "[[ann few false] [bob many true]]",
+		"SELECT CASE WHEN count(*) > 2 THEN 'big' ELSE 'small' END FROM posts":                                                                  "[[big]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT CASE WHEN rating > 2 THEN 1 END, count(*) FROM posts GROUP BY author")
+	mustFail(t, db, "UPDATE posts SET rating = 1 RETURNING CASE WHEN count(*) > 1 THEN 1 END")
+}
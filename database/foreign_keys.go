@@ -0,0 +1,434 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	NoAction   = "NO ACTION"
+	Restrict   = "RESTRICT"
+	Cascade    = "CASCADE"
+	SetNull    = "SET NULL"
+	SetDefault = "SET DEFAULT"
+)
+
+func (db *Database) validateForeignKeys(tableName string, columns []Column, constraints []Constraint) error {
+	for i := range constraints {
+		c := &constraints[i]
+		if c.Type != ForeignKeyConstraint {
+			continue
+		}
+		refTableName := strings.ToLower(c.RefTable)
+		var refColumns []Column
+		var refConstraints []Constraint
+		if refTableName == tableName {
+			refColumns, refConstraints = columns, constraints
+		} else {
+			parent, exists := db.Tables[refTableName]
+			if !exists {
+				return fmt.Errorf("таблица '%s', на которую ссылается ограничение '%s', не существует", c.RefTable, c.Name)
+			}
+			refColumns, refConstraints = parent.Columns, parent.Constraints
+		}
+		c.RefTable = refTableName
+
+		if len(c.RefColumns) == 0 {
+			for _, rc := range refConstraints {
+				if rc.Type == PrimaryKeyConstraint {
+					c.RefColumns = rc.Columns
+				}
+			}
+			if len(c.RefColumns) == 0 {
+				return fmt.Errorf("у таблицы '%s' нет первичного ключа для ограничения '%s'", c.RefTable, c.Name)
+			}
+		}
+		if len(c.RefColumns) != len(c.Columns) {
+			return fmt.Errorf("количество столбцов ограничения '%s' не совпадает с количеством столбцов в таблице '%s'", c.Name, c.RefTable)
+		}
+		for j, refName := range c.RefColumns {
+			refCol := findColumn(refColumns, refName)
+			if refCol == nil {
+				return fmt.Errorf("столбец '%s' не найден в таблице '%s'", refName, c.RefTable)
+			}
+			col := findColumn(columns, c.Columns[j])
+			if !typesComparable(col.Type, refCol.Type) {
+				return fmt.Errorf("типы столбцов '%s' (%s) и '%s.%s' (%s) ограничения '%s' несовместимы", col.Name, col.Type, c.RefTable, refCol.Name, refCol.Type, c.Name)
+			}
+		}
+		if !hasUniqueOn(refConstraints, c.RefColumns) {
+			return fmt.Errorf("для столбцов (%s) таблицы '%s' нет ограничения PRIMARY KEY или UNIQUE", strings.Join(c.RefColumns, ", "), c.RefTable)
+		}
+		if c.OnDelete == "" {
+			c.OnDelete = NoAction
+		}
+		if c.OnUpdate == "" {
+			c.OnUpdate = NoAction
+		}
+	}
+	return nil
+}
+
+func findColumn(columns []Column, name string) *Column {
+	for i := range columns {
+		if strings.ToLower(columns[i].Name) == strings.ToLower(name) {
+			return &columns[i]
+		}
+	}
+	return nil
+}
+
+func typesComparable(a, b DataType) bool {
+	if a == b {
+		return true
+	}
+	return isNumericType(a) && isNumericType(b)
+}
+
+func sameColumnSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if strings.ToLower(x) == strings.ToLower(y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func hasUniqueOn(constraints []Constraint, columns []string) bool {
+	for _, c := range constraints {
+		if (c.Type == PrimaryKeyConstraint || c.Type == UniqueConstraint) && sameColumnSet(c.Columns, columns) {
+			return true
+		}
+	}
+	return false
+}
+
+func columnValues(table *Table, row []interface{}, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, colName := range columns {
+		values[i] = row[getColumnIndex(table, colName)]
+	}
+	return values
+}
+
+// parentHasKey ищет значения values столбцов columns через уникальный индекс родительской таблицы.
+func parentHasKey(parent *Table, columns []string, values []interface{}) bool {
+	for _, idx := range parent.Indexes {
+		if !idx.Unique || !sameColumnSet(idx.Columns, columns) {
+			continue
+		}
+		ordered := make([]interface{}, len(idx.Columns))
+		for i, idxCol := range idx.Columns {
+			for j, colName := range columns {
+				if strings.ToLower(idxCol) == strings.ToLower(colName) {
+					ordered[i] = values[j]
+				}
+			}
+		}
+		return len(idx.lookup(ordered)) > 0
+	}
+	return false
+}
+
+func (db *Database) isDeferred(tableName string, c *Constraint) bool {
+	tx := db.transaction
+	if tx == nil || tx.implicit || !c.Deferrable {
+		return false
+	}
+	if deferred, ok := tx.constraintModes[constraintKey(tableName, c.Name)]; ok {
+		return deferred
+	}
+	if deferred, ok := tx.constraintModes["*"]; ok {
+		return deferred
+	}
+	return c.InitiallyDeferred
+}
+
+func (db *Database) deferCheck(tableName string, c *Constraint) {
+	if db.transaction.deferredChecks == nil {
+		db.transaction.deferredChecks = make(map[string]bool)
+	}
+	db.transaction.deferredChecks[constraintKey(tableName, c.Name)] = true
+}
+
+func foreignKeyError(table *Table, c *Constraint, values []interface{}) error {
+	return &ConstraintError{
+		Table:      table.Name,
+		Constraint: c.Name,
+		Message:    fmt.Sprintf("ключ (%s)=(%s) отсутствует в таблице '%s'", strings.Join(c.Columns, ", "), formatKeyValues(values), c.RefTable),
+	}
+}
+
+// checkForeignKeys проверяет, что новые строки дочерней таблицы ссылаются на существующие строки.
+func (db *Database) checkForeignKeys(table *Table, rows [][]interface{}) error {
+	for i := range table.Constraints {
+		c := &table.Constraints[i]
+		if c.Type != ForeignKeyConstraint {
+			continue
+		}
+		parent, exists := db.Tables[c.RefTable]
+		if !exists {
+			return fmt.Errorf("таблица '%s', на которую ссылается ограничение '%s', не существует", c.RefTable, c.Name)
+		}
+		for _, row := range rows {
+			values := columnValues(table, row, c.Columns)
+			if hasNullValue(values) || parentHasKey(parent, c.RefColumns, values) {
+				continue
+			}
+			if db.isDeferred(table.Name, c) {
+				db.deferCheck(table.Name, c)
+				continue
+			}
+			return foreignKeyError(table, c, values)
+		}
+	}
+	return nil
+}
+
+type foreignKeyRef struct {
+	child      *Table
+	constraint *Constraint
+}
+
+func (db *Database) referencingConstraints(parent *Table) []foreignKeyRef {
+	var names []string
+	for name := range db.Tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var refs []foreignKeyRef
+	for _, name := range names {
+		child := db.Tables[name]
+		for i := range child.Constraints {
+			c := &child.Constraints[i]
+			if c.Type == ForeignKeyConstraint && c.RefTable == parent.Name {
+				refs = append(refs, foreignKeyRef{child: child, constraint: c})
+			}
+		}
+	}
+	return refs
+}
+
+func (db *Database) onReferencedRowsDeleted(parent *Table, deletedRows [][]interface{}) error {
+	for _, ref := range db.referencingConstraints(parent) {
+		keys := make(map[string]bool)
+		for _, row := range deletedRows {
+			values := columnValues(parent, row, ref.constraint.RefColumns)
+			if !hasNullValue(values) {
+				keys[encodeKey(values)] = true
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		err := db.applyReferentialAction(parent, ref, ref.constraint.OnDelete, keys, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) onReferencedRowsUpdated(parent *Table, oldRows, newRows [][]interface{}) error {
+	for _, ref := range db.referencingConstraints(parent) {
+		keys := make(map[string]bool)
+		newValues := make(map[string][]interface{})
+		for i := range oldRows {
+			oldValues := columnValues(parent, oldRows[i], ref.constraint.RefColumns)
+			values := columnValues(parent, newRows[i], ref.constraint.RefColumns)
+			oldKey := encodeKey(oldValues)
+			if hasNullValue(oldValues) || oldKey == encodeKey(values) {
+				continue
+			}
+			keys[oldKey] = true
+			newValues[oldKey] = values
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		err := db.applyReferentialAction(parent, ref, ref.constraint.OnUpdate, keys, newValues)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyReferentialAction выполняет действие ON DELETE/ON UPDATE для строк дочерней таблицы,
+// ссылающихся на ключи keys. newValues задан только для ON UPDATE CASCADE.
+func (db *Database) applyReferentialAction(parent *Table, ref foreignKeyRef, action string, keys map[string]bool, newValues map[string][]interface{}) error {
+	child, c := ref.child, ref.constraint
+	var positions []int
+	var rowKeys []string
+	for pos, row := range child.Rows {
+		values := columnValues(child, row, c.Columns)
+		if hasNullValue(values) {
+			continue
+		}
+		key := encodeKey(values)
+		if keys[key] {
+			positions = append(positions, pos)
+			rowKeys = append(rowKeys, key)
+		}
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	switch action {
+	case Restrict, NoAction:
+		for _, pos := range positions {
+			values := columnValues(child, child.Rows[pos], c.Columns)
+			if action == NoAction && parentHasKey(parent, c.RefColumns, values) {
+				continue
+			}
+			if action == NoAction && db.isDeferred(child.Name, c) {
+				db.deferCheck(child.Name, c)
+				continue
+			}
+			return &ConstraintError{
+				Table:      child.Name,
+				Constraint: c.Name,
+				Message:    fmt.Sprintf("на ключ (%s)=(%s) таблицы '%s' всё ещё есть ссылки", strings.Join(c.RefColumns, ", "), formatKeyValues(values), parent.Name),
+			}
+		}
+		return nil
+	case Cascade:
+		if newValues == nil {
+			return db.deleteRows(child.Name, child, positions)
+		}
+	}
+
+	var changes []rowChange
+	for i, pos := range positions {
+		newRow := make([]interface{}, len(child.Rows[pos]))
+		copy(newRow, child.Rows[pos])
+		for j, colName := range c.Columns {
+			colIndex := getColumnIndex(child, colName)
+			switch action {
+			case Cascade:
+				newRow[colIndex] = newValues[rowKeys[i]][j]
+			case SetNull:
+				newRow[colIndex] = nil
+			case SetDefault:
+				value, err := evaluateDefault(db, child.Columns[colIndex])
+				if err != nil {
+					return err
+				}
+				newRow[colIndex] = value
+			default:
+				return fmt.Errorf("неизвестное действие внешнего ключа '%s'", action)
+			}
+		}
+		if err := validateRow(db, child, newRow); err != nil {
+			return err
+		}
+		changes = append(changes, rowChange{pos: pos, row: newRow})
+	}
+	return db.applyRowChanges(child.Name, child, changes)
+}
+
+func (db *Database) verifyForeignKey(child *Table, c *Constraint) error {
+	parent, exists := db.Tables[c.RefTable]
+	if !exists {
+		return fmt.Errorf("таблица '%s', на которую ссылается ограничение '%s', не существует", c.RefTable, c.Name)
+	}
+	for _, row := range child.Rows {
+		values := columnValues(child, row, c.Columns)
+		if !hasNullValue(values) && !parentHasKey(parent, c.RefColumns, values) {
+			return foreignKeyError(child, c, values)
+		}
+	}
+	return nil
+}
+
+// checkDeferredConstraints проверяет отложенные ограничения при COMMIT.
+func (db *Database) checkDeferredConstraints() error {
+	return db.checkPendingConstraints(nil)
+}
+
+func (db *Database) checkPendingConstraints(filter func(tableName string, c *Constraint) bool) error {
+	var keys []string
+	for key := range db.transaction.deferredChecks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := strings.SplitN(key, ".", 2)
+		child, exists := db.Tables[parts[0]]
+		if !exists {
+			delete(db.transaction.deferredChecks, key)
+			continue
+		}
+		for i := range child.Constraints {
+			c := &child.Constraints[i]
+			if strings.ToLower(c.Name) != parts[1] || c.Type != ForeignKeyConstraint {
+				continue
+			}
+			if filter != nil && !filter(child.Name, c) {
+				continue
+			}
+			if err := db.verifyForeignKey(child, c); err != nil {
+				return err
+			}
+			delete(db.transaction.deferredChecks, key)
+		}
+	}
+	return nil
+}
+
+// SetConstraintsMode реализует SET CONSTRAINTS: names == nil означает ALL.
+// При переключении в IMMEDIATE накопленные проверки выполняются сразу.
+func (db *Database) SetConstraintsMode(names []string, deferred bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.transaction == nil {
+		return fmt.Errorf("SET CONSTRAINTS допустимо только внутри транзакции")
+	}
+	if db.transaction.constraintModes == nil {
+		db.transaction.constraintModes = make(map[string]bool)
+	}
+	selected := make(map[string]bool)
+	if names == nil {
+		db.transaction.constraintModes = map[string]bool{"*": deferred}
+	} else {
+		for _, name := range names {
+			found := false
+			for tableName, table := range db.Tables {
+				for i := range table.Constraints {
+					c := &table.Constraints[i]
+					if strings.ToLower(c.Name) != strings.ToLower(name) {
+						continue
+					}
+					if !c.Deferrable {
+						return fmt.Errorf("ограничение '%s' не является DEFERRABLE", c.Name)
+					}
+					key := constraintKey(tableName, c.Name)
+					db.transaction.constraintModes[key] = deferred
+					selected[key] = true
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("ограничение '%s' не существует", name)
+			}
+		}
+	}
+	if deferred {
+		return nil
+	}
+	return db.checkPendingConstraints(func(tableName string, c *Constraint) bool {
+		return names == nil || selected[constraintKey(tableName, c.Name)]
+	})
+}
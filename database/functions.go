@@ -0,0 +1,493 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type scalarFunction func(args []interface{}) (interface{}, error)
+
+// builtinFunction — функция реестра: реализация и сигнатура. minArgs и maxArgs задают
+// число аргументов (maxArgs == -1 — без ограничения), argTypes — допустимые типы
+// аргументов по позициям (nil — любой тип); последний элемент относится и ко всем
+// следующим аргументам. У функций, зарегистрированных через RegisterFunction,
+// typed = true и returnType — объявленный тип результата.
+type builtinFunction struct {
+	fn         scalarFunction
+	minArgs    int
+	maxArgs    int
+	argTypes   [][]DataType
+	returnType DataType
+	typed      bool
+}
+
+// Допустимые типы аргументов в сигнатурах функций.
+var (
+	anyArg      []DataType
+	textArg     = []DataType{STRING}
+	integerArg  = []DataType{INTEGER}
+	numericArg  = []DataType{INTEGER, FLOAT, DECIMAL}
+	temporalArg = []DataType{DATE, TIME, TIMESTAMP, TIMESTAMPTZ, INTERVAL}
+	jsonArg     = []DataType{JSON, STRING}
+	byteaArg    = []DataType{BYTEA}
+)
+
+// fixedArgs описывает функцию с постоянным числом аргументов.
+func fixedArgs(fn scalarFunction, argTypes ...[]DataType) *builtinFunction {
+	return &builtinFunction{fn: fn, minArgs: len(argTypes), maxArgs: len(argTypes), argTypes: argTypes}
+}
+
+// optionalArgs описывает функцию, у которой аргументы после minArgs можно опустить.
+func optionalArgs(fn scalarFunction, minArgs int, argTypes ...[]DataType) *builtinFunction {
+	return &builtinFunction{fn: fn, minArgs: minArgs, maxArgs: len(argTypes), argTypes: argTypes}
+}
+
+// variadicArgs описывает функцию с любым числом аргументов не меньше minArgs.
+func variadicArgs(fn scalarFunction, minArgs int, argType []DataType) *builtinFunction {
+	return &builtinFunction{fn: fn, minArgs: minArgs, maxArgs: -1, argTypes: [][]DataType{argType}}
+}
+
+var scalarFunctions = map[string]*builtinFunction{
+	// Дата и время.
+	"now":               fixedArgs(fnNow),
+	"current_timestamp": fixedArgs(fnNow),
+	"localtimestamp":    fixedArgs(fnLocalTimestamp),
+	"current_date":      fixedArgs(fnCurrentDate),
+	"current_time":      fixedArgs(fnCurrentTime),
+	"date_trunc":        fixedArgs(fnDateTrunc, textArg, temporalArg),
+	"date_part":         fixedArgs(fnDatePart, textArg, temporalArg),
+	"age":               optionalArgs(fnAge, 1, temporalArg, temporalArg),
+
+	// JSON, BYTEA и UUID.
+	"json_extract":    fixedArgs(fnJSONExtract, jsonArg, textArg),
+	"json_set":        variadicArgs(fnJSONSet, 3, anyArg),
+	"gen_random_uuid": fixedArgs(fnGenRandomUUID),
+	"encode":          fixedArgs(fnEncode, byteaArg, textArg),
+	"decode":          fixedArgs(fnDecode, textArg, textArg),
+
+	// Строки.
+	"upper":       fixedArgs(fnUpper, textArg),
+	"lower":       fixedArgs(fnLower, textArg),
+	"length":      fixedArgs(fnLength, []DataType{STRING, BYTEA}),
+	"char_length": fixedArgs(fnLength, textArg),
+	"substr":      optionalArgs(fnSubstr, 2, textArg, integerArg, integerArg),
+	"substring":   optionalArgs(fnSubstr, 2, textArg, integerArg, integerArg),
+	"trim":        optionalArgs(fnTrim, 1, textArg, textArg),
+	"ltrim":       optionalArgs(fnLTrim, 1, textArg, textArg),
+	"rtrim":       optionalArgs(fnRTrim, 1, textArg, textArg),
+	"replace":     fixedArgs(fnReplace, textArg, textArg, textArg),
+	"concat":      variadicArgs(fnConcat, 1, anyArg),
+	"position":    fixedArgs(fnPosition, textArg, textArg),
+
+	// Математика.
+	"abs":     fixedArgs(fnAbs, numericArg),
+	"round":   optionalArgs(fnRound, 1, numericArg, integerArg),
+	"ceil":    fixedArgs(fnCeil, numericArg),
+	"ceiling": fixedArgs(fnCeil, numericArg),
+	"floor":   fixedArgs(fnFloor, numericArg),
+	"mod":     fixedArgs(fnMod, numericArg, numericArg),
+	"power":   fixedArgs(fnPower, numericArg, numericArg),
+	"pow":     fixedArgs(fnPower, numericArg, numericArg),
+	"sqrt":    fixedArgs(fnSqrt, numericArg),
+
+	// Условные функции.
+	"coalesce": variadicArgs(fnCoalesce, 1, anyArg),
+	"nullif":   fixedArgs(fnNullIf, anyArg, anyArg),
+	"greatest": variadicArgs(fnGreatest, 1, anyArg),
+	"least":    variadicArgs(fnLeast, 1, anyArg),
+
+	// Типы.
+	"typeof":    fixedArgs(fnTypeOf, anyArg),
+	"pg_typeof": fixedArgs(fnTypeOf, anyArg),
+}
+
+// call проверяет число и типы аргументов по сигнатуре и вызывает функцию.
+// NULL подходит к аргументу любого типа.
+func (f *builtinFunction) call(name string, args []interface{}) (interface{}, error) {
+	if err := f.checkArity(name, len(args)); err != nil {
+		return nil, err
+	}
+	for i, arg := range args {
+		if actual, ok := valueType(arg); ok {
+			if err := checkArgType(name, i, f.argType(i), actual); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.fn(args)
+}
+
+func containsType(types []DataType, dataType DataType) bool {
+	for _, t := range types {
+		if t == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *builtinFunction) checkArity(name string, count int) error {
+	switch {
+	case f.maxArgs == -1 && count < f.minArgs:
+		return fmt.Errorf("функция '%s' ожидает не менее %d аргумент(ов), получено %d", name, f.minArgs, count)
+	case f.maxArgs != -1 && f.minArgs == f.maxArgs && count != f.minArgs:
+		return fmt.Errorf("функция '%s' ожидает %d аргумент(ов), получено %d", name, f.minArgs, count)
+	case f.maxArgs != -1 && (count < f.minArgs || count > f.maxArgs):
+		return fmt.Errorf("функция '%s' ожидает от %d до %d аргументов, получено %d", name, f.minArgs, f.maxArgs, count)
+	}
+	return nil
+}
+
+// argType возвращает допустимые типы i-го аргумента (nil — любой тип).
+func (f *builtinFunction) argType(i int) []DataType {
+	if len(f.argTypes) == 0 {
+		return nil
+	}
+	return f.argTypes[min(i, len(f.argTypes)-1)]
+}
+
+// checkArgType проверяет, что аргумент с индексом i имеет один из допустимых типов.
+func checkArgType(name string, i int, allowed []DataType, actual DataType) error {
+	if allowed == nil || containsType(allowed, actual) {
+		return nil
+	}
+	names := make([]string, len(allowed))
+	for j, dataType := range allowed {
+		names[j] = dataType.String()
+	}
+	return fmt.Errorf("функция '%s' ожидает аргумент %d типа %s, получено %s", name, i+1, strings.Join(names, " или "), actual)
+}
+
+func currentTime() time.Time {
+	return time.Now().Round(time.Microsecond)
+}
+
+func fnNow(args []interface{}) (interface{}, error) {
+	return TimestampTZ{currentTime()}, nil
+}
+
+func fnLocalTimestamp(args []interface{}) (interface{}, error) {
+	return Timestamp{wallClock(currentTime())}, nil
+}
+
+func fnCurrentDate(args []interface{}) (interface{}, error) {
+	return dateOf(currentTime()), nil
+}
+
+func fnCurrentTime(args []interface{}) (interface{}, error) {
+	return TimeOfDay{clockOf(currentTime())}, nil
+}
+
+func fnDateTrunc(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	field := args[0].(string)
+	switch v := args[1].(type) {
+	case Date:
+		t, err := truncateTime(v.t, field)
+		return Timestamp{t}, err
+	case Timestamp:
+		t, err := truncateTime(v.t, field)
+		return Timestamp{t}, err
+	case TimestampTZ:
+		t, err := truncateTime(v.t.Local(), field)
+		return TimestampTZ{t}, err
+	}
+	return nil, fmt.Errorf("функция 'date_trunc' не применима к типу %s", typeNameOf(args[1]))
+}
+
+func fnDatePart(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	return extractField(args[0].(string), args[1])
+}
+
+// fnAge возвращает age(a, b) = a - b в годах, месяцах и днях; age(a) считается
+// от начала текущего дня.
+func fnAge(args []interface{}) (interface{}, error) {
+	if len(args) == 1 {
+		args = []interface{}{Timestamp{dateOf(currentTime()).t}, args[0]}
+	}
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	a, b, _, ok := commonTimestamp(args[0], args[1])
+	if !ok {
+		return nil, fmt.Errorf("функция 'age' не применима к типам %s и %s", typeNameOf(args[0]), typeNameOf(args[1]))
+	}
+	return age(a, b), nil
+}
+
+func fnUpper(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	return strings.ToUpper(args[0].(string)), nil
+}
+
+func fnLower(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	return strings.ToLower(args[0].(string)), nil
+}
+
+// fnLength возвращает длину строки в символах или длину BYTEA в байтах.
+func fnLength(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case string:
+		return utf8.RuneCountInString(v), nil
+	case Bytes:
+		return len(v), nil
+	}
+	return nil, nil
+}
+
+// fnSubstr возвращает substr(s, start [, count]); символы нумеруются с 1, а начало
+// левее первого символа сокращает подстроку, как в PostgreSQL.
+func fnSubstr(args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg == nil {
+			return nil, nil
+		}
+	}
+	runes := []rune(args[0].(string))
+	from := args[1].(int) - 1
+	to := len(runes)
+	if len(args) == 3 {
+		count := args[2].(int)
+		if count < 0 {
+			return nil, fmt.Errorf("функция 'substr': отрицательная длина подстроки %d", count)
+		}
+		to = min(to, from+count)
+	}
+	from = max(from, 0)
+	if from >= to {
+		return "", nil
+	}
+	return string(runes[from:to]), nil
+}
+
+func fnTrim(args []interface{}) (interface{}, error) {
+	return trimWith(strings.Trim, args)
+}
+
+func fnLTrim(args []interface{}) (interface{}, error) {
+	return trimWith(strings.TrimLeft, args)
+}
+
+func fnRTrim(args []interface{}) (interface{}, error) {
+	return trimWith(strings.TrimRight, args)
+}
+
+// trimWith удаляет с краёв строки символы из второго аргумента (по умолчанию пробелы).
+func trimWith(trim func(s, cutset string) string, args []interface{}) (interface{}, error) {
+	cutset := " "
+	if len(args) == 2 {
+		if args[1] == nil {
+			return nil, nil
+		}
+		cutset = args[1].(string)
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	return trim(args[0].(string), cutset), nil
+}
+
+func fnReplace(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil || args[2] == nil {
+		return nil, nil
+	}
+	s, from := args[0].(string), args[1].(string)
+	if from == "" {
+		return s, nil
+	}
+	return strings.ReplaceAll(s, from, args[2].(string)), nil
+}
+
+// fnConcat соединяет аргументы любых типов в строку; NULL пропускаются.
+func fnConcat(args []interface{}) (interface{}, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		if arg != nil {
+			fmt.Fprintf(&b, "%v", arg)
+		}
+	}
+	return b.String(), nil
+}
+
+// fnPosition возвращает номер символа, с которого подстрока входит в строку, или 0:
+// position(substring, string), а также position(substring IN string).
+func fnPosition(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	s := args[1].(string)
+	index := strings.Index(s, args[0].(string))
+	if index == -1 {
+		return 0, nil
+	}
+	return utf8.RuneCountInString(s[:index]) + 1, nil
+}
+
+func fnAbs(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+	case float64:
+		return math.Abs(v), nil
+	case Decimal:
+		return v.abs(), nil
+	}
+	return nil, nil
+}
+
+// fnRound округляет число до places знаков после запятой (по умолчанию до целого);
+// половина округляется от нуля.
+func fnRound(args []interface{}) (interface{}, error) {
+	places := 0
+	if len(args) == 2 {
+		if args[1] == nil {
+			return nil, nil
+		}
+		places = args[1].(int)
+	}
+	switch v := args[0].(type) {
+	case int:
+		if places >= 0 {
+			return v, nil
+		}
+		n, _ := decimalFromInt(v).roundTo(places).toInt()
+		return n, nil
+	case float64:
+		scale := math.Pow(10, float64(places))
+		return math.Round(v*scale) / scale, nil
+	case Decimal:
+		return v.roundTo(places), nil
+	}
+	return nil, nil
+}
+
+func fnCeil(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return v, nil
+	case float64:
+		return math.Ceil(v), nil
+	case Decimal:
+		return v.ceil(), nil
+	}
+	return nil, nil
+}
+
+func fnFloor(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return v, nil
+	case float64:
+		return math.Floor(v), nil
+	case Decimal:
+		return v.floor(), nil
+	}
+	return nil, nil
+}
+
+func fnMod(args []interface{}) (interface{}, error) {
+	return applyArithmetic("%", args[0], args[1])
+}
+
+func fnPower(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	result := math.Pow(toFloat(args[0]), toFloat(args[1]))
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return nil, fmt.Errorf("функция 'power': результат не определён для %v и %v", args[0], args[1])
+	}
+	return result, nil
+}
+
+func fnSqrt(args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	x := toFloat(args[0])
+	if x < 0 {
+		return nil, fmt.Errorf("функция 'sqrt': квадратный корень из отрицательного числа %v", args[0])
+	}
+	return math.Sqrt(x), nil
+}
+
+// fnCoalesce возвращает первый аргумент не NULL.
+func fnCoalesce(args []interface{}) (interface{}, error) {
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+// fnNullIf возвращает NULL, если аргументы равны, и первый аргумент иначе.
+func fnNullIf(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return args[0], nil
+	}
+	other, err := coerceLiteral(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	cmp, err := compareTyped(args[0], other)
+	if err != nil {
+		return nil, fmt.Errorf("функция 'nullif': %v", err)
+	}
+	if cmp == 0 {
+		return nil, nil
+	}
+	return args[0], nil
+}
+
+func fnGreatest(args []interface{}) (interface{}, error) {
+	return extremum("greatest", args, 1)
+}
+
+func fnLeast(args []interface{}) (interface{}, error) {
+	return extremum("least", args, -1)
+}
+
+// extremum возвращает наибольший (sign = 1) или наименьший (sign = -1) аргумент;
+// NULL пропускаются, а если все аргументы NULL, результат — NULL.
+func extremum(name string, args []interface{}, sign int) (interface{}, error) {
+	var best interface{}
+	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
+		if best == nil {
+			best = arg
+			continue
+		}
+		cmp, err := compareTyped(arg, best)
+		if err != nil {
+			return nil, fmt.Errorf("функция '%s': %v", name, err)
+		}
+		if cmp*sign > 0 {
+			best = arg
+		}
+	}
+	return best, nil
+}
+
+// fnTypeOf возвращает имя типа значения.
+func fnTypeOf(args []interface{}) (interface{}, error) {
+	return typeNameOf(args[0]), nil
+}
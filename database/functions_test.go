@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBuiltinFunctions(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING, email STRING, score INTEGER, balance DECIMAL(8,2))",
+		"INSERT INTO users VALUES (1, '  Alice ', NULL, -5, 10.25)",
+		"INSERT INTO users VALUES (2, 'Bob', 'bob@x', 12, -3.50)",
+	)
+	cases := map[string]string{
+		"SELECT upper(name), lower(name), length(trim(name)) FROM users WHERE id = 1":                                                 "[[  ALICE    alice  5]]",
+		"SELECT substr('Привет', 2, 3), substring('abcdef', 4), position('c' IN 'abc'), position('z', 'abc') FROM users WHERE id = 1": "[[рив def 3 0]]",
+		"SELECT trim('xxhixx', 'x'), ltrim('  a'), rtrim('a  '), replace('a-b-c', '-', '+') FROM users WHERE id = 1":                  "[[hi a a a+b+c]]",
+		"SELECT concat(name, NULL, id, TRUE) FROM users WHERE id = 2":                                                                 "[[Bob2true]]",
+		"SELECT abs(score), abs(balance), round(balance, 1), round(1234.5, -2) FROM users WHERE id = 2":                               "[[12 3.50 -3.5 1200]]",
+		"SELECT ceil(2.1), floor(-2.1), mod(7, 3), power(2, 10), sqrt(16) FROM users WHERE id = 1":                                    "[[3 -3 1 1024 4]]",
+		"SELECT coalesce(email, 'не указан'), greatest(score, 0), least(score, NULL, 3), nullif(score, 12) FROM users ORDER BY id":    "[[не указан 0 -5 -5] [bob@x 12 3 <nil>]]",
+		"SELECT typeof(balance), pg_typeof(name), typeof(NULL), typeof(1.5) FROM users WHERE id = 1":                                  "[[DECIMAL STRING NULL FLOAT]]",
+		"SELECT upper(NULL), length(NULL), round(NULL, 2) FROM users WHERE id = 1":                                                    "[[<nil> <nil> <nil>]]",
+		"SELECT id FROM users WHERE length(trim(name)) > 3":                                                                           "[[1]]",
+		"SELECT name FROM users ORDER BY lower(name) DESC":                                                                            "[[Bob] [  Alice ]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	mustExec(t, db,
+		"UPDATE users SET name = upper(trim(name)) WHERE id = 1",
+		"INSERT INTO users VALUES (abs(-3), lower('CAROL'), NULL, 0, round(2.555, 2))",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, name, balance FROM users WHERE id <> 2 ORDER BY id")); got != "[[1 ALICE 10.25] [3 carol 2.56]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestFunctionCallsCheckedBeforeExecution(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db, "CREATE TABLE users (id INTEGER, name STRING)")
+	for _, query := range []string{
+		"SELECT upper(5) FROM users",
+		"SELECT substr(name) FROM users",
+		"SELECT nosuch(name) FROM users",
+		"SELECT sqrt('x') FROM users",
+		"UPDATE users SET name = nosuch(name)",
+	} {
+		mustFail(t, db, query)
+	}
+	mustExec(t, db, "INSERT INTO users VALUES (1, 'a')")
+	mustFail(t, db, "SELECT upper(id) FROM users")
+	mustFail(t, db, "SELECT sqrt(-1) FROM users")
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+// newTestDatabase создаёт пустую базу во временном каталоге: таблицы
+// сохраняются в файлы текущего каталога.
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return NewDatabase()
+}
+
+// mustExec выполняет запросы и прерывает тест при первой ошибке.
+func mustExec(t *testing.T, db *Database, queries ...string) {
+	t.Helper()
+	for _, query := range queries {
+		if _, err := db.ExecuteSQL(query); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+}
+
+// mustQuery выполняет запрос и возвращает строки результата.
+func mustQuery(t *testing.T, db *Database, query string) [][]interface{} {
+	t.Helper()
+	rows, err := db.ExecuteSQL(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return rows
+}
+
+// mustFail проверяет, что запрос завершается ошибкой.
+func mustFail(t *testing.T, db *Database, query string) {
+	t.Helper()
+	if _, err := db.ExecuteSQL(query); err == nil {
+		t.Fatalf("%s: ожидалась ошибка", query)
+	}
+}
@@ -0,0 +1,598 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Index — индекс таблицы. Элемент Columns — имя столбца или текст выражения,
+// например data->>'name'; разобранные выражения хранятся в exprs.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Method  string
+	tree    *btree
+	entries map[string][]int
+	exprs   []*Expr
+}
+
+// resolveColumns проверяет, что столбцы индекса существуют, и разбирает выражения;
+// текст выражений приводится к единому виду.
+func (idx *Index) resolveColumns(db *Database, table *Table) error {
+	idx.exprs = make([]*Expr, len(idx.Columns))
+	for i, colName := range idx.Columns {
+		if getColumnIndex(table, colName) != -1 {
+			continue
+		}
+		expr, err := parseExpressionString(db, colName)
+		if err != nil || expr.Type == ColumnExpr {
+			return fmt.Errorf("столбец '%s' индекса '%s' не найден в таблице '%s'", colName, idx.Name, table.Name)
+		}
+		for _, name := range expressionColumns(expr, nil) {
+			if getColumnIndex(table, name) == -1 {
+				return fmt.Errorf("столбец '%s' индекса '%s' не найден в таблице '%s'", name, idx.Name, table.Name)
+			}
+		}
+		idx.Columns[i] = exprString(expr)
+		idx.exprs[i] = expr
+	}
+	return nil
+}
+
+// keyValues возвращает ключ строки в индексе. Выражение, которое не удалось
+// вычислить, индексируется как NULL.
+func (idx *Index) keyValues(table *Table, row []interface{}) []interface{} {
+	values := make([]interface{}, len(idx.Columns))
+	for i, colName := range idx.Columns {
+		if expr := idx.expr(i); expr != nil {
+			values[i], _ = evaluateExpression(row, table.columnNames(), expr)
+			continue
+		}
+		colIndex := getColumnIndex(table, colName)
+		if colIndex != -1 && colIndex < len(row) {
+			values[i] = row[colIndex]
+		}
+	}
+	return values
+}
+
+// expr возвращает выражение i-го элемента индекса или nil для столбца.
+func (idx *Index) expr(i int) *Expr {
+	if i < len(idx.exprs) {
+		return idx.exprs[i]
+	}
+	return nil
+}
+
+func (idx *Index) hasExpressions() bool {
+	for _, expr := range idx.exprs {
+		if expr != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// keyMatches сообщает, что левая часть условия совпадает с i-м элементом индекса.
+func (idx *Index) keyMatches(i int, c *Condition) bool {
+	if expr := idx.expr(i); expr != nil || c.Expr != nil {
+		return expr != nil && c.Expr != nil && strings.EqualFold(exprString(expr), c.Column)
+	}
+	return columnMatches(c.Column, idx.Columns[i])
+}
+
+func (idx *Index) add(key []interface{}, pos int) {
+	if idx.Method == "HASH" {
+		encoded := encodeKey(key)
+		idx.entries[encoded] = append(idx.entries[encoded], pos)
+		return
+	}
+	idx.tree.insert(key, pos)
+}
+
+func (idx *Index) remove(key []interface{}, pos int) {
+	if idx.Method == "HASH" {
+		encoded := encodeKey(key)
+		positions := idx.entries[encoded]
+		for i, p := range positions {
+			if p == pos {
+				positions = append(positions[:i], positions[i+1:]...)
+				break
+			}
+		}
+		if len(positions) == 0 {
+			delete(idx.entries, encoded)
+		} else {
+			idx.entries[encoded] = positions
+		}
+		return
+	}
+	idx.tree.removePosition(key, pos)
+}
+
+func (idx *Index) lookup(key []interface{}) []int {
+	if idx.Method == "HASH" {
+		return idx.entries[encodeKey(key)]
+	}
+	item := idx.tree.get(key)
+	if item == nil {
+		return nil
+	}
+	return item.positions
+}
+
+// scan возвращает позиции строк с ключами в диапазоне [lower, upper] в порядке ключа.
+func (idx *Index) scan(lower, upper *indexBound) []int {
+	var positions []int
+	idx.tree.ascend(lower, upper, func(item *btreeItem) bool {
+		positions = append(positions, item.positions...)
+		return true
+	})
+	return positions
+}
+
+func (idx *Index) rebuild(db *Database, table *Table) error {
+	if err := idx.resolveColumns(db, table); err != nil {
+		return err
+	}
+	if idx.Method == "" {
+		idx.Method = "BTREE"
+	}
+	idx.tree = &btree{}
+	idx.entries = make(map[string][]int)
+	for pos, row := range table.Rows {
+		idx.add(idx.keyValues(table, row), pos)
+	}
+	return nil
+}
+
+func (table *Table) findIndex(name string) *Index {
+	for _, idx := range table.Indexes {
+		if strings.ToLower(idx.Name) == strings.ToLower(name) {
+			return idx
+		}
+	}
+	return nil
+}
+
+func (table *Table) rebuildIndexes(db *Database) error {
+	for _, idx := range table.Indexes {
+		if err := idx.rebuild(db, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (table *Table) indexRow(pos int) {
+	for _, idx := range table.Indexes {
+		idx.add(idx.keyValues(table, table.Rows[pos]), pos)
+	}
+}
+
+func (table *Table) unindexRow(pos int) {
+	for _, idx := range table.Indexes {
+		idx.remove(idx.keyValues(table, table.Rows[pos]), pos)
+	}
+}
+
+// Ключ индекса строится так, чтобы равные по isEqual значения (например, 1 и 1.0)
+// давали одинаковое представление.
+func encodeKey(values []interface{}) string {
+	var sb strings.Builder
+	for i, value := range values {
+		if i > 0 {
+			sb.WriteByte('|')
+		}
+		switch v := value.(type) {
+		case nil:
+			sb.WriteString("N")
+		case int:
+			sb.WriteString("n" + strconv.Itoa(v))
+		case float64:
+			if v == float64(int(v)) {
+				sb.WriteString("n" + strconv.Itoa(int(v)))
+			} else {
+				sb.WriteString("f" + strconv.FormatFloat(v, 'g', -1, 64))
+			}
+		case string:
+			sb.WriteString("s" + strconv.Itoa(len(v)) + ":" + v)
+		case Decimal:
+			sb.WriteString(decimalKey(v))
+		case bool:
+			sb.WriteString("b" + strconv.FormatBool(v))
+		case JSONValue:
+			sb.WriteString("j" + jsonKey(v))
+		case Bytes:
+			sb.WriteString("x" + strconv.Itoa(len(v)) + ":" + string(v))
+		case UUIDValue:
+			sb.WriteString("u" + v.String())
+		default:
+			sb.WriteString(fmt.Sprintf("%T:%v", v, v))
+		}
+	}
+	return sb.String()
+}
+
+// compareValues задаёт порядок значений в индексах и ORDER BY по правилам compareTyped;
+// NULL считается больше любого значения, несравнимые типы упорядочиваются по имени типа.
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+	if cmp, err := compareTyped(a, b); err == nil {
+		return cmp
+	}
+	return strings.Compare(fmt.Sprintf("%T:%v", a, a), fmt.Sprintf("%T:%v", b, b))
+}
+
+func hasNullValue(values []interface{}) bool {
+	for _, v := range values {
+		if v == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func formatKeyValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if v == nil {
+			parts[i] = "NULL"
+		} else {
+			parts[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (db *Database) findIndexTable(indexName string) (*Table, *Index) {
+	for _, table := range db.Tables {
+		if idx := table.findIndex(indexName); idx != nil {
+			return table, idx
+		}
+	}
+	return nil, nil
+}
+
+// generateIndexName подбирает свободное имя индекса; имена индексов уникальны во всей базе.
+func (db *Database) generateIndexName(table *Table, base string) string {
+	name := base
+	for n := 1; ; n++ {
+		if _, existing := db.findIndexTable(name); existing == nil && table.findIndex(name) == nil {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, n)
+	}
+}
+
+func (db *Database) CreateIndex(indexName, tableName string, columns []string, unique bool, method string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tableName = strings.ToLower(tableName)
+	indexName = strings.ToLower(indexName)
+	table, exists := db.Tables[tableName]
+	if !exists {
+		return fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	if _, existing := db.findIndexTable(indexName); existing != nil {
+		return fmt.Errorf("индекс '%s' уже существует", indexName)
+	}
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = "BTREE"
+	}
+	switch method {
+	case "BTREE":
+	case "HASH":
+		if len(columns) != 1 {
+			return errors.New("хеш-индекс может быть построен только по одному столбцу")
+		}
+		if unique {
+			return errors.New("хеш-индекс не может быть уникальным")
+		}
+	default:
+		return fmt.Errorf("неизвестный метод индекса '%s'", method)
+	}
+
+	idx := &Index{Name: indexName, Columns: columns, Unique: unique, Method: method}
+	err := idx.rebuild(db, table)
+	if err != nil {
+		return err
+	}
+	if unique {
+		if values := idx.duplicateKey(table); values != nil {
+			return fmt.Errorf("невозможно создать уникальный индекс '%s': значение (%s)=(%s) повторяется", indexName, strings.Join(columns, ", "), formatKeyValues(values))
+		}
+	}
+
+	db.logSchemaChange(tableName)
+	table.Indexes = append(table.Indexes, idx)
+	return db.saveTableToDisk(tableName)
+}
+
+// duplicateKey возвращает ключ, который в уникальном индексе встречается более одного
+// раза, или nil. Ключи с NULL не сравниваются.
+func (idx *Index) duplicateKey(table *Table) []interface{} {
+	if !idx.Unique {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, row := range table.Rows {
+		values := idx.keyValues(table, row)
+		if hasNullValue(values) {
+			continue
+		}
+		key := encodeKey(values)
+		if seen[key] {
+			return values
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func (db *Database) DropIndex(indexName string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table, idx := db.findIndexTable(indexName)
+	if idx == nil {
+		return fmt.Errorf("индекс '%s' не существует", indexName)
+	}
+	for _, c := range table.Constraints {
+		if strings.ToLower(c.Name) == strings.ToLower(idx.Name) {
+			return fmt.Errorf("индекс '%s' обеспечивает ограничение %s таблицы '%s' и не может быть удалён", idx.Name, c.Type, table.Name)
+		}
+	}
+	db.logSchemaChange(table.Name)
+	for i, other := range table.Indexes {
+		if other == idx {
+			table.Indexes = append(table.Indexes[:i], table.Indexes[i+1:]...)
+			break
+		}
+	}
+	return db.saveTableToDisk(table.Name)
+}
+
+// indexPlan описывает доступ к таблице через индекс: равенство по первым eqColumns
+// столбцам индекса и, возможно, диапазон по следующему столбцу.
+type indexPlan struct {
+	index     *Index
+	eqColumns int
+	lower     *indexBound
+	upper     *indexBound
+}
+
+func (plan *indexPlan) positions() []int {
+	if plan.index.Method == "HASH" {
+		positions := append([]int(nil), plan.index.lookup(plan.lower.key)...)
+		sort.Ints(positions)
+		return positions
+	}
+	return plan.index.scan(plan.lower, plan.upper)
+}
+
+func collectConjuncts(condition *Condition, conjuncts []*Condition) []*Condition {
+	if condition == nil {
+		return conjuncts
+	}
+	switch {
+	case condition.Type == Compound && condition.LogicalOp == "AND":
+		conjuncts = collectConjuncts(condition.Left, conjuncts)
+		return collectConjuncts(condition.Right, conjuncts)
+	case condition.Type == Simple:
+		return append(conjuncts, condition)
+	}
+	return conjuncts
+}
+
+func columnMatches(reference, columnName string) bool {
+	if strings.Contains(reference, ".") {
+		reference = reference[strings.LastIndex(reference, ".")+1:]
+	}
+	return strings.ToLower(reference) == strings.ToLower(columnName)
+}
+
+// indexLiteral приводит литерал условия к типу индексируемого столбца; строки
+// для временных столбцов, BYTEA и UUID разбираются так же, как при сравнении.
+func indexLiteral(value interface{}, dataType DataType) (interface{}, bool) {
+	if s, ok := value.(string); ok && (isTemporalType(dataType) || dataType == BYTEA || dataType == UUID) {
+		converted, err := parseValue(s, dataType)
+		return converted, err == nil
+	}
+	return value, literalMatchesType(value, dataType)
+}
+
+func literalMatchesType(value interface{}, dataType DataType) bool {
+	switch value.(type) {
+	case int, float64, Decimal:
+		return dataType == INTEGER || dataType == FLOAT || dataType == DECIMAL
+	case string:
+		return dataType == STRING
+	case bool:
+		return dataType == BOOLEAN
+	}
+	actual, ok := valueType(value)
+	return ok && actual == dataType
+}
+
+// planIndexScan выбирает индекс для условия WHERE. Найденные через индекс строки
+// всё равно проверяются полным условием, поэтому план только сужает просмотр.
+func planIndexScan(table *Table, condition *Condition) *indexPlan {
+	conjuncts := collectConjuncts(condition, nil)
+	if len(conjuncts) == 0 {
+		return nil
+	}
+	var best *indexPlan
+	bestScore := 0
+	for _, idx := range table.Indexes {
+		plan := &indexPlan{index: idx}
+		var prefix []interface{}
+		hasRange := false
+		for i, colName := range idx.Columns {
+			var eqValue interface{}
+			var lower, upper *indexBound
+			for _, c := range conjuncts {
+				if !idx.keyMatches(i, c) || c.Value == nil {
+					continue
+				}
+				// Тип выражения заранее не известен, поэтому константа берётся как есть.
+				value, ok := c.Value, true
+				if idx.expr(i) == nil {
+					value, ok = indexLiteral(c.Value, table.Columns[getColumnIndex(table, colName)].Type)
+				}
+				if !ok {
+					continue
+				}
+				switch c.Operator {
+				case "=":
+					eqValue = value
+				case ">", ">=":
+					lower = &indexBound{key: appendKey(prefix, value), inclusive: c.Operator == ">="}
+				case "<", "<=":
+					upper = &indexBound{key: appendKey(prefix, value), inclusive: c.Operator == "<="}
+				}
+			}
+			if eqValue != nil {
+				prefix = appendKey(prefix, eqValue)
+				plan.eqColumns++
+				continue
+			}
+			if lower != nil || upper != nil {
+				plan.lower, plan.upper = lower, upper
+				hasRange = true
+			}
+			break
+		}
+		if plan.eqColumns == 0 && !hasRange {
+			continue
+		}
+		if idx.Method == "HASH" && plan.eqColumns != len(idx.Columns) {
+			continue
+		}
+		if len(prefix) > 0 {
+			if plan.lower == nil {
+				plan.lower = &indexBound{key: prefix, inclusive: true}
+			}
+			if plan.upper == nil {
+				plan.upper = &indexBound{key: prefix, inclusive: true}
+			}
+		}
+		score := plan.eqColumns * 2
+		if hasRange {
+			score++
+		}
+		if idx.Unique && plan.eqColumns == len(idx.Columns) {
+			score += 100
+		} else if idx.Method == "HASH" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = plan, score
+		}
+	}
+	return best
+}
+
+func appendKey(prefix []interface{}, value interface{}) []interface{} {
+	key := make([]interface{}, len(prefix), len(prefix)+1)
+	copy(key, prefix)
+	return append(key, value)
+}
+
+// indexOrder проверяет, что порядок индекса после первых skip столбцов совпадает
+// с ORDER BY, и возвращает признак обратного обхода.
+func indexOrder(idx *Index, skip int, orderBy []OrderBy) (matches bool, reverse bool) {
+	if len(orderBy) == 0 || idx.Method == "HASH" || skip+len(orderBy) > len(idx.Columns) {
+		return false, false
+	}
+	for i, item := range orderBy {
+		if item.Expr.Type != ColumnExpr || idx.expr(skip+i) != nil || !columnMatches(item.Expr.Name, idx.Columns[skip+i]) || item.Desc != orderBy[0].Desc {
+			return false, false
+		}
+	}
+	return true, orderBy[0].Desc
+}
+
+// scanTable возвращает строки таблицы, удовлетворяющие условию, используя индекс,
+// если это возможно. ordered сообщает, что строки уже упорядочены по orderBy.
+func scanTable(table *Table, condition *Condition, orderBy []OrderBy) ([][]interface{}, bool, error) {
+	var positions []int
+	useIndex, ordered, reverse := false, false, false
+	if plan := planIndexScan(table, condition); plan != nil {
+		positions = plan.positions()
+		useIndex = true
+		ordered, reverse = indexOrder(plan.index, plan.eqColumns, orderBy)
+	} else if len(orderBy) > 0 {
+		for _, idx := range table.Indexes {
+			if matches, desc := indexOrder(idx, 0, orderBy); matches {
+				positions = idx.scan(nil, nil)
+				useIndex, ordered, reverse = true, true, desc
+				break
+			}
+		}
+	}
+	if !useIndex {
+		positions = make([]int, len(table.Rows))
+		for i := range positions {
+			positions[i] = i
+		}
+	}
+	if useIndex && !ordered {
+		sort.Ints(positions)
+	}
+	if reverse {
+		for i, j := 0, len(positions)-1; i < j; i, j = i+1, j-1 {
+			positions[i], positions[j] = positions[j], positions[i]
+		}
+	}
+
+	columnNames := table.columnNames()
+	var result [][]interface{}
+	for _, pos := range positions {
+		row := table.Rows[pos]
+		if condition != nil {
+			match, err := evaluateCondition(row, columnNames, condition)
+			if err != nil {
+				return nil, false, err
+			}
+			if !match {
+				continue
+			}
+		}
+		result = append(result, row)
+	}
+	return result, ordered, nil
+}
+
+// equalityIndex возвращает индекс, пригодный для поиска строк по равенству одного столбца;
+// хеш-индексы предпочтительнее.
+func (table *Table) equalityIndex(columnName string) *Index {
+	var found *Index
+	for _, idx := range table.Indexes {
+		if len(idx.Columns) != 1 || idx.expr(0) != nil || !columnMatches(columnName, idx.Columns[0]) {
+			continue
+		}
+		if idx.Method == "HASH" {
+			return idx
+		}
+		if found == nil {
+			found = idx
+		}
+	}
+	return found
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// checkIndexes проверяет, что каждый индекс таблицы содержит ровно по одной
+// записи на строку и запись находится по ключу строки.
+func checkIndexes(t *testing.T, db *Database, tableName string) {
+	t.Helper()
+	table := db.Tables[tableName]
+	for _, idx := range table.Indexes {
+		var positions []int
+		if idx.Method == "HASH" {
+			for _, entry := range idx.entries {
+				positions = append(positions, entry...)
+			}
+		} else {
+			positions = idx.scan(nil, nil)
+		}
+		sort.Ints(positions)
+		if len(positions) != len(table.Rows) {
+			t.Fatalf("индекс %s: %d записей на %d строк", idx.Name, len(positions), len(table.Rows))
+		}
+		for pos, row := range table.Rows {
+			if positions[pos] != pos {
+				t.Fatalf("индекс %s: позиции %v", idx.Name, positions)
+			}
+			found := false
+			for _, p := range idx.lookup(idx.keyValues(table, row)) {
+				found = found || p == pos
+			}
+			if !found {
+				t.Fatalf("индекс %s: строка %v не находится по ключу", idx.Name, row)
+			}
+		}
+	}
+}
+
+func newOrdersDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, order_date DATE, amount INTEGER)",
+		"CREATE INDEX orders_user_date ON orders (user_id, order_date)",
+		"INSERT INTO orders VALUES (1, 1, '2023-01-15', 10)",
+		"INSERT INTO orders VALUES (2, 2, '2023-01-20', 20)",
+		"INSERT INTO orders VALUES (3, 1, '2023-02-10', 30)",
+		"INSERT INTO orders VALUES (4, 1, '2023-03-05', 40)",
+		"INSERT INTO orders VALUES (5, 2, '2023-02-01', 50)",
+	)
+	return db
+}
+
+func TestIndexScanResults(t *testing.T) {
+	db := newOrdersDatabase(t)
+	cases := map[string]string{
+		"SELECT id FROM orders WHERE user_id = 1":                                                 "[[1] [3] [4]]",
+		"SELECT id FROM orders WHERE user_id = 1 AND order_date > '2023-02-01'":                   "[[3] [4]]",
+		"SELECT id FROM orders WHERE user_id = 1 AND order_date >= '2023-01-15' AND amount <> 30": "[[1] [4]]",
+		"SELECT id FROM orders WHERE user_id = 2 AND order_date < '2023-02-01'":                   "[[2]]",
+		"SELECT id FROM orders ORDER BY user_id DESC, order_date DESC LIMIT 3":                    "[[5] [2] [4]]",
+		"SELECT id FROM orders WHERE user_id = 1 ORDER BY order_date LIMIT 1 OFFSET 1":            "[[3]]",
+		"SELECT id FROM orders WHERE id = 4":                                                      "[[4]]",
+		"SELECT id FROM orders WHERE user_id = 3":                                                 "[]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+}
+
+func TestIndexMaintainedByWritesAndRollback(t *testing.T) {
+	db := newOrdersDatabase(t)
+	mustExec(t, db,
+		"UPDATE orders SET user_id = 3 WHERE id = 1",
+		"DELETE FROM orders WHERE id = 2",
+	)
+	checkIndexes(t, db, "orders")
+
+	mustExec(t, db,
+		"BEGIN",
+		"INSERT INTO orders VALUES (6, 1, '2023-04-01', 60)",
+		"UPDATE orders SET user_id = 2 WHERE user_id = 1",
+		"DELETE FROM orders WHERE id = 5",
+		"ROLLBACK",
+	)
+	checkIndexes(t, db, "orders")
+
+	rows := mustQuery(t, db, "SELECT id FROM orders WHERE user_id = 1 ORDER BY order_date")
+	if got := fmt.Sprint(rows); got != "[[3] [4]]" {
+		t.Fatalf("после ROLLBACK: %s", got)
+	}
+	mustFail(t, db, "INSERT INTO orders VALUES (3, 1, '2023-04-01', 60)")
+	mustExec(t, db, "INSERT INTO orders VALUES (6, 1, '2023-04-01', 60)")
+	checkIndexes(t, db, "orders")
+}
+
+func TestDropIndex(t *testing.T) {
+	db := newOrdersDatabase(t)
+	mustExec(t, db, "CREATE UNIQUE INDEX IF NOT EXISTS orders_amount_uq ON orders (amount)")
+	mustFail(t, db, "INSERT INTO orders VALUES (8, 1, '2023-05-01', 10)")
+	mustFail(t, db, "CREATE INDEX orders_amount_uq ON orders (user_id)")
+	mustExec(t, db,
+		"DROP INDEX orders_amount_uq",
+		"DROP INDEX IF EXISTS orders_amount_uq",
+		"INSERT INTO orders VALUES (8, 1, '2023-05-01', 10)",
+	)
+	mustFail(t, db, "DROP INDEX orders_amount_uq")
+	mustFail(t, db, "CREATE UNIQUE INDEX orders_amount_uq ON orders (amount)")
+	checkIndexes(t, db, "orders")
+}
+
+func TestHashIndexLookupsAndJoins(t *testing.T) {
+	db := newOrdersDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING)",
+		"INSERT INTO users VALUES (1, 'Alice')",
+		"INSERT INTO users VALUES (2, 'Bob')",
+		"INSERT INTO users VALUES (4, 'Dave')",
+		"CREATE INDEX orders_user_hash ON orders USING HASH (user_id)",
+	)
+	mustFail(t, db, "CREATE UNIQUE INDEX users_id_hash ON users USING HASH (id)")
+	checkIndexes(t, db, "orders")
+
+	cases := map[string]string{
+		"SELECT id FROM orders WHERE user_id = 2":                                                                       "[[2] [5]]",
+		"SELECT id FROM orders WHERE user_id > 1":                                                                       "[[2] [5]]",
+		"SELECT id FROM orders WHERE user_id = 1 AND amount > 10":                                                       "[[3] [4]]",
+		"SELECT users.name, orders.id FROM users JOIN orders ON users.id = orders.user_id ORDER BY orders.id":           "[[Alice 1] [Bob 2] [Alice 3] [Alice 4] [Bob 5]]",
+		"SELECT users.name, orders.id FROM users LEFT JOIN orders ON users.id = orders.user_id WHERE orders.id IS NULL": "[[Dave <nil>]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	mustExec(t, db,
+		"BEGIN",
+		"UPDATE orders SET user_id = 4 WHERE user_id = 2",
+		"ROLLBACK",
+		"DELETE FROM orders WHERE id = 1",
+	)
+	checkIndexes(t, db, "orders")
+	rows := mustQuery(t, db, "SELECT orders.id FROM users JOIN orders ON users.id = orders.user_id WHERE users.name = 'Dave'")
+	if got := fmt.Sprint(rows); got != "[]" {
+		t.Fatalf("после ROLLBACK: %s", got)
+	}
+}
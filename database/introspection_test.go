@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newIntrospectionDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER AUTO_INCREMENT PRIMARY KEY, name STRING NOT NULL, email STRING UNIQUE, status STRING DEFAULT 'active')",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id) DEFERRABLE INITIALLY DEFERRED, amount DECIMAL(8,2))",
+		"CREATE INDEX orders_user_id_idx ON orders USING HASH (user_id)",
+		"CREATE VIEW names AS SELECT name FROM users",
+		"CREATE MATERIALIZED VIEW totals AS SELECT user_id, amount FROM orders",
+	)
+	return db
+}
+
+func TestShowAndDescribe(t *testing.T) {
+	db := newIntrospectionDatabase(t)
+	cases := map[string]string{
+		"SHOW TABLES":              "[[names VIEW] [orders BASE TABLE] [totals MATERIALIZED VIEW] [users BASE TABLE]]",
+		"DESCRIBE users":           "[[id INTEGER NO PRI <nil> AUTO_INCREMENT] [name STRING NO  <nil> ] [email STRING YES UNI <nil> ] [status STRING YES  'active' ]]",
+		"SHOW COLUMNS FROM orders": "[[id INTEGER NO PRI <nil> ] [user_id INTEGER YES  <nil> ] [amount DECIMAL(8,2) YES  <nil> ]]",
+		"SHOW INDEXES FROM orders": "[[orders orders_pkey id BTREE YES] [orders orders_user_id_idx user_id HASH NO]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "DESCRIBE missing")
+}
+
+func TestInformationSchema(t *testing.T) {
+	db := newIntrospectionDatabase(t)
+	cases := map[string]string{
+		"SELECT table_name, table_type FROM information_schema.tables ORDER BY table_name":                                                                                    "[[names VIEW] [orders BASE TABLE] [totals MATERIALIZED VIEW] [users BASE TABLE]]",
+		"SELECT column_name, data_type, is_nullable FROM information_schema.columns WHERE table_name = 'orders' ORDER BY ordinal_position":                                    "[[id INTEGER NO] [user_id INTEGER YES] [amount DECIMAL(8,2) YES]]",
+		"SELECT constraint_name, is_deferrable, initially_deferred FROM information_schema.table_constraints WHERE table_name = 'orders' AND constraint_type = 'FOREIGN KEY'": "[[orders_user_id_fkey YES YES]]",
+		"SELECT column_name FROM information_schema.tables JOIN information_schema.columns ON tables.table_name = columns.table_name WHERE table_type = 'VIEW'":               "[[name]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "DELETE FROM information_schema.tables")
+	mustFail(t, db, "INSERT INTO information_schema.columns (table_name) VALUES ('x')")
+
+	mustExec(t, db, "ALTER TABLE users ADD COLUMN age INTEGER")
+	rows := mustQuery(t, db, "SELECT ordinal_position FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'age'")
+	if got := fmt.Sprint(rows); got != "[[5]]" {
+		t.Fatalf("новый столбец: %s", got)
+	}
+}
@@ -3,125 +3,134 @@ package database
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"sort"
 )
 
+// isEqual сравнивает ключи соединения по правилам compareTyped; NULL ничему не равен.
 func isEqual(a, b interface{}) bool {
-	switch aTyped := a.(type) {
-	case int:
-		switch bTyped := b.(type) {
-		case int:
-			return aTyped == bTyped
-		case float64:
-			return float64(aTyped) == bTyped
-		}
-	case float64:
-		switch bTyped := b.(type) {
-		case int:
-			return aTyped == float64(bTyped)
-		case float64:
-			return aTyped == bTyped
-		}
-	case string:
-		if bTyped, ok := b.(string); ok {
-			return aTyped == bTyped
+	if a == nil || b == nil {
+		return false
+	}
+	cmp, err := compareTyped(a, b)
+	return err == nil && cmp == 0
+}
+
+// joinMatches возвращает позиции строк table2, у которых столбец joinIndex равен value.
+// Если по столбцу есть индекс, он используется как готовая хеш-таблица соединения.
+func joinMatches(table *Table, joinIndex int, index *Index, value interface{}) []int {
+	if value == nil {
+		return nil
+	}
+	if index != nil {
+		positions := append([]int(nil), index.lookup([]interface{}{value})...)
+		sort.Ints(positions)
+		return positions
+	}
+	var positions []int
+	for pos, row := range table.Rows {
+		if isEqual(value, row[joinIndex]) {
+			positions = append(positions, pos)
 		}
 	}
-	return false
+	return positions
+}
+
+func combineRows(row1, row2 []interface{}) []interface{} {
+	combined := make([]interface{}, 0, len(row1)+len(row2))
+	combined = append(combined, row1...)
+	return append(combined, row2...)
 }
 
 func (db *Database) Join(table1Name, table2Name, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
-	fmt.Printf("Выполнение INNER JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1Name, table2Name, joinColumn1, joinColumn2)
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	table1Name = strings.ToLower(table1Name)
-	table2Name = strings.ToLower(table2Name)
+	table1, table2, err := db.resolveJoinTables(nil, table1Name, table2Name)
+	if err != nil {
+		return nil, err
+	}
+	return db.innerJoin(table1, table2, joinColumn1, joinColumn2)
+}
 
-	table1, exists1 := db.Tables[table1Name]
-	table2, exists2 := db.Tables[table2Name]
+func (db *Database) LeftJoin(table1Name, table2Name, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
+	table1, table2, err := db.resolveJoinTables(nil, table1Name, table2Name)
+	if err != nil {
+		return nil, err
+	}
+	return db.leftJoin(table1, table2, joinColumn1, joinColumn2)
+}
+
+func (db *Database) RightJoin(table1Name, table2Name, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
+	fmt.Printf("Выполнение RIGHT JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1Name, table2Name, joinColumn1, joinColumn2)
+	return db.LeftJoin(table2Name, table1Name, joinColumn2, joinColumn1)
+}
 
-	if !exists1 || !exists2 {
-		return nil, errors.New("одна или обе таблицы не существуют")
+// resolveJoinTables находит обе стороны соединения; представления раскрываются,
+// CTE из scope скрывают одноимённые таблицы.
+func (db *Database) resolveJoinTables(scope relationScope, table1Name, table2Name string) (*Table, *Table, error) {
+	table1, err1 := scope.resolve(db, table1Name)
+	table2, err2 := scope.resolve(db, table2Name)
+	if err1 != nil && err2 != nil {
+		return nil, nil, errors.New("одна или обе таблицы не существуют")
+	}
+	if err1 != nil {
+		return nil, nil, err1
 	}
+	if err2 != nil {
+		return nil, nil, err2
+	}
+	return table1, table2, nil
+}
 
+func joinColumnIndexes(table1, table2 *Table, joinColumn1, joinColumn2 string) (int, int, error) {
 	joinIndex1 := getColumnIndex(table1, joinColumn1)
 	if joinIndex1 == -1 {
-		return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn1, table1Name)
+		return 0, 0, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn1, table1.Name)
 	}
-
 	joinIndex2 := getColumnIndex(table2, joinColumn2)
 	if joinIndex2 == -1 {
-		return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn2, table2Name)
+		return 0, 0, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn2, table2.Name)
+	}
+	return joinIndex1, joinIndex2, nil
+}
+
+func (db *Database) innerJoin(table1, table2 *Table, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
+	fmt.Printf("Выполнение INNER JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1.Name, table2.Name, joinColumn1, joinColumn2)
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	joinIndex1, joinIndex2, err := joinColumnIndexes(table1, table2, joinColumn1, joinColumn2)
+	if err != nil {
+		return nil, err
 	}
 
 	var result [][]interface{}
+	index := table2.equalityIndex(table2.Columns[joinIndex2].Name)
 	for _, row1 := range table1.Rows {
-		for _, row2 := range table2.Rows {
-			if isEqual(row1[joinIndex1], row2[joinIndex2]) {
-				combinedRow := append(row1, row2...)
-				result = append(result, combinedRow)
-			}
+		for _, pos := range joinMatches(table2, joinIndex2, index, row1[joinIndex1]) {
+			result = append(result, combineRows(row1, table2.Rows[pos]))
 		}
 	}
-
-	var columnNames []string
-	for _, col := range table1.Columns {
-		columnNames = append(columnNames, fmt.Sprintf("%s.%s", table1.Name, col.Name))
-	}
-	for _, col := range table2.Columns {
-		columnNames = append(columnNames, fmt.Sprintf("%s.%s", table2.Name, col.Name))
-	}
-
 	return result, nil
 }
 
-func (db *Database) LeftJoin(table1Name, table2Name, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
-	fmt.Printf("Выполнение LEFT JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1Name, table2Name, joinColumn1, joinColumn2)
+func (db *Database) leftJoin(table1, table2 *Table, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
+	fmt.Printf("Выполнение LEFT JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1.Name, table2.Name, joinColumn1, joinColumn2)
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	table1Name = strings.ToLower(table1Name)
-	table2Name = strings.ToLower(table2Name)
-
-	table1, exists1 := db.Tables[table1Name]
-	table2, exists2 := db.Tables[table2Name]
 
-	if !exists1 || !exists2 {
-		return nil, errors.New("одна или обе таблицы не существуют")
-	}
-
-	joinIndex1 := getColumnIndex(table1, joinColumn1)
-	if joinIndex1 == -1 {
-		return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn1, table1Name)
-	}
-
-	joinIndex2 := getColumnIndex(table2, joinColumn2)
-	if joinIndex2 == -1 {
-		return nil, fmt.Errorf("столбец '%s' не найден в таблице '%s'", joinColumn2, table2Name)
+	joinIndex1, joinIndex2, err := joinColumnIndexes(table1, table2, joinColumn1, joinColumn2)
+	if err != nil {
+		return nil, err
 	}
 
 	var result [][]interface{}
+	index := table2.equalityIndex(table2.Columns[joinIndex2].Name)
 	for _, row1 := range table1.Rows {
-		matched := false
-		for _, row2 := range table2.Rows {
-			if isEqual(row1[joinIndex1], row2[joinIndex2]) {
-				combinedRow := append(row1, row2...)
-				result = append(result, combinedRow)
-				matched = true
-			}
+		matches := joinMatches(table2, joinIndex2, index, row1[joinIndex1])
+		for _, pos := range matches {
+			result = append(result, combineRows(row1, table2.Rows[pos]))
 		}
-		if !matched {
-			nulls := make([]interface{}, len(table2.Columns))
-			for i := range nulls {
-				nulls[i] = nil
-			}
-			combinedRow := append(row1, nulls...)
-			result = append(result, combinedRow)
+		if len(matches) == 0 {
+			result = append(result, combineRows(row1, make([]interface{}, len(table2.Columns))))
 		}
 	}
 	return result, nil
 }
-
-func (db *Database) RightJoin(table1Name, table2Name, joinColumn1, joinColumn2 string) ([][]interface{}, error) {
-	fmt.Printf("Выполнение RIGHT JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1Name, table2Name, joinColumn1, joinColumn2)
-	return db.LeftJoin(table2Name, table1Name, joinColumn2, joinColumn1)
-}
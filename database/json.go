@@ -0,0 +1,461 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONValue — документ JSON в разобранном виде (дерево значений encoding/json).
+// Числа хранятся как json.Number без потери точности. Как и JSONB в PostgreSQL,
+// документ хранится нормализованным: ключи упорядочены, пробелы не сохраняются.
+type JSONValue struct {
+	doc interface{}
+}
+
+func parseJSON(text string) (JSONValue, error) {
+	if !json.Valid([]byte(text)) {
+		return JSONValue{}, fmt.Errorf("некорректное значение JSON: %s", text)
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return JSONValue{}, fmt.Errorf("некорректное значение JSON: %v", err)
+	}
+	return JSONValue{doc: doc}, nil
+}
+
+func (j JSONValue) String() string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(j.doc); err != nil {
+		return "null"
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (j JSONValue) MarshalText() ([]byte, error) { return []byte(j.String()), nil }
+
+// toJSON приводит операнд JSON-оператора к документу; строка разбирается как JSON.
+func toJSON(value interface{}) (JSONValue, error) {
+	switch v := value.(type) {
+	case JSONValue:
+		return v, nil
+	case string:
+		return parseJSON(v)
+	}
+	return JSONValue{}, fmt.Errorf("ожидалось значение JSON, получено %s", typeNameOf(value))
+}
+
+// jsonFromValue переводит значение SQL в элемент документа JSON.
+func jsonFromValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool:
+		return v
+	case JSONValue:
+		return v.doc
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case Decimal:
+		return json.Number(v.String())
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// jsonToValue переводит элемент документа в значение SQL: скаляры становятся
+// STRING, INTEGER, FLOAT, DECIMAL или BOOLEAN, null — NULL, объекты и массивы
+// остаются JSON.
+func jsonToValue(element interface{}) interface{} {
+	switch v := element.(type) {
+	case nil, string, bool:
+		return v
+	case json.Number:
+		if value, ok := parseLiteralToken(string(v)); ok {
+			return value
+		}
+		return string(v)
+	}
+	return JSONValue{doc: element}
+}
+
+// jsonText возвращает элемент как текст, как оператор ->>: строки без кавычек,
+// остальные значения — в записи JSON.
+func jsonText(element interface{}) interface{} {
+	switch v := element.(type) {
+	case nil:
+		return nil
+	case string:
+		return v
+	}
+	return JSONValue{doc: element}.String()
+}
+
+// parseJSONPath разбирает путь внутри документа: массив PostgreSQL '{a,b,0}'
+// или путь вида '$.a.b[0]'.
+func parseJSONPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(path, "{") && strings.HasSuffix(path, "}") {
+		inner := strings.TrimSpace(path[1 : len(path)-1])
+		if inner == "" {
+			return nil, nil
+		}
+		var steps []string
+		for _, step := range strings.Split(inner, ",") {
+			steps = append(steps, strings.Trim(strings.TrimSpace(step), `"`))
+		}
+		return steps, nil
+	}
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("неверный путь JSON '%s'", path)
+	}
+	var steps []string
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.Index(rest[1:], `"`)
+				if end == -1 {
+					return nil, fmt.Errorf("неверный путь JSON '%s'", path)
+				}
+				steps = append(steps, rest[1:end+1])
+				rest = rest[end+2:]
+				continue
+			}
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("неверный путь JSON '%s'", path)
+			}
+			steps = append(steps, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("неверный путь JSON '%s'", path)
+			}
+			if _, err := strconv.Atoi(rest[1:end]); err != nil {
+				return nil, fmt.Errorf("неверный индекс массива в пути JSON '%s'", path)
+			}
+			steps = append(steps, rest[1:end])
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("неверный путь JSON '%s'", path)
+		}
+	}
+	return steps, nil
+}
+
+// jsonStep возвращает поле объекта или элемент массива; отрицательный индекс
+// отсчитывается от конца массива.
+func jsonStep(element interface{}, key string) (interface{}, bool) {
+	switch v := element.(type) {
+	case map[string]interface{}:
+		child, ok := v[key]
+		return child, ok
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, false
+		}
+		if index < 0 {
+			index += len(v)
+		}
+		if index < 0 || index >= len(v) {
+			return nil, false
+		}
+		return v[index], true
+	}
+	return nil, false
+}
+
+func jsonGet(element interface{}, steps []string) (interface{}, bool) {
+	for _, step := range steps {
+		var ok bool
+		element, ok = jsonStep(element, step)
+		if !ok {
+			return nil, false
+		}
+	}
+	return element, true
+}
+
+// jsonSet возвращает копию элемента, в которой по пути steps записано value.
+// Недостающее последнее поле объекта создаётся, индекс, равный длине массива,
+// добавляет элемент в конец; если путь не существует, элемент не меняется.
+func jsonSet(element interface{}, steps []string, value interface{}) interface{} {
+	if len(steps) == 0 {
+		return value
+	}
+	switch v := element.(type) {
+	case map[string]interface{}:
+		child, exists := v[steps[0]]
+		if !exists && len(steps) > 1 {
+			return element
+		}
+		copied := make(map[string]interface{}, len(v)+1)
+		for key, item := range v {
+			copied[key] = item
+		}
+		copied[steps[0]] = jsonSet(child, steps[1:], value)
+		return copied
+	case []interface{}:
+		index, err := strconv.Atoi(steps[0])
+		if err != nil {
+			return element
+		}
+		if index < 0 {
+			index += len(v)
+		}
+		if index == len(v) && len(steps) == 1 {
+			return append(append([]interface{}(nil), v...), value)
+		}
+		if index < 0 || index >= len(v) {
+			return element
+		}
+		copied := append([]interface{}(nil), v...)
+		copied[index] = jsonSet(v[index], steps[1:], value)
+		return copied
+	}
+	return element
+}
+
+// jsonEqual сравнивает элементы документа; числа сравниваются по значению, поэтому 1 равно 1.0.
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, item := range av {
+			other, exists := bv[key]
+			if !exists || !jsonEqual(item, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		ad, okA := parseDecimal(string(av))
+		bd, okB := parseDecimal(string(bv))
+		return okA && okB && ad.Cmp(bd) == 0
+	}
+	return a == b
+}
+
+// jsonKey возвращает запись документа для ключа индекса, в которой равные по
+// jsonEqual числа записаны одинаково.
+func jsonKey(j JSONValue) string {
+	var normalize func(element interface{}) interface{}
+	normalize = func(element interface{}) interface{} {
+		switch v := element.(type) {
+		case map[string]interface{}:
+			copied := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				copied[key] = normalize(item)
+			}
+			return copied
+		case []interface{}:
+			copied := make([]interface{}, len(v))
+			for i, item := range v {
+				copied[i] = normalize(item)
+			}
+			return copied
+		case json.Number:
+			if d, ok := parseDecimal(string(v)); ok {
+				return json.Number(d.trim(0).String())
+			}
+		}
+		return element
+	}
+	return JSONValue{doc: normalize(j.doc)}.String()
+}
+
+// jsonContains проверяет вложение b в a по правилам оператора @> PostgreSQL:
+// объект содержит поля другого объекта, массив — все элементы другого массива
+// в любом порядке, а также отдельный скаляр.
+func jsonContains(a, b interface{}) bool {
+	switch bv := b.(type) {
+	case map[string]interface{}:
+		av, ok := a.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, item := range bv {
+			other, exists := av[key]
+			if !exists || !jsonContains(other, item) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		av, ok := a.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range bv {
+			found := false
+			for _, other := range av {
+				if jsonContains(other, item) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	if av, ok := a.([]interface{}); ok {
+		for _, other := range av {
+			if jsonEqual(other, b) {
+				return true
+			}
+		}
+		return false
+	}
+	return jsonEqual(a, b)
+}
+
+// jsonOperators — операторы доступа к документу и проверки вложения.
+var jsonOperators = []string{"->>", "->", "#>>", "#>", "@>", "<@"}
+
+func isJSONOperator(op string) bool {
+	for _, candidate := range jsonOperators {
+		if op == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// applyJSONOperator вычисляет doc -> key, doc ->> key, doc #> path, doc #>> path,
+// doc @> doc и doc <@ doc. Отсутствующий путь и NULL дают NULL.
+func applyJSONOperator(op string, left, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	doc, err := toJSON(left)
+	if err != nil {
+		return nil, fmt.Errorf("оператор '%s': %v", op, err)
+	}
+	switch op {
+	case "->", "->>":
+		var key string
+		switch v := right.(type) {
+		case string:
+			key = v
+		case int:
+			key = strconv.Itoa(v)
+		default:
+			return nil, fmt.Errorf("оператор '%s' ожидает ключ STRING или индекс INTEGER, получено %s", op, typeNameOf(right))
+		}
+		element, found := jsonStep(doc.doc, key)
+		if !found {
+			return nil, nil
+		}
+		if op == "->>" {
+			return jsonText(element), nil
+		}
+		return JSONValue{doc: element}, nil
+	case "#>", "#>>":
+		path, ok := right.(string)
+		if !ok {
+			return nil, fmt.Errorf("оператор '%s' ожидает путь STRING, получено %s", op, typeNameOf(right))
+		}
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		element, found := jsonGet(doc.doc, steps)
+		if !found {
+			return nil, nil
+		}
+		if op == "#>>" {
+			return jsonText(element), nil
+		}
+		return JSONValue{doc: element}, nil
+	case "@>", "<@":
+		other, err := toJSON(right)
+		if err != nil {
+			return nil, fmt.Errorf("оператор '%s': %v", op, err)
+		}
+		if op == "<@" {
+			return jsonContains(other.doc, doc.doc), nil
+		}
+		return jsonContains(doc.doc, other.doc), nil
+	}
+	return nil, fmt.Errorf("неизвестный оператор JSON '%s'", op)
+}
+
+// fnJSONExtract возвращает значение по пути: json_extract(doc, '$.a.b[0]').
+func fnJSONExtract(args []interface{}) (interface{}, error) {
+	if args[0] == nil || args[1] == nil {
+		return nil, nil
+	}
+	doc, err := toJSON(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("функция 'json_extract': %v", err)
+	}
+	steps, err := parseJSONPath(args[1].(string))
+	if err != nil {
+		return nil, err
+	}
+	element, found := jsonGet(doc.doc, steps)
+	if !found {
+		return nil, nil
+	}
+	return jsonToValue(element), nil
+}
+
+// fnJSONSet записывает значения по путям: json_set(doc, path, value [, path, value ...]).
+func fnJSONSet(args []interface{}) (interface{}, error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		return nil, fmt.Errorf("функция 'json_set' ожидает документ и пары путь-значение, получено %d аргумент(ов)", len(args))
+	}
+	if args[0] == nil {
+		return nil, nil
+	}
+	doc, err := toJSON(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("функция 'json_set': %v", err)
+	}
+	element := doc.doc
+	for i := 1; i < len(args); i += 2 {
+		path, ok := args[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("функция 'json_set' ожидает путь STRING, получено %s", typeNameOf(args[i]))
+		}
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		element = jsonSet(element, steps, jsonFromValue(args[i+1]))
+	}
+	return JSONValue{doc: element}, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newEventsDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, payload JSONB)",
+		`INSERT INTO events VALUES (1, '{"user": {"name": "ann", "age": 31}, "tags": ["a", "b"], "score": 1.10}')`,
+		`INSERT INTO events VALUES (2, '{ "tags" : ["c"], "user": {"age": 17, "name": "bob"} }')`,
+		"INSERT INTO events VALUES (3, NULL)",
+	)
+	return db
+}
+
+func TestJSONNormalizationAndOperators(t *testing.T) {
+	db := newEventsDatabase(t)
+	mustFail(t, db, `INSERT INTO events VALUES (4, '{"user": "broken"')`)
+
+	cases := map[string]string{
+		"SELECT payload FROM events WHERE id = 2":                                                              `[[{"tags":["c"],"user":{"age":17,"name":"bob"}}]]`,
+		"SELECT payload->'user'->>'name' FROM events ORDER BY id":                                              "[[ann] [bob] [<nil>]]",
+		"SELECT payload->'tags'->-1, payload->'tags'->>0 FROM events WHERE id = 1":                             `[["b" a]]`,
+		"SELECT payload#>'{user,age}', payload#>>'{user,name}' FROM events WHERE id = 2":                       "[[17 bob]]",
+		"SELECT payload->'missing' FROM events WHERE id = 1":                                                   "[[<nil>]]",
+		`SELECT id FROM events WHERE payload @> '{"tags": ["a"]}'`:                                             "[[1]]",
+		`SELECT id FROM events WHERE '{"user": {"name": "bob"}}' <@ payload`:                                   "[[2]]",
+		"SELECT id FROM events WHERE json_extract(payload, '$.user.age') > 30":                                 "[[1]]",
+		"SELECT json_extract(payload, '$.tags[0]'), json_extract(payload, '$.score') FROM events WHERE id = 1": "[[a 1.1]]",
+		`SELECT id FROM events WHERE payload->'user' = '{"name": "ann", "age": 31.0}'`:                         "[[1]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	mustExec(t, db, "UPDATE events SET payload = json_set(payload, '$.user.age', 32, '$.seen', TRUE) WHERE id = 1")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT payload FROM events WHERE id = 1")); got != `[[{"score":1.10,"seen":true,"tags":["a","b"],"user":{"age":32,"name":"ann"}}]]` {
+		t.Fatalf("после json_set: %s", got)
+	}
+}
+
+func TestJSONExpressionIndexAndPersistence(t *testing.T) {
+	db := newEventsDatabase(t)
+	mustExec(t, db,
+		"CREATE INDEX events_user ON events USING HASH ((payload->'user'->>'name'))",
+		"CREATE INDEX events_age ON events ((json_extract(payload, '$.user.age')))",
+	)
+	checkIndexes(t, db, "events")
+
+	reopened := NewDatabase()
+	checkIndexes(t, reopened, "events")
+	cases := map[string]string{
+		"SELECT id FROM events WHERE payload->'user'->>'name' = 'bob'":          "[[2]]",
+		"SELECT id FROM events WHERE json_extract(payload, '$.user.age') >= 18": "[[1]]",
+		"SELECT payload->>'score' FROM events WHERE id = 1":                     "[[1.10]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, reopened, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+}
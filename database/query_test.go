@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueryReportsColumnsAndRowsAffected(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name STRING)",
+		"INSERT INTO items VALUES (1, 'a')",
+		"INSERT INTO items VALUES (2, 'b')",
+		"CREATE MATERIALIZED VIEW item_names AS SELECT name FROM items",
+	)
+
+	for _, query := range []string{"SHOW TABLES", "DESCRIBE items", "SHOW INDEXES FROM items", "SHOW COLUMNS FROM items"} {
+		result, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if len(result.Rows) == 0 || len(result.Columns) != len(result.Rows[0]) {
+			t.Fatalf("%s: столбцы %v, строки %v", query, result.columnNames(), result.Rows)
+		}
+	}
+
+	for _, tc := range []struct {
+		query    string
+		affected int
+	}{
+		{"CREATE TABLE items_copy AS SELECT * FROM items", 2},
+		{"CREATE TABLE items_empty AS SELECT * FROM items WITH NO DATA", 0},
+		{"INSERT INTO items VALUES (3, 'c')", 1},
+		{"REFRESH MATERIALIZED VIEW item_names", 3},
+		{"TRUNCATE items_copy", 2},
+	} {
+		result, err := db.Query(tc.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.query, err)
+		}
+		if result.RowsAffected != tc.affected {
+			t.Fatalf("%s: RowsAffected = %d, ожидалось %d", tc.query, result.RowsAffected, tc.affected)
+		}
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT count(*) FROM items_copy")); got != "[[0]]" {
+		t.Fatalf("после TRUNCATE: %s", got)
+	}
+}
+
+func TestQueryOnConflictRowsAffected(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY, n INTEGER)",
+		"INSERT INTO a VALUES (1, 1)",
+	)
+	for _, tc := range []struct {
+		query    string
+		affected int
+		rows     string
+	}{
+		{"INSERT INTO a VALUES (2, 1) ON CONFLICT DO NOTHING RETURNING *", 1, "[[2 1]]"},
+		{"INSERT INTO a VALUES (2, 7) ON CONFLICT DO NOTHING RETURNING *", 0, "[]"},
+		{"INSERT INTO a VALUES (3, 1) ON CONFLICT (id) DO UPDATE SET n = excluded.n", 1, "[]"},
+		{"INSERT INTO a VALUES (3, 4) ON CONFLICT (id) DO UPDATE SET n = excluded.n RETURNING n", 1, "[[4]]"},
+	} {
+		result, err := db.Query(tc.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.query, err)
+		}
+		if result.RowsAffected != tc.affected || fmt.Sprint(result.Rows) != tc.rows {
+			t.Fatalf("%s: RowsAffected = %d, строки %v", tc.query, result.RowsAffected, result.Rows)
+		}
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, n FROM a ORDER BY id")); got != "[[1 1] [2 1] [3 4]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestQueryReturningKeepsDecimalPrecision(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db, "CREATE TABLE f (id INTEGER, d DECIMAL(8,2))")
+	want := ResultColumn{Name: "d", Type: DECIMAL, Precision: 8, Scale: 2}
+	for _, query := range []string{
+		"INSERT INTO f VALUES (1, 3.5) RETURNING d",
+		"UPDATE f SET d = 4 WHERE id = 1 RETURNING *",
+		"DELETE FROM f WHERE id = 1 RETURNING id, f.d AS d",
+	} {
+		result, err := db.Query(query)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		if got := result.Columns[len(result.Columns)-1]; got != want {
+			t.Fatalf("%s: столбец %+v", query, got)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package database
+
+// ResultColumn описывает столбец результата запроса; Precision и Scale задаются
+// для столбцов DECIMAL(p, s).
+type ResultColumn struct {
+	Name      string
+	Type      DataType
+	Precision int
+	Scale     int
+}
+
+// ResultSet — результат запроса вместе с метаданными столбцов. RowsAffected —
+// число строк, вставленных, изменённых или удалённых запросом, для SELECT — число
+// строк результата.
+type ResultSet struct {
+	Columns      []ResultColumn
+	Rows         [][]interface{}
+	RowsAffected int
+}
+
+func (rs *ResultSet) columnNames() []string {
+	names := make([]string, len(rs.Columns))
+	for i, col := range rs.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// parseReturning разбирает RETURNING * | выражение [AS псевдоним] [, ...] до конца
+// запроса, начиная с токена RETURNING. Элемент * хранится с пустым выражением.
+func parseReturning(db *Database, tokens []string, start int) ([]selectItem, error) {
+	if start >= len(tokens) {
+		return nil, nil
+	}
+	list := strings.TrimSpace(strings.Join(tokens[start+1:], " "))
+	if list == "" {
+		return nil, errors.New("неверный синтаксис RETURNING: отсутствуют выражения")
+	}
+	var items []selectItem
+	for _, text := range splitCSV(list) {
+		text = strings.TrimSpace(text)
+		if text == "*" {
+			items = append(items, selectItem{name: "*"})
+			continue
+		}
+		parsed, err := parseSelectItems(db, []string{text})
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис RETURNING: %v", err)
+		}
+		if containsAggregate(parsed[0].expr) || containsWindow(parsed[0].expr) {
+			return nil, errors.New("агрегатные и оконные функции недопустимы в RETURNING")
+		}
+		items = append(items, parsed[0])
+	}
+	return items, nil
+}
+
+// returningResult вычисляет список RETURNING по изменённым строкам таблицы.
+// Без RETURNING результат содержит только число затронутых строк.
+func returningResult(table *Table, items []selectItem, rows [][]interface{}) (*ResultSet, error) {
+	result := &ResultSet{RowsAffected: len(rows)}
+	if len(items) == 0 {
+		return result, nil
+	}
+	var columnNames []string
+	var columnTypes []DataType
+	for _, col := range table.Columns {
+		columnNames = append(columnNames, table.Name+"."+col.Name)
+		columnTypes = append(columnTypes, col.Type)
+	}
+	// Столбцы таблицы описываются по схеме, вместе с точностью DECIMAL.
+	var exprs []*Expr
+	for _, item := range items {
+		if item.expr != nil {
+			column := ResultColumn{Name: item.name}
+			if item.expr.Type == ColumnExpr {
+				if colIndex := findColumnIndex(columnNames, item.expr.Name); colIndex != -1 {
+					col := table.Columns[colIndex]
+					column = ResultColumn{Name: item.name, Type: col.Type, Precision: col.Precision, Scale: col.Scale}
+				}
+			}
+			result.Columns = append(result.Columns, column)
+			exprs = append(exprs, item.expr)
+			continue
+		}
+		for _, col := range table.Columns {
+			result.Columns = append(result.Columns, ResultColumn{Name: col.Name, Type: col.Type, Precision: col.Precision, Scale: col.Scale})
+			exprs = append(exprs, &Expr{Type: ColumnExpr, Name: col.Name})
+		}
+	}
+
+	result.Rows = make([][]interface{}, 0, len(rows))
+	for _, row := range rows {
+		values := make([]interface{}, len(exprs))
+		for i, expr := range exprs {
+			value, err := evaluateExpression(row, columnNames, expr)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка вычисления RETURNING: %v", err)
+			}
+			values[i] = value
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	for i, expr := range exprs {
+		if expr.Type == ColumnExpr && findColumnIndex(columnNames, expr.Name) != -1 {
+			continue
+		}
+		dataType, ok := expressionType(expr, columnNames, columnTypes)
+		if !ok {
+			dataType = inferColumnType(result.Rows, i)
+		}
+		result.Columns[i].Type = dataType
+	}
+	return result, nil
+}
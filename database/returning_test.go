@@ -0,0 +1,42 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReturningFromInsertUpdateDelete(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER AUTO_INCREMENT PRIMARY KEY, name STRING, age INTEGER, status STRING DEFAULT 'new')",
+		"INSERT INTO users (name, age) VALUES ('Ann', 30)",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO users (name, age) VALUES ('Dave', 41) RETURNING id, status")); got != "[[2 new]]" {
+		t.Fatalf("INSERT RETURNING: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO users (name, age) VALUES ('Eve', 20) RETURNING *")); got != "[[3 Eve 20 new]]" {
+		t.Fatalf("INSERT RETURNING *: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "UPDATE users SET age = age + 1 WHERE age > 25 RETURNING name, age * 2 AS doubled")); got != "[[Ann 62] [Dave 84]]" {
+		t.Fatalf("UPDATE RETURNING: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "UPDATE users SET age = 0 WHERE id = 42 RETURNING id")); got != "[]" {
+		t.Fatalf("UPDATE без строк: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "DELETE FROM users WHERE age < 25 RETURNING id, upper(name)")); got != "[[3 EVE]]" {
+		t.Fatalf("DELETE RETURNING: %s", got)
+	}
+	mustFail(t, db, "DELETE FROM users WHERE id = 1 RETURNING nosuch")
+	mustFail(t, db, "UPDATE users SET age = 1 RETURNING count(*)")
+
+	result, err := db.Query("DELETE FROM users WHERE id = 2 RETURNING name AS who, age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(result.columnNames(), result.Rows, result.RowsAffected); got != "[who age] [[Dave 42]] 1" {
+		t.Fatalf("Query: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, name, age FROM users")); got != "[[1 Ann 31]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// setOperator — оператор составного запроса: UNION, INTERSECT или EXCEPT, с ALL
+// или без (тогда повторяющиеся строки отбрасываются).
+type setOperator struct {
+	name string
+	all  bool
+}
+
+// executeCompound выполняет запрос, части которого соединены UNION, INTERSECT
+// или EXCEPT. INTERSECT связывает сильнее UNION и EXCEPT, части можно заключать
+// в скобки. ORDER BY, LIMIT и OFFSET после последней части относятся ко всему
+// результату; внутри части они допустимы только в скобках.
+func executeCompound(db *Database, tokens []string, scope relationScope) (*ResultSet, error) {
+	var operands [][]string
+	var operators []setOperator
+	start := 0
+	for i := findClause(tokens, 0, "UNION", "INTERSECT", "EXCEPT"); i < len(tokens); i = findClause(tokens, start, "UNION", "INTERSECT", "EXCEPT") {
+		operands = append(operands, tokens[start:i])
+		op := setOperator{name: strings.ToUpper(tokens[i])}
+		start = i + 1
+		if start < len(tokens) {
+			switch strings.ToUpper(tokens[start]) {
+			case "ALL":
+				op.all = true
+				start++
+			case "DISTINCT":
+				start++
+			}
+		}
+		operators = append(operators, op)
+	}
+	last := tokens[start:]
+	if len(operators) == 0 && (len(last) == 0 || last[0] != "(") {
+		return executeSimpleSelect(db, tokens, scope)
+	}
+	tailIndex := findClause(last, 0, "ORDER", "LIMIT", "OFFSET")
+	operands = append(operands, last[:tailIndex])
+	orderBy, limit, offset, err := parseSelectTail(db, last, tailIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*ResultSet, len(operands))
+	for i, operand := range operands {
+		if results[i], err = executeSetOperand(db, operand, scope); err != nil {
+			return nil, err
+		}
+	}
+	// Сначала выполняются INTERSECT, затем слева направо UNION и EXCEPT.
+	pending := []*ResultSet{results[0]}
+	var pendingOperators []setOperator
+	for i, op := range operators {
+		if op.name != "INTERSECT" {
+			pending = append(pending, results[i+1])
+			pendingOperators = append(pendingOperators, op)
+			continue
+		}
+		combined, err := combineResults(op, pending[len(pending)-1], results[i+1])
+		if err != nil {
+			return nil, err
+		}
+		pending[len(pending)-1] = combined
+	}
+	result := pending[0]
+	for i, op := range pendingOperators {
+		if result, err = combineResults(op, result, pending[i+1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(orderBy) > 0 {
+		if err := sortRows(result.Rows, result.columnNames(), orderBy); err != nil {
+			return nil, err
+		}
+	}
+	result.Rows = applyLimit(result.Rows, limit, offset)
+	return result, nil
+}
+
+// executeSetOperand выполняет часть составного запроса: SELECT или запрос в скобках.
+func executeSetOperand(db *Database, tokens []string, scope relationScope) (*ResultSet, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("неверный синтаксис составного запроса: отсутствует запрос")
+	}
+	if tokens[0] == "(" {
+		if matchingParen(tokens, 0, len(tokens)) != len(tokens)-1 {
+			return nil, errors.New("неверный синтаксис составного запроса: ожидался запрос в скобках")
+		}
+		return executeQuery(db, tokens[1:len(tokens)-1], scope)
+	}
+	if strings.ToUpper(tokens[0]) != "SELECT" {
+		return nil, fmt.Errorf("неверный синтаксис составного запроса: ожидался SELECT, получено '%s'", tokens[0])
+	}
+	if i := findClause(tokens, 0, "ORDER", "LIMIT", "OFFSET"); i < len(tokens) {
+		return nil, fmt.Errorf("%s внутри части составного запроса допустимо только в скобках", strings.ToUpper(tokens[i]))
+	}
+	return executeSimpleSelect(db, tokens, scope)
+}
+
+// combineResults соединяет результаты двух частей составного запроса. Строки
+// сравниваются так же, как ключи индексов, поэтому 1 и 1.0 совпадают.
+func combineResults(op setOperator, left, right *ResultSet) (*ResultSet, error) {
+	columns, err := setResultColumns(op.name, left, right)
+	if err != nil {
+		return nil, err
+	}
+	leftRows, err := convertRows(left.Rows, columns)
+	if err != nil {
+		return nil, err
+	}
+	rightRows, err := convertRows(right.Rows, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ResultSet{Columns: columns}
+	switch op.name {
+	case "UNION":
+		result.Rows = append(leftRows, rightRows...)
+		if !op.all {
+			result.Rows = distinctRows(result.Rows)
+		}
+	case "INTERSECT", "EXCEPT":
+		counts := make(map[string]int)
+		for _, row := range rightRows {
+			counts[encodeKey(row)]++
+		}
+		if !op.all {
+			leftRows = distinctRows(leftRows)
+		}
+		for _, row := range leftRows {
+			key := encodeKey(row)
+			found := counts[key] > 0
+			if found && op.all {
+				counts[key]--
+			}
+			if found == (op.name == "INTERSECT") {
+				result.Rows = append(result.Rows, row)
+			}
+		}
+	}
+	return result, nil
+}
+
+// setResultColumns проверяет, что части составного запроса совместимы: одинаковое
+// число столбцов, а типы столбцов совпадают или оба числовые. Имена столбцов берутся
+// из левой части; столбец, в котором нет значений, кроме NULL, совместим с любым.
+func setResultColumns(op string, left, right *ResultSet) ([]ResultColumn, error) {
+	if len(left.Columns) != len(right.Columns) {
+		return nil, fmt.Errorf("запросы в %s должны возвращать одинаковое число столбцов: %d и %d", op, len(left.Columns), len(right.Columns))
+	}
+	columns := make([]ResultColumn, len(left.Columns))
+	for i, col := range left.Columns {
+		leftType, rightType := col.Type, right.Columns[i].Type
+		columns[i] = ResultColumn{Name: col.Name, Type: leftType}
+		switch {
+		case leftType == rightType && col.Precision == right.Columns[i].Precision && col.Scale == right.Columns[i].Scale:
+			// Одинаковый DECIMAL(p, s) сохраняет точность.
+			columns[i] = col
+		case leftType == rightType || !hasValues(right.Rows, i):
+		case !hasValues(left.Rows, i):
+			columns[i].Type = rightType
+		case isNumericType(leftType) && isNumericType(rightType):
+			// Константы числовых типов упорядочены по ширине: INTEGER, FLOAT, DECIMAL.
+			columns[i].Type = max(leftType, rightType)
+		default:
+			return nil, fmt.Errorf("столбец %d в %s имеет несовместимые типы %s и %s", i+1, op, leftType, rightType)
+		}
+	}
+	return columns, nil
+}
+
+// hasValues сообщает, есть ли в столбце значения, отличные от NULL.
+func hasValues(rows [][]interface{}, column int) bool {
+	for _, row := range rows {
+		if column < len(row) && row[column] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// convertRows приводит значения строк к типам столбцов результата.
+func convertRows(rows [][]interface{}, columns []ResultColumn) ([][]interface{}, error) {
+	converted := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		converted[i] = make([]interface{}, len(columns))
+		for j, col := range columns {
+			if j >= len(row) || row[j] == nil {
+				continue
+			}
+			if dataType, _ := valueType(row[j]); dataType == col.Type {
+				converted[i][j] = row[j]
+				continue
+			}
+			value, err := coerceValue(row[j], col.Type)
+			if err != nil {
+				return nil, fmt.Errorf("столбец '%s': %v", col.Name, err)
+			}
+			converted[i][j] = value
+		}
+	}
+	return converted, nil
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE customers (id INTEGER, city STRING)",
+		"CREATE TABLE suppliers (id INTEGER, city STRING, rating FLOAT)",
+		"CREATE TABLE orders (customer_id INTEGER)",
+		"INSERT INTO customers VALUES (1, 'Oslo')",
+		"INSERT INTO customers VALUES (2, 'Rome')",
+		"INSERT INTO customers VALUES (3, 'Rome')",
+		"INSERT INTO customers VALUES (4, 'Kyiv')",
+		"INSERT INTO suppliers VALUES (1, 'Rome', 4.5)",
+		"INSERT INTO suppliers VALUES (2, 'Rome', 1.0)",
+		"INSERT INTO suppliers VALUES (3, 'Lima', 3.0)",
+		"INSERT INTO orders VALUES (1)",
+		"INSERT INTO orders VALUES (3)",
+		"INSERT INTO orders VALUES (3)",
+	)
+	cases := map[string]string{
+		"SELECT city FROM customers UNION SELECT city FROM suppliers ORDER BY city":                                                                "[[Kyiv] [Lima] [Oslo] [Rome]]",
+		"SELECT city FROM customers UNION ALL SELECT city FROM suppliers ORDER BY city":                                                            "[[Kyiv] [Lima] [Oslo] [Rome] [Rome] [Rome] [Rome]]",
+		"SELECT city FROM customers INTERSECT SELECT city FROM suppliers":                                                                          "[[Rome]]",
+		"SELECT city FROM customers INTERSECT ALL SELECT city FROM suppliers":                                                                      "[[Rome] [Rome]]",
+		"SELECT id FROM customers EXCEPT SELECT customer_id FROM orders ORDER BY id":                                                               "[[2] [4]]",
+		"SELECT customer_id FROM orders EXCEPT ALL SELECT id FROM customers WHERE id = 3":                                                          "[[1] [3]]",
+		"SELECT id FROM customers WHERE id = 1 UNION SELECT rating FROM suppliers WHERE id = 2":                                                    "[[1]]",
+		"SELECT id AS n FROM customers UNION SELECT rating FROM suppliers ORDER BY n DESC LIMIT 2":                                                 "[[4.5] [4]]",
+		"SELECT id FROM customers EXCEPT SELECT id FROM suppliers UNION SELECT 1 FROM orders ORDER BY id":                                          "[[1] [4]]",
+		"SELECT id FROM customers UNION SELECT id FROM suppliers INTERSECT SELECT customer_id FROM orders ORDER BY id":                             "[[1] [2] [3] [4]]",
+		"(SELECT city, rating FROM suppliers ORDER BY rating DESC LIMIT 1) UNION ALL (SELECT city, rating FROM suppliers ORDER BY rating LIMIT 1)": "[[Rome 4.5] [Rome 1]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT id, city FROM customers UNION SELECT id FROM suppliers")
+	mustFail(t, db, "SELECT city FROM customers UNION SELECT rating FROM suppliers")
+	mustFail(t, db, "SELECT id FROM customers UNION SELECT rating FROM suppliers ORDER BY rating")
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -14,25 +15,25 @@ const (
 	Compound
 )
 
+// Condition — условие WHERE. Левая часть простого условия — столбец Column или,
+// если это выражение (например, data->>'name'), Expr; тогда Column хранит его текст.
+// Правая часть — константа Value или, если она зависит от строки (lo < hi), ValueExpr.
 type Condition struct {
 	Type      ConditionType
 	Left      *Condition
 	Right     *Condition
 	LogicalOp string
 	Column    string
+	Expr      *Expr
 	Operator  string
 	Value     interface{}
+	ValueExpr *Expr
 }
 
 func ParseAndExecute(db *Database, query string) ([][]interface{}, error) {
-	query = strings.TrimSpace(query)
-	if strings.HasSuffix(query, ";") {
-		query = query[:len(query)-1]
-	}
-
-	tokens := tokenize(query)
-	if len(tokens) == 0 {
-		return nil, errors.New("пустой запрос")
+	query, tokens, err := prepareQuery(query)
+	if err != nil {
+		return nil, err
 	}
 
 	command := strings.ToUpper(tokens[0])
@@ -42,12 +43,24 @@ func ParseAndExecute(db *Database, query string) ([][]interface{}, error) {
 		return handleCreate(db, query, tokens)
 	case "INSERT":
 		return handleInsert(db, query, tokens)
-	case "SELECT":
+	case "SELECT", "WITH", "(":
 		return handleSelect(db, query, tokens)
 	case "UPDATE":
 		return handleUpdate(db, query, tokens)
 	case "DELETE":
 		return handleDelete(db, query, tokens)
+	case "DROP":
+		return handleDrop(db, query, tokens)
+	case "ALTER":
+		return handleAlter(db, query, tokens)
+	case "TRUNCATE":
+		return handleTruncate(db, query, tokens)
+	case "REFRESH":
+		return handleRefresh(db, query, tokens)
+	case "SHOW":
+		return resultRows(executeShow(db, tokens))
+	case "DESCRIBE", "DESC":
+		return resultRows(executeDescribe(db, tokens))
 	case "BEGIN":
 		err := db.BeginTransaction()
 		if err != nil {
@@ -69,16 +82,118 @@ func ParseAndExecute(db *Database, query string) ([][]interface{}, error) {
 		}
 		fmt.Println("Транзакция откатана.")
 		return nil, nil
+	case "SET":
+		return handleSet(db, query, tokens)
 	default:
 		return nil, fmt.Errorf("неизвестная команда '%s'", command)
 	}
 }
 
+// executeStatement выполняет запрос и возвращает результат с метаданными столбцов
+// и числом затронутых строк. Остальные команды выполняются как в ParseAndExecute.
+func executeStatement(db *Database, query string) (*ResultSet, error) {
+	_, tokens, err := prepareQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToUpper(tokens[0]) {
+	case "SELECT", "WITH", "(":
+		result, err := executeSelect(db, tokens)
+		if err != nil {
+			return nil, err
+		}
+		result.RowsAffected = len(result.Rows)
+		return result, nil
+	case "INSERT":
+		return executeInsert(db, tokens)
+	case "UPDATE":
+		return executeUpdate(db, tokens)
+	case "DELETE":
+		return executeDelete(db, tokens)
+	case "CREATE":
+		return executeCreate(db, query, tokens)
+	case "TRUNCATE":
+		return executeTruncate(db, tokens)
+	case "REFRESH":
+		return executeRefresh(db, tokens)
+	case "SHOW":
+		return executeShow(db, tokens)
+	case "DESCRIBE", "DESC":
+		return executeDescribe(db, tokens)
+	}
+	rows, err := ParseAndExecute(db, query)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultSet{Rows: rows}, nil
+}
+
+// resultRows возвращает строки результата команды для ParseAndExecute.
+func resultRows(result *ResultSet, err error) ([][]interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// prepareQuery убирает завершающую точку с запятой и разбивает запрос на токены.
+func prepareQuery(query string) (string, []string, error) {
+	query = strings.TrimSpace(query)
+	if strings.HasSuffix(query, ";") {
+		query = query[:len(query)-1]
+	}
+
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return "", nil, errors.New("пустой запрос")
+	}
+	return query, tokens, nil
+}
+
 func handleCreate(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	return resultRows(executeCreate(db, query, tokens))
+}
+
+// executeCreate выполняет CREATE TABLE, CREATE INDEX и CREATE VIEW. Для CREATE TABLE AS
+// RowsAffected — число скопированных строк.
+func executeCreate(db *Database, query string, tokens []string) (*ResultSet, error) {
+	if len(tokens) > 2 && (strings.ToUpper(tokens[1]) == "INDEX" || (strings.ToUpper(tokens[1]) == "UNIQUE" && strings.ToUpper(tokens[2]) == "INDEX")) {
+		if _, err := handleCreateIndex(db, tokens); err != nil {
+			return nil, err
+		}
+		return &ResultSet{}, nil
+	}
+	if len(tokens) > 2 && (strings.ToUpper(tokens[1]) == "VIEW" || (strings.ToUpper(tokens[1]) == "MATERIALIZED" && strings.ToUpper(tokens[2]) == "VIEW")) {
+		if _, err := handleCreateView(db, tokens); err != nil {
+			return nil, err
+		}
+		return &ResultSet{}, nil
+	}
 	if len(tokens) < 3 || strings.ToUpper(tokens[1]) != "TABLE" {
 		return nil, errors.New("неверный синтаксис CREATE TABLE")
 	}
-	tableName := tokens[2]
+	i := 2
+	ifNotExists := false
+	if i+2 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "NOT" && strings.ToUpper(tokens[i+2]) == "EXISTS" {
+		ifNotExists = true
+		i += 3
+	}
+	if i >= len(tokens) {
+		return nil, errors.New("неверный синтаксис CREATE TABLE")
+	}
+	tableName := tokens[i]
+	if ifNotExists {
+		db.mu.RLock()
+		_, exists := db.Tables[strings.ToLower(tableName)]
+		db.mu.RUnlock()
+		if exists {
+			fmt.Printf("Таблица '%s' уже существует, пропуск.\n", tableName)
+			return &ResultSet{}, nil
+		}
+	}
+	if asIndex := findClause(tokens, i+1, "AS"); asIndex < len(tokens) {
+		return executeCreateTableAs(db, tableName, tokens, i+1, asIndex)
+	}
 	columnsDefStart := strings.Index(query, "(")
 	columnsDefEnd := strings.LastIndex(query, ")")
 	if columnsDefStart == -1 || columnsDefEnd == -1 || columnsDefEnd < columnsDefStart {
@@ -87,95 +202,1076 @@ func handleCreate(db *Database, query string, tokens []string) ([][]interface{},
 	columnsDef := query[columnsDefStart+1 : columnsDefEnd]
 	columnsParts := splitCSV(columnsDef)
 	var columns []Column
+	var constraints []Constraint
+	var indexes []*Index
 	for _, part := range columnsParts {
-		col := strings.Fields(strings.TrimSpace(part))
-		if len(col) < 2 {
-			return nil, errors.New("неверный синтаксис определения столбца")
-		}
-		colName := col[0]
-		var colType DataType
-		switch strings.ToUpper(col[1]) {
-		case "STRING":
-			colType = STRING
-		case "INTEGER":
-			colType = INTEGER
-		case "FLOAT":
-			colType = FLOAT
+		col := tokenize(strings.TrimSpace(part))
+		if len(col) > 0 && strings.ToUpper(col[0]) == "LIKE" {
+			likeColumns, likeConstraints, likeIndexes, err := parseLikeClause(db, col)
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, likeColumns...)
+			constraints = append(constraints, likeConstraints...)
+			indexes = append(indexes, likeIndexes...)
+			continue
+		}
+		if len(col) > 0 && isTableConstraintStart(col[0]) {
+			constraint, err := parseTableConstraint(col)
+			if err != nil {
+				return nil, err
+			}
+			constraints = append(constraints, constraint)
+			continue
+		}
+		column, columnConstraints, err := parseColumnDefinition(db, col)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, columnConstraints...)
+		columns = append(columns, column)
+	}
+	err := db.CreateTableWithIndexes(tableName, columns, constraints, indexes)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Таблица создана успешно.")
+	return &ResultSet{}, nil
+}
+
+// parseLikeClause разбирает LIKE name [{INCLUDING | EXCLUDING} {DEFAULTS | CONSTRAINTS | INDEXES | IDENTITY | ALL}] ...
+func parseLikeClause(db *Database, tokens []string) ([]Column, []Constraint, []*Index, error) {
+	if len(tokens) < 2 {
+		return nil, nil, nil, errors.New("неверный синтаксис LIKE: отсутствует имя таблицы")
+	}
+	including := make(map[string]bool)
+	for i := 2; i < len(tokens); i += 2 {
+		mode := strings.ToUpper(tokens[i])
+		if (mode != "INCLUDING" && mode != "EXCLUDING") || i+1 >= len(tokens) {
+			return nil, nil, nil, fmt.Errorf("неверный синтаксис LIKE: неожиданный токен '%s'", tokens[i])
+		}
+		var options []string
+		switch option := strings.ToUpper(tokens[i+1]); option {
+		case "ALL":
+			options = []string{"DEFAULTS", "CONSTRAINTS", "INDEXES", "IDENTITY"}
+		case "DEFAULTS", "CONSTRAINTS", "INDEXES", "IDENTITY":
+			options = []string{option}
 		default:
-			return nil, fmt.Errorf("неизвестный тип данных '%s'", col[1])
+			return nil, nil, nil, fmt.Errorf("неизвестный параметр LIKE '%s'", tokens[i+1])
+		}
+		for _, option := range options {
+			including[option] = mode == "INCLUDING"
+		}
+	}
+	return db.likeDefinition(tokens[1], including)
+}
+
+// executeCreateTableAs разбирает CREATE TABLE name [(col, ...)] AS SELECT ... [WITH [NO] DATA].
+func executeCreateTableAs(db *Database, tableName string, tokens []string, start, asIndex int) (*ResultSet, error) {
+	columnNames, selectTokens, withData, err := parseAsSelect(tokens, start, asIndex)
+	if err != nil {
+		return nil, fmt.Errorf("неверный синтаксис CREATE TABLE AS: %v", err)
+	}
+	result, err := executeSelect(db, selectTokens)
+	if err != nil {
+		return nil, err
+	}
+	err = db.CreateTableAs(tableName, columnNames, result, withData)
+	if err != nil {
+		return nil, err
+	}
+	copied := 0
+	if withData {
+		copied = len(result.Rows)
+	}
+	fmt.Printf("Таблица создана успешно. Скопировано строк: %d.\n", copied)
+	return &ResultSet{RowsAffected: copied}, nil
+}
+
+// isQueryStart сообщает, что с токена начинается запрос: SELECT, WITH или
+// часть составного запроса в скобках.
+func isQueryStart(token string) bool {
+	upper := strings.ToUpper(token)
+	return upper == "SELECT" || upper == "WITH" || upper == "("
+}
+
+// parseAsSelect разбирает часть "[(col, ...)] AS SELECT ... [WITH [NO] DATA]", где
+// start указывает на необязательный список столбцов, а asIndex — на AS.
+func parseAsSelect(tokens []string, start, asIndex int) ([]string, []string, bool, error) {
+	var columnNames []string
+	if start < asIndex {
+		names, next, err := parseIdentifierList(tokens, start)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if next != asIndex {
+			return nil, nil, false, fmt.Errorf("неожиданный токен '%s'", tokens[next])
+		}
+		columnNames = names
+	}
+	selectTokens := tokens[asIndex+1:]
+	withData := true
+	if n := len(selectTokens); n >= 2 && strings.ToUpper(selectTokens[n-2]) == "WITH" && strings.ToUpper(selectTokens[n-1]) == "DATA" {
+		selectTokens = selectTokens[:n-2]
+	} else if n >= 3 && strings.ToUpper(selectTokens[n-3]) == "WITH" && strings.ToUpper(selectTokens[n-2]) == "NO" && strings.ToUpper(selectTokens[n-1]) == "DATA" {
+		selectTokens = selectTokens[:n-3]
+		withData = false
+	}
+	if len(selectTokens) == 0 || !isQueryStart(selectTokens[0]) {
+		return nil, nil, false, errors.New("ожидается SELECT")
+	}
+	return columnNames, selectTokens, withData, nil
+}
+
+// handleCreateView разбирает CREATE [MATERIALIZED] VIEW [IF NOT EXISTS] name [(col, ...)] AS SELECT ...
+// [WITH [NO] DATA]; WITH DATA допустимо только для материализованных представлений.
+func handleCreateView(db *Database, tokens []string) ([][]interface{}, error) {
+	i := 1
+	materialized := false
+	if strings.ToUpper(tokens[i]) == "MATERIALIZED" {
+		materialized = true
+		i++
+	}
+	i++
+	ifNotExists := false
+	if i+2 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "NOT" && strings.ToUpper(tokens[i+2]) == "EXISTS" {
+		ifNotExists = true
+		i += 3
+	}
+	if i >= len(tokens) {
+		return nil, errors.New("неверный синтаксис CREATE VIEW")
+	}
+	viewName := tokens[i]
+	asIndex := findClause(tokens, i+1, "AS")
+	if asIndex == len(tokens) {
+		return nil, errors.New("неверный синтаксис CREATE VIEW: отсутствует AS SELECT")
+	}
+	columnNames, selectTokens, withData, err := parseAsSelect(tokens, i+1, asIndex)
+	if err != nil {
+		return nil, fmt.Errorf("неверный синтаксис CREATE VIEW: %v", err)
+	}
+	if !materialized && len(selectTokens) != len(tokens)-asIndex-1 {
+		return nil, errors.New("WITH DATA допустимо только для материализованных представлений")
+	}
+	if ifNotExists {
+		db.mu.RLock()
+		_, exists := db.Views[strings.ToLower(viewName)]
+		db.mu.RUnlock()
+		if exists {
+			fmt.Printf("Представление '%s' уже существует, пропуск.\n", viewName)
+			return nil, nil
+		}
+	}
+	err = db.CreateView(viewName, columnNames, strings.Join(selectTokens, " "), materialized, withData)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Представление создано успешно.")
+	return nil, nil
+}
+
+// executeShow разбирает SHOW TABLES, SHOW COLUMNS FROM name и SHOW INDEXES [FROM name].
+func executeShow(db *Database, tokens []string) (*ResultSet, error) {
+	if len(tokens) < 2 {
+		return nil, errors.New("неверный синтаксис SHOW")
+	}
+	switch strings.ToUpper(tokens[1]) {
+	case "TABLES":
+		if len(tokens) != 2 {
+			return nil, errors.New("неверный синтаксис SHOW TABLES")
+		}
+		return db.ShowTables(), nil
+	case "COLUMNS":
+		if len(tokens) != 4 || (strings.ToUpper(tokens[2]) != "FROM" && strings.ToUpper(tokens[2]) != "IN") {
+			return nil, errors.New("неверный синтаксис SHOW COLUMNS: ожидается SHOW COLUMNS FROM name")
 		}
+		return db.DescribeTable(tokens[3])
+	case "INDEXES", "INDEX":
+		tableName := ""
+		switch {
+		case len(tokens) == 4 && (strings.ToUpper(tokens[2]) == "FROM" || strings.ToUpper(tokens[2]) == "IN"):
+			tableName = tokens[3]
+		case len(tokens) != 2:
+			return nil, errors.New("неверный синтаксис SHOW INDEXES")
+		}
+		return db.ShowIndexes(tableName)
+	default:
+		return nil, fmt.Errorf("неизвестный объект SHOW '%s'", tokens[1])
+	}
+}
+
+// executeDescribe выполняет DESCRIBE name.
+func executeDescribe(db *Database, tokens []string) (*ResultSet, error) {
+	if len(tokens) != 2 {
+		return nil, errors.New("неверный синтаксис DESCRIBE")
+	}
+	return db.DescribeTable(tokens[1])
+}
 
-		autoIncrement := false
-		if len(col) > 2 && strings.ToUpper(col[2]) == "AUTO_INCREMENT" {
+func handleRefresh(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeRefresh(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Представление обновлено успешно (строк: %d).\n", result.RowsAffected)
+	return nil, nil
+}
+
+// executeRefresh выполняет REFRESH MATERIALIZED VIEW; RowsAffected — число строк представления.
+func executeRefresh(db *Database, tokens []string) (*ResultSet, error) {
+	if len(tokens) != 4 || strings.ToUpper(tokens[1]) != "MATERIALIZED" || strings.ToUpper(tokens[2]) != "VIEW" {
+		return nil, errors.New("неверный синтаксис REFRESH MATERIALIZED VIEW")
+	}
+	refreshed, err := db.refreshMaterializedView(tokens[3])
+	if err != nil {
+		return nil, err
+	}
+	return &ResultSet{RowsAffected: refreshed}, nil
+}
+
+// parseColumnDefinition разбирает определение столбца "name type [атрибуты]" из CREATE TABLE
+// и ALTER TABLE ADD COLUMN.
+func parseColumnDefinition(db *Database, col []string) (Column, []Constraint, error) {
+	var constraints []Constraint
+	if len(col) < 2 {
+		return Column{}, nil, errors.New("неверный синтаксис определения столбца")
+	}
+	colName := col[0]
+	column, typeEnd, err := parseColumnType(col, 1)
+	if err != nil {
+		return Column{}, nil, err
+	}
+	column.Name = colName
+	colType := column.Type
+
+	constraintName := ""
+	for i := typeEnd; i < len(col); i++ {
+		switch strings.ToUpper(col[i]) {
+		case "AUTO_INCREMENT":
 			if colType != INTEGER {
-				return nil, errors.New("AUTO_INCREMENT поддерживается только для INTEGER типов")
+				return Column{}, nil, errors.New("AUTO_INCREMENT поддерживается только для INTEGER типов")
+			}
+			column.AutoIncrement = true
+		case "CONSTRAINT":
+			if i+1 >= len(col) {
+				return Column{}, nil, fmt.Errorf("неверный синтаксис CONSTRAINT для столбца '%s'", colName)
 			}
-			autoIncrement = true
+			constraintName = strings.ToLower(col[i+1])
+			i++
+			continue
+		case "PRIMARY":
+			if i+1 >= len(col) || strings.ToUpper(col[i+1]) != "KEY" {
+				return Column{}, nil, fmt.Errorf("неверный синтаксис PRIMARY KEY для столбца '%s'", colName)
+			}
+			i++
+			constraints = append(constraints, Constraint{Name: constraintName, Type: PrimaryKeyConstraint, Columns: []string{colName}})
+		case "UNIQUE":
+			constraints = append(constraints, Constraint{Name: constraintName, Type: UniqueConstraint, Columns: []string{colName}})
+		case "NOT":
+			if i+1 >= len(col) || strings.ToUpper(col[i+1]) != "NULL" {
+				return Column{}, nil, fmt.Errorf("неверный синтаксис NOT NULL для столбца '%s'", colName)
+			}
+			i++
+			column.NotNull = true
+		case "NULL":
+			column.NotNull = false
+		case "DEFAULT":
+			_, next, err := parseExpression(db, col, i+1, len(col))
+			if err != nil {
+				return Column{}, nil, fmt.Errorf("неверное значение DEFAULT для столбца '%s': %v", colName, err)
+			}
+			column.Default = strings.Join(col[i+1:next], " ")
+			i = next - 1
+		case "REFERENCES":
+			constraint := Constraint{Name: constraintName, Type: ForeignKeyConstraint, Columns: []string{colName}}
+			next, err := parseReferences(col, i+1, &constraint)
+			if err != nil {
+				return Column{}, nil, fmt.Errorf("неверный синтаксис REFERENCES для столбца '%s': %v", colName, err)
+			}
+			constraints = append(constraints, constraint)
+			i = next - 1
+		case "CHECK":
+			expression, next, err := parseParenthesized(col, i+1)
+			if err != nil {
+				return Column{}, nil, fmt.Errorf("неверный синтаксис CHECK для столбца '%s': %v", colName, err)
+			}
+			constraints = append(constraints, Constraint{Name: constraintName, Type: CheckConstraint, Columns: []string{colName}, Expression: expression})
+			i = next - 1
+		default:
+			return Column{}, nil, fmt.Errorf("неизвестный атрибут столбца '%s'", col[i])
 		}
+		constraintName = ""
+	}
+	return column, constraints, nil
+}
 
-		columns = append(columns, Column{Name: colName, Type: colType, AutoIncrement: autoIncrement})
+// parseColumnType разбирает тип столбца вместе с точностью DECIMAL(p, s) и
+// возвращает индекс токена после него.
+func parseColumnType(tokens []string, start int) (Column, int, error) {
+	dataType, err := parseDataType(tokens[start])
+	if err != nil {
+		return Column{}, start, err
+	}
+	column := Column{Type: dataType}
+	next := start + 1
+	if next >= len(tokens) || tokens[next] != "(" {
+		return column, next, nil
+	}
+	if dataType != DECIMAL {
+		return Column{}, next, fmt.Errorf("тип %s не принимает параметров", dataType)
 	}
-	err := db.CreateTable(tableName, columns)
+	end := next + 1
+	for end < len(tokens) && tokens[end] != ")" {
+		end++
+	}
+	var params []int
+	for i := next + 1; i < end; i++ {
+		if (i-next)%2 == 0 {
+			if tokens[i] != "," {
+				return Column{}, i, errors.New("неверный синтаксис DECIMAL(p, s)")
+			}
+			continue
+		}
+		n, err := strconv.Atoi(tokens[i])
+		if err != nil {
+			return Column{}, i, errors.New("неверный синтаксис DECIMAL(p, s)")
+		}
+		params = append(params, n)
+	}
+	if end >= len(tokens) || len(params) == 0 || len(params) > 2 || (end-next)%2 != 0 {
+		return Column{}, end, errors.New("неверный синтаксис DECIMAL(p, s)")
+	}
+	column.Precision = params[0]
+	if len(params) == 2 {
+		column.Scale = params[1]
+	}
+	if column.Precision < 1 || column.Precision > maxDecimalPrecision {
+		return Column{}, end, fmt.Errorf("точность DECIMAL должна быть от 1 до %d", maxDecimalPrecision)
+	}
+	if column.Scale < 0 || column.Scale > column.Precision {
+		return Column{}, end, fmt.Errorf("масштаб DECIMAL должен быть от 0 до %d", column.Precision)
+	}
+	return column, end + 1, nil
+}
+
+func parseDataType(name string) (DataType, error) {
+	switch strings.ToUpper(name) {
+	case "STRING", "TEXT":
+		return STRING, nil
+	case "INTEGER", "INT":
+		return INTEGER, nil
+	case "FLOAT":
+		return FLOAT, nil
+	case "BOOLEAN", "BOOL":
+		return BOOLEAN, nil
+	case "DATE":
+		return DATE, nil
+	case "TIME":
+		return TIME, nil
+	case "TIMESTAMP":
+		return TIMESTAMP, nil
+	case "TIMESTAMPTZ":
+		return TIMESTAMPTZ, nil
+	case "INTERVAL":
+		return INTERVAL, nil
+	case "DECIMAL", "NUMERIC":
+		return DECIMAL, nil
+	case "JSON", "JSONB":
+		return JSON, nil
+	case "BYTEA", "BLOB":
+		return BYTEA, nil
+	case "UUID":
+		return UUID, nil
+	default:
+		return 0, fmt.Errorf("неизвестный тип данных '%s'", name)
+	}
+}
+
+func isTableConstraintStart(token string) bool {
+	switch strings.ToUpper(token) {
+	case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+		return true
+	}
+	return false
+}
+
+func parseTableConstraint(tokens []string) (Constraint, error) {
+	var constraint Constraint
+	i := 0
+	if strings.ToUpper(tokens[i]) == "CONSTRAINT" {
+		if len(tokens) < 3 {
+			return constraint, errors.New("неверный синтаксис CONSTRAINT: отсутствует имя ограничения")
+		}
+		constraint.Name = strings.ToLower(tokens[1])
+		i = 2
+	}
+	switch strings.ToUpper(tokens[i]) {
+	case "PRIMARY":
+		if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "KEY" {
+			return constraint, errors.New("неверный синтаксис PRIMARY KEY")
+		}
+		constraint.Type = PrimaryKeyConstraint
+		i += 2
+	case "UNIQUE":
+		constraint.Type = UniqueConstraint
+		i++
+	case "CHECK":
+		expression, next, err := parseParenthesized(tokens, i+1)
+		if err != nil {
+			return constraint, fmt.Errorf("неверный синтаксис CHECK: %v", err)
+		}
+		if next != len(tokens) {
+			return constraint, fmt.Errorf("неожиданный токен '%s' в определении ограничения", tokens[next])
+		}
+		constraint.Type = CheckConstraint
+		constraint.Expression = expression
+		return constraint, nil
+	case "FOREIGN":
+		if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "KEY" {
+			return constraint, errors.New("неверный синтаксис FOREIGN KEY")
+		}
+		columns, next, err := parseIdentifierList(tokens, i+2)
+		if err != nil {
+			return constraint, err
+		}
+		if next >= len(tokens) || strings.ToUpper(tokens[next]) != "REFERENCES" {
+			return constraint, errors.New("неверный синтаксис FOREIGN KEY: ожидается REFERENCES")
+		}
+		constraint.Type = ForeignKeyConstraint
+		constraint.Columns = columns
+		next, err = parseReferences(tokens, next+1, &constraint)
+		if err != nil {
+			return constraint, err
+		}
+		if next != len(tokens) {
+			return constraint, fmt.Errorf("неожиданный токен '%s' в определении ограничения", tokens[next])
+		}
+		return constraint, nil
+	default:
+		return constraint, fmt.Errorf("неизвестный тип ограничения '%s'", tokens[i])
+	}
+	columns, next, err := parseIdentifierList(tokens, i)
+	if err != nil {
+		return constraint, err
+	}
+	if next != len(tokens) {
+		return constraint, fmt.Errorf("неожиданный токен '%s' в определении ограничения", tokens[next])
+	}
+	constraint.Columns = columns
+	return constraint, nil
+}
+
+// parseReferences разбирает "parent [(cols)] [ON DELETE action] [ON UPDATE action]
+// [[NOT] DEFERRABLE] [INITIALLY DEFERRED|IMMEDIATE]", начиная с имени родительской таблицы.
+func parseReferences(tokens []string, start int, constraint *Constraint) (int, error) {
+	if start >= len(tokens) {
+		return start, errors.New("отсутствует имя родительской таблицы")
+	}
+	constraint.RefTable = tokens[start]
+	i := start + 1
+	if i < len(tokens) && tokens[i] == "(" {
+		refColumns, next, err := parseIdentifierList(tokens, i)
+		if err != nil {
+			return next, err
+		}
+		constraint.RefColumns = refColumns
+		i = next
+	}
+	for i < len(tokens) {
+		upperToken := strings.ToUpper(tokens[i])
+		switch {
+		case upperToken == "ON" && i+1 < len(tokens):
+			event := strings.ToUpper(tokens[i+1])
+			action, next, err := parseReferentialAction(tokens, i+2)
+			if err != nil {
+				return next, err
+			}
+			switch event {
+			case "DELETE":
+				constraint.OnDelete = action
+			case "UPDATE":
+				constraint.OnUpdate = action
+			default:
+				return i, fmt.Errorf("ожидается ON DELETE или ON UPDATE, получено '%s'", tokens[i+1])
+			}
+			i = next
+		case upperToken == "DEFERRABLE":
+			constraint.Deferrable = true
+			i++
+		case upperToken == "NOT" && i+1 < len(tokens) && strings.ToUpper(tokens[i+1]) == "DEFERRABLE":
+			constraint.Deferrable = false
+			i += 2
+		case upperToken == "INITIALLY" && i+1 < len(tokens):
+			switch strings.ToUpper(tokens[i+1]) {
+			case "DEFERRED":
+				constraint.InitiallyDeferred = true
+			case "IMMEDIATE":
+				constraint.InitiallyDeferred = false
+			default:
+				return i, fmt.Errorf("ожидается INITIALLY DEFERRED или INITIALLY IMMEDIATE, получено '%s'", tokens[i+1])
+			}
+			i += 2
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+func parseReferentialAction(tokens []string, start int) (string, int, error) {
+	if start >= len(tokens) {
+		return "", start, errors.New("отсутствует действие внешнего ключа")
+	}
+	switch strings.ToUpper(tokens[start]) {
+	case "CASCADE":
+		return Cascade, start + 1, nil
+	case "RESTRICT":
+		return Restrict, start + 1, nil
+	case "NO":
+		if start+1 < len(tokens) && strings.ToUpper(tokens[start+1]) == "ACTION" {
+			return NoAction, start + 2, nil
+		}
+	case "SET":
+		if start+1 < len(tokens) {
+			switch strings.ToUpper(tokens[start+1]) {
+			case "NULL":
+				return SetNull, start + 2, nil
+			case "DEFAULT":
+				return SetDefault, start + 2, nil
+			}
+		}
+	}
+	return "", start, fmt.Errorf("неизвестное действие внешнего ключа '%s'", tokens[start])
+}
+
+// parseParenthesized возвращает текст между парными скобками, начиная с tokens[start].
+func parseParenthesized(tokens []string, start int) (string, int, error) {
+	if start >= len(tokens) || tokens[start] != "(" {
+		return "", start, errors.New("ожидается выражение в скобках")
+	}
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				if i == start+1 {
+					return "", i, errors.New("пустое выражение в скобках")
+				}
+				return strings.Join(tokens[start+1:i], " "), i + 1, nil
+			}
+		}
+	}
+	return "", len(tokens), errors.New("не найдена закрывающая скобка")
+}
+
+func parseIdentifierList(tokens []string, start int) ([]string, int, error) {
+	if start >= len(tokens) || tokens[start] != "(" {
+		return nil, start, errors.New("ожидается список столбцов в скобках")
+	}
+	var names []string
+	i := start + 1
+	for i < len(tokens) {
+		if tokens[i] == ")" {
+			if len(names) == 0 {
+				return nil, i, errors.New("пустой список столбцов")
+			}
+			return names, i + 1, nil
+		}
+		if tokens[i] == "," {
+			i++
+			continue
+		}
+		names = append(names, tokens[i])
+		i++
+	}
+	return nil, i, errors.New("не найдена закрывающая скобка списка столбцов")
+}
+
+// parseIndexColumns разбирает список элементов индекса: столбцы и выражения
+// в скобках, например (id, (data->>'name')).
+func parseIndexColumns(db *Database, tokens []string, start int) ([]string, int, error) {
+	if start >= len(tokens) || tokens[start] != "(" {
+		return nil, start, errors.New("ожидается список столбцов в скобках")
+	}
+	var columns []string
+	i := start + 1
+	for i < len(tokens) {
+		if tokens[i] == "(" {
+			expr, next, err := parseExpression(db, tokens, i+1, len(tokens))
+			if err != nil {
+				return nil, next, err
+			}
+			if next >= len(tokens) || tokens[next] != ")" {
+				return nil, next, errors.New("не найдена закрывающая скобка выражения")
+			}
+			columns = append(columns, exprString(expr))
+			i = next + 1
+		} else if tokens[i] != ")" {
+			columns = append(columns, tokens[i])
+			i++
+		}
+		if i < len(tokens) && tokens[i] == ")" {
+			if len(columns) == 0 {
+				return nil, i, errors.New("пустой список столбцов")
+			}
+			return columns, i + 1, nil
+		}
+		if i >= len(tokens) || tokens[i] != "," {
+			break
+		}
+		i++
+	}
+	return nil, i, errors.New("не найдена закрывающая скобка списка столбцов")
+}
+
+// handleCreateIndex разбирает CREATE [UNIQUE] INDEX [IF NOT EXISTS] name ON table [USING method] (col | (expr), ...).
+func handleCreateIndex(db *Database, tokens []string) ([][]interface{}, error) {
+	i := 1
+	unique := false
+	if strings.ToUpper(tokens[i]) == "UNIQUE" {
+		unique = true
+		i++
+	}
+	i++
+	ifNotExists := false
+	if i+2 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "NOT" && strings.ToUpper(tokens[i+2]) == "EXISTS" {
+		ifNotExists = true
+		i += 3
+	}
+	if i+2 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "ON" {
+		return nil, errors.New("неверный синтаксис CREATE INDEX")
+	}
+	indexName := tokens[i]
+	tableName := tokens[i+2]
+	i += 3
+	method := ""
+	if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "USING" {
+		method = tokens[i+1]
+		i += 2
+	}
+	columns, next, err := parseIndexColumns(db, tokens, i)
+	if err != nil {
+		return nil, fmt.Errorf("неверный синтаксис CREATE INDEX: %v", err)
+	}
+	if next != len(tokens) {
+		return nil, fmt.Errorf("неверный синтаксис CREATE INDEX: неожиданный токен '%s'", tokens[next])
+	}
+	if ifNotExists {
+		db.mu.RLock()
+		_, existing := db.findIndexTable(indexName)
+		db.mu.RUnlock()
+		if existing != nil {
+			fmt.Printf("Индекс '%s' уже существует, пропуск.\n", indexName)
+			return nil, nil
+		}
+	}
+	err = db.CreateIndex(indexName, tableName, columns, unique, method)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Таблица создана успешно.")
+	fmt.Println("Индекс создан успешно.")
+	return nil, nil
+}
+
+func handleDrop(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	if len(tokens) < 3 {
+		return nil, errors.New("неверный синтаксис DROP")
+	}
+	switch strings.ToUpper(tokens[1]) {
+	case "INDEX":
+		i := 2
+		ifExists := false
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "EXISTS" {
+			ifExists = true
+			i += 2
+		}
+		if i+1 != len(tokens) {
+			return nil, errors.New("неверный синтаксис DROP INDEX")
+		}
+		db.mu.RLock()
+		_, existing := db.findIndexTable(tokens[i])
+		db.mu.RUnlock()
+		if existing == nil && ifExists {
+			fmt.Printf("Индекс '%s' не существует, пропуск.\n", tokens[i])
+			return nil, nil
+		}
+		err := db.DropIndex(tokens[i])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Индекс удалён успешно.")
+		return nil, nil
+	case "TABLE":
+		i := 2
+		ifExists := false
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "EXISTS" {
+			ifExists = true
+			i += 2
+		}
+		if i >= len(tokens) {
+			return nil, errors.New("неверный синтаксис DROP TABLE")
+		}
+		tableName := tokens[i]
+		cascade, err := parseDropBehavior(tokens, i+1)
+		if err != nil {
+			return nil, err
+		}
+		db.mu.RLock()
+		_, exists := db.Tables[strings.ToLower(tableName)]
+		db.mu.RUnlock()
+		if !exists && ifExists {
+			fmt.Printf("Таблица '%s' не существует, пропуск.\n", tableName)
+			return nil, nil
+		}
+		err = db.DropTable(tableName, cascade)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Таблица удалена успешно.")
+		return nil, nil
+	case "VIEW", "MATERIALIZED":
+		i := 2
+		materialized := strings.ToUpper(tokens[1]) == "MATERIALIZED"
+		if materialized {
+			if strings.ToUpper(tokens[2]) != "VIEW" {
+				return nil, errors.New("неверный синтаксис DROP MATERIALIZED VIEW")
+			}
+			i++
+		}
+		ifExists := false
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "EXISTS" {
+			ifExists = true
+			i += 2
+		}
+		if i+1 != len(tokens) {
+			return nil, errors.New("неверный синтаксис DROP VIEW")
+		}
+		db.mu.RLock()
+		view, exists := db.Views[strings.ToLower(tokens[i])]
+		db.mu.RUnlock()
+		if (!exists || view.Materialized != materialized) && ifExists {
+			fmt.Printf("Представление '%s' не существует, пропуск.\n", tokens[i])
+			return nil, nil
+		}
+		err := db.DropView(tokens[i], materialized)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("Представление удалено успешно.")
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("неизвестный объект DROP '%s'", tokens[1])
+	}
+}
+
+// parseDropBehavior разбирает необязательное CASCADE | RESTRICT в конце оператора.
+func parseDropBehavior(tokens []string, i int) (bool, error) {
+	if i == len(tokens) {
+		return false, nil
+	}
+	if i+1 == len(tokens) {
+		switch strings.ToUpper(tokens[i]) {
+		case "CASCADE":
+			return true, nil
+		case "RESTRICT":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("неожиданный токен '%s'", tokens[i])
+}
+
+func handleTruncate(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeTruncate(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Таблица очищена успешно (строк: %d).\n", result.RowsAffected)
+	return nil, nil
+}
+
+// executeTruncate выполняет TRUNCATE; RowsAffected — число удалённых строк.
+func executeTruncate(db *Database, tokens []string) (*ResultSet, error) {
+	i := 1
+	if i < len(tokens) && strings.ToUpper(tokens[i]) == "TABLE" {
+		i++
+	}
+	if i >= len(tokens) {
+		return nil, errors.New("неверный синтаксис TRUNCATE")
+	}
+	tableName := tokens[i]
+	restartIdentity := false
+	switch rest := strings.ToUpper(strings.Join(tokens[i+1:], " ")); rest {
+	case "":
+	case "RESTART IDENTITY":
+		restartIdentity = true
+	case "CONTINUE IDENTITY":
+	default:
+		return nil, fmt.Errorf("неверный синтаксис TRUNCATE: '%s'", strings.Join(tokens[i+1:], " "))
+	}
+	removed, err := db.truncateTable(tableName, restartIdentity)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultSet{RowsAffected: removed}, nil
+}
+
+// handleAlter разбирает ALTER TABLE name с одним действием:
+// ADD [COLUMN] определение, DROP [COLUMN] [IF EXISTS] name [CASCADE | RESTRICT],
+// RENAME [COLUMN] old TO new, RENAME TO new, ALTER [COLUMN] name [SET DATA] TYPE type.
+func handleAlter(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	if len(tokens) < 5 || strings.ToUpper(tokens[1]) != "TABLE" {
+		return nil, errors.New("неверный синтаксис ALTER TABLE")
+	}
+	tableName := tokens[2]
+	action := strings.ToUpper(tokens[3])
+	i := 4
+	if action != "RENAME" && i < len(tokens) && strings.ToUpper(tokens[i]) == "COLUMN" {
+		i++
+	}
+	var err error
+	switch action {
+	case "ADD":
+		if i == 4 && isTableConstraintStart(tokens[i]) {
+			return nil, errors.New("ALTER TABLE ADD поддерживает только добавление столбцов")
+		}
+		var column Column
+		var constraints []Constraint
+		column, constraints, err = parseColumnDefinition(db, tokens[i:])
+		if err != nil {
+			return nil, err
+		}
+		err = db.AddColumn(tableName, column, constraints)
+	case "DROP":
+		ifExists := false
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "IF" && strings.ToUpper(tokens[i+1]) == "EXISTS" {
+			ifExists = true
+			i += 2
+		}
+		if i >= len(tokens) {
+			return nil, errors.New("неверный синтаксис ALTER TABLE DROP COLUMN")
+		}
+		columnName := tokens[i]
+		var cascade bool
+		cascade, err = parseDropBehavior(tokens, i+1)
+		if err != nil {
+			return nil, err
+		}
+		db.mu.RLock()
+		table, exists := db.Tables[strings.ToLower(tableName)]
+		missing := exists && getColumnIndex(table, columnName) == -1
+		db.mu.RUnlock()
+		if missing && ifExists {
+			fmt.Printf("Столбец '%s' не существует, пропуск.\n", columnName)
+			return nil, nil
+		}
+		err = db.DropColumn(tableName, columnName, cascade)
+	case "RENAME":
+		switch {
+		case len(tokens) == 6 && strings.ToUpper(tokens[4]) == "TO":
+			err = db.RenameTable(tableName, tokens[5])
+		case len(tokens) == 8 && strings.ToUpper(tokens[4]) == "COLUMN" && strings.ToUpper(tokens[6]) == "TO":
+			err = db.RenameColumn(tableName, tokens[5], tokens[7])
+		case len(tokens) == 7 && strings.ToUpper(tokens[5]) == "TO":
+			err = db.RenameColumn(tableName, tokens[4], tokens[6])
+		default:
+			return nil, errors.New("неверный синтаксис ALTER TABLE RENAME")
+		}
+	case "ALTER":
+		if i >= len(tokens) {
+			return nil, errors.New("неверный синтаксис ALTER TABLE ALTER COLUMN")
+		}
+		columnName := tokens[i]
+		i++
+		if i+1 < len(tokens) && strings.ToUpper(tokens[i]) == "SET" && strings.ToUpper(tokens[i+1]) == "DATA" {
+			i += 2
+		}
+		if i+2 > len(tokens) || strings.ToUpper(tokens[i]) != "TYPE" {
+			return nil, errors.New("неверный синтаксис ALTER TABLE ALTER COLUMN: ожидается TYPE")
+		}
+		var columnType Column
+		var next int
+		columnType, next, err = parseColumnType(tokens, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if next != len(tokens) {
+			return nil, fmt.Errorf("неверный синтаксис ALTER TABLE ALTER COLUMN: неожиданный токен '%s'", tokens[next])
+		}
+		err = db.AlterColumnTypeWithPrecision(tableName, columnName, columnType.Type, columnType.Precision, columnType.Scale)
+	default:
+		return nil, fmt.Errorf("неизвестное действие ALTER TABLE '%s'", tokens[3])
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Таблица изменена успешно.")
+	return nil, nil
+}
+
+func handleSet(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	if len(tokens) < 4 || strings.ToUpper(tokens[1]) != "CONSTRAINTS" {
+		return nil, errors.New("неверный синтаксис SET: поддерживается только SET CONSTRAINTS")
+	}
+	var deferred bool
+	switch strings.ToUpper(tokens[len(tokens)-1]) {
+	case "DEFERRED":
+		deferred = true
+	case "IMMEDIATE":
+		deferred = false
+	default:
+		return nil, errors.New("неверный синтаксис SET CONSTRAINTS: ожидается DEFERRED или IMMEDIATE")
+	}
+	var names []string
+	if !(len(tokens) == 4 && strings.ToUpper(tokens[2]) == "ALL") {
+		for _, tok := range tokens[2 : len(tokens)-1] {
+			if tok != "," {
+				names = append(names, tok)
+			}
+		}
+	}
+	err := db.SetConstraintsMode(names, deferred)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Режим проверки ограничений установлен.")
 	return nil, nil
 }
 
 func handleInsert(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeInsert(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Данные вставлены успешно (строк: %d).\n", result.RowsAffected)
+	return result.Rows, nil
+}
+
+// executeInsert выполняет INSERT и возвращает строки RETURNING и число затронутых строк.
+func executeInsert(db *Database, tokens []string) (*ResultSet, error) {
 	if len(tokens) < 4 || strings.ToUpper(tokens[1]) != "INTO" {
 		return nil, errors.New("неверный синтаксис INSERT INTO")
 	}
 	tableName := tokens[2]
-	upperQuery := strings.ToUpper(query)
-	valuesIndex := strings.Index(upperQuery, "VALUES")
+
+	valuesIndex := -1
+	for i, tok := range tokens {
+		if strings.ToUpper(tok) == "VALUES" {
+			valuesIndex = i
+			break
+		}
+	}
 	if valuesIndex == -1 {
 		return nil, errors.New("не найдено ключевое слово VALUES")
 	}
-	valuesPart := query[valuesIndex+6:]
-	valuesPart = strings.TrimSpace(valuesPart)
-	if !strings.HasPrefix(valuesPart, "(") || !strings.HasSuffix(valuesPart, ")") {
+
+	var columns []string
+	if valuesIndex > 3 {
+		var next int
+		var err error
+		columns, next, err = parseIdentifierList(tokens, 3)
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис списка столбцов INSERT: %v", err)
+		}
+		if next != valuesIndex {
+			return nil, fmt.Errorf("неверный синтаксис INSERT: неожиданный токен '%s'", tokens[next])
+		}
+	}
+
+	if valuesIndex+1 >= len(tokens) || tokens[valuesIndex+1] != "(" {
 		return nil, errors.New("неверный синтаксис VALUES")
 	}
-	valuesPart = valuesPart[1 : len(valuesPart)-1]
-	values := splitCSV(valuesPart)
-	for i := range values {
-		values[i] = strings.TrimSpace(values[i])
-		values[i] = strings.Trim(values[i], "'")
+	valuesEnd := matchingParen(tokens, valuesIndex+1, len(tokens))
+	if valuesEnd == -1 {
+		return nil, errors.New("неверный синтаксис VALUES")
 	}
-	err := db.Insert(tableName, values)
+	values, err := parseValueList(db, tokens, valuesIndex+2, valuesEnd)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Данные вставлены успешно.")
-	return nil, nil
+	returningIndex := findClause(tokens, valuesEnd+1, "RETURNING")
+	returning, err := parseReturning(db, tokens, returningIndex)
+	if err != nil {
+		return nil, err
+	}
+	var conflict *OnConflict
+	if valuesEnd+1 < returningIndex {
+		conflict, err = parseOnConflict(db, tokens, valuesEnd+1, returningIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db.insertValues(tableName, columns, values, conflict, returning)
 }
 
-func handleSelect(db *Database, query string, tokens []string) ([][]interface{}, error) {
-	fromIndex := -1
-	for i, tok := range tokens {
-		if strings.ToUpper(tok) == "FROM" {
-			fromIndex = i
-			break
+// parseValueList разбирает список выражений VALUES и вычисляет их значения.
+func parseValueList(db *Database, tokens []string, start, end int) ([]interface{}, error) {
+	var values []interface{}
+	current := start
+	for current < end {
+		expr, next, err := parseExpression(db, tokens, current, end)
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис VALUES: %v", err)
+		}
+		if expr.Type == DefaultExpr {
+			values = append(values, defaultValue{})
+		} else {
+			value, err := evaluateExpression(nil, nil, expr)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		if next < end && tokens[next] != "," {
+			return nil, fmt.Errorf("неверный синтаксис VALUES: неожиданный токен '%s'", tokens[next])
 		}
+		current = next + 1
 	}
-	if fromIndex == -1 {
-		return nil, errors.New("неверный синтаксис SELECT: отсутствует FROM")
+	return values, nil
+}
+
+func handleSelect(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeSelect(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	return result.Rows, nil
+}
+
+// executeSelect выполняет SELECT и возвращает строки вместе с именами и типами столбцов.
+func executeSelect(db *Database, tokens []string) (*ResultSet, error) {
+	return executeQuery(db, tokens, nil)
+}
+
+// executeSimpleSelect выполняет SELECT без WITH; scope — CTE, видимые в запросе.
+func executeSimpleSelect(db *Database, tokens []string, scope relationScope) (*ResultSet, error) {
+	distinct, distinctOn, listStart, err := parseDistinct(db, tokens)
+	if err != nil {
+		return nil, err
 	}
-	if fromIndex < 2 {
+	fromIndex := findClause(tokens, listStart, "FROM")
+	if fromIndex <= listStart {
 		return nil, errors.New("неверный синтаксис SELECT: отсутствуют столбцы для выборки")
 	}
 
-	columnsList := strings.Join(tokens[1:fromIndex], " ")
+	columnsList := strings.Join(tokens[listStart:fromIndex], " ")
 	columnsList = strings.TrimSpace(columnsList)
 	columnsList = strings.Trim(columnsList, ",")
 	selectColumns := splitCSV(columnsList)
 	for i := range selectColumns {
 		selectColumns[i] = strings.TrimSpace(selectColumns[i])
-		selectColumns[i] = strings.Trim(selectColumns[i], "'")
+	}
+	items, err := parseSelectItems(db, selectColumns)
+	if err != nil {
+		return nil, err
 	}
 
+	// Без FROM список выборки вычисляется один раз: SELECT 2 * 3, upper('abc').
+	if fromIndex == len(tokens) {
+		return selectValues(items)
+	}
 	if fromIndex+1 >= len(tokens) {
 		return nil, errors.New("неверный синтаксис SELECT: отсутствует имя таблицы после FROM")
 	}
@@ -194,19 +1290,79 @@ func handleSelect(db *Database, query string, tokens []string) ([][]interface{},
 		}
 	}
 
+	tailIndex := findClause(tokens, fromIndex+1, "ORDER", "LIMIT", "OFFSET")
+	orderBy, limit, offset, err := parseSelectTail(db, tokens, tailIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Запрос с агрегатными функциями или GROUP BY возвращает по строке на группу.
+	aggregate := false
+	for _, item := range items {
+		if containsAggregate(item.expr) {
+			aggregate = true
+		}
+	}
+	groupIndex := findClause(tokens, fromIndex+1, "GROUP")
+	var groupBy []*Expr
+	if groupIndex < tailIndex {
+		if groupIndex+1 >= tailIndex || strings.ToUpper(tokens[groupIndex+1]) != "BY" {
+			return nil, errors.New("неверный синтаксис GROUP BY")
+		}
+		if selectColumns[0] == "*" {
+			return nil, errors.New("запрос с GROUP BY должен перечислять столбцы выборки")
+		}
+		groupBy, err = parseExpressionList(db, tokens, groupIndex+2, tailIndex, "GROUP BY")
+		if err != nil {
+			return nil, err
+		}
+		aggregate = true
+	}
+	whereEnd := min(groupIndex, tailIndex)
+	if err := checkDistinctOrder(distinct, distinctOn, orderBy, items, selectColumns[0] == "*"); err != nil {
+		return nil, err
+	}
+	if !aggregate {
+		orderBy = resolveAliases(orderBy, items)
+		for i, expr := range distinctOn {
+			distinctOn[i] = resolveAlias(expr, items)
+		}
+	} else if len(distinctOn) > 0 {
+		return nil, errors.New("DISTINCT ON в запросе с агрегатными функциями или GROUP BY не поддерживается")
+	}
+
+	// Оконные функции вычисляются по строкам после WHERE и JOIN, до ORDER BY и LIMIT.
+	var windows []*Expr
+	for _, item := range items {
+		windows = collectWindows(item.expr, windows)
+	}
+	for _, item := range orderBy {
+		windows = collectWindows(item.Expr, windows)
+	}
+	for _, expr := range distinctOn {
+		windows = collectWindows(expr, windows)
+	}
+	if len(windows) > 0 && aggregate {
+		return nil, errors.New("оконные функции в запросе с агрегатными функциями или GROUP BY не поддерживаются")
+	}
+
 	var condition *Condition
 	if whereIndex != -1 {
-		if whereIndex+1 >= len(tokens) {
+		if whereIndex+1 >= whereEnd {
 			return nil, errors.New("неверный синтаксис WHERE: отсутствует условие")
 		}
-		condition, err = parseWhere(tokens, whereIndex+1)
+		condition, err = parseWhereRange(db, tokens, whereIndex+1, whereEnd)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	var joinedData [][]interface{}
+	ordered := false
 	var columnNames []string
+	var columnTypes []DataType
+	// Определения столбцов источника: из них берутся точность и масштаб DECIMAL.
+	var sourceColumns []Column
 
 	if joinType != "" {
 		onParts := strings.Split(joinCondition, "=")
@@ -218,44 +1374,44 @@ func handleSelect(db *Database, query string, tokens []string) ([][]interface{},
 
 		if strings.Contains(joinColumn1, ".") {
 			parts := strings.Split(joinColumn1, ".")
-			if len(parts) != 2 {
+			if len(parts) > 3 {
 				return nil, errors.New("неверный синтаксис столбца для JOIN")
 			}
-			joinColumn1 = parts[1]
+			joinColumn1 = parts[len(parts)-1]
 		}
 		if strings.Contains(joinColumn2, ".") {
 			parts := strings.Split(joinColumn2, ".")
-			if len(parts) != 2 {
+			if len(parts) > 3 {
 				return nil, errors.New("неверный синтаксис столбца для JOIN")
 			}
-			joinColumn2 = parts[1]
+			joinColumn2 = parts[len(parts)-1]
 		}
 
+		table1, table2, err := db.resolveJoinTables(scope, tableName, joinTable)
+		if err != nil {
+			return nil, err
+		}
 		switch joinType {
 		case "LEFT":
-			joinedData, err = db.LeftJoin(tableName, joinTable, joinColumn1, joinColumn2)
+			joinedData, err = db.leftJoin(table1, table2, joinColumn1, joinColumn2)
 		case "RIGHT":
-			joinedData, err = db.RightJoin(tableName, joinTable, joinColumn1, joinColumn2)
-		case "INNER":
-			joinedData, err = db.Join(tableName, joinTable, joinColumn1, joinColumn2)
+			fmt.Printf("Выполнение RIGHT JOIN между '%s' и '%s' по столбцам '%s' и '%s'\n", table1.Name, table2.Name, joinColumn1, joinColumn2)
+			joinedData, err = db.leftJoin(table2, table1, joinColumn2, joinColumn1)
 		default:
-			joinedData, err = db.Join(tableName, joinTable, joinColumn1, joinColumn2)
+			joinedData, err = db.innerJoin(table1, table2, joinColumn1, joinColumn2)
 		}
 		if err != nil {
 			return nil, err
 		}
-
-		table1, exists1 := db.Tables[strings.ToLower(tableName)]
-		table2, exists2 := db.Tables[strings.ToLower(joinTable)]
-		if !exists1 || !exists2 {
-			return nil, errors.New("одна или обе таблицы не существуют")
-		}
 		for _, col := range table1.Columns {
 			columnNames = append(columnNames, fmt.Sprintf("%s.%s", table1.Name, col.Name))
+			columnTypes = append(columnTypes, col.Type)
 		}
 		for _, col := range table2.Columns {
 			columnNames = append(columnNames, fmt.Sprintf("%s.%s", table2.Name, col.Name))
+			columnTypes = append(columnTypes, col.Type)
 		}
+		sourceColumns = append(append(sourceColumns, table1.Columns...), table2.Columns...)
 
 		if condition != nil {
 			filteredData := [][]interface{}{}
@@ -272,72 +1428,194 @@ func handleSelect(db *Database, query string, tokens []string) ([][]interface{},
 		}
 
 	} else {
-		rows, err := db.Select(tableName, nil)
+		table, err := scope.resolve(db, tableName)
+		if err != nil {
+			return nil, err
+		}
+		scanOrder := orderBy
+		if aggregate || len(windows) > 0 {
+			scanOrder = nil
+		}
+		rows, sorted, err := db.selectOrdered(table, condition, scanOrder)
+		if err != nil {
+			return nil, err
+		}
+		joinedData = rows
+		ordered = sorted
+
+		for _, col := range table.Columns {
+			columnNames = append(columnNames, col.Name)
+			columnTypes = append(columnTypes, col.Type)
+		}
+		sourceColumns = table.Columns
+	}
+
+	if aggregate {
+		result, err := aggregateRows(joinedData, columnNames, columnTypes, items, groupBy)
+		if err != nil {
+			return nil, err
+		}
+		if len(orderBy) > 0 {
+			if err := sortRows(result.Rows, result.columnNames(), groupedOrderBy(orderBy, result)); err != nil {
+				return nil, err
+			}
+		}
+		if distinct {
+			result.Rows = distinctRows(result.Rows)
+		}
+		result.Rows = applyLimit(result.Rows, limit, offset)
+		return result, nil
+	}
+
+	visibleColumns := len(columnNames)
+	if len(windows) > 0 {
+		joinedData, columnNames, columnTypes, err = computeWindows(joinedData, columnNames, columnTypes, windows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(orderBy) > 0 && !ordered {
+		err = sortRows(joinedData, columnNames, orderBy)
 		if err != nil {
 			return nil, err
 		}
-		joinedData = rows
-
-		table, exists := db.Tables[strings.ToLower(tableName)]
-		if !exists {
-			return nil, fmt.Errorf("таблица '%s' не существует", tableName)
-		}
-		for _, col := range table.Columns {
-			columnNames = append(columnNames, col.Name)
-		}
-
-		if condition != nil {
-			filteredData := [][]interface{}{}
-			for _, row := range joinedData {
-				match, err := evaluateCondition(row, columnNames, condition)
-				if err != nil {
-					return nil, err
-				}
-				if match {
-					filteredData = append(filteredData, row)
-				}
-			}
-			joinedData = filteredData
+	}
+	// DISTINCT ON оставляет первую строку каждой группы после сортировки. Обычный
+	// DISTINCT сравнивает строки результата, поэтому LIMIT применяется после выборки.
+	if len(distinctOn) > 0 {
+		if joinedData, err = distinctOnRows(joinedData, columnNames, distinctOn); err != nil {
+			return nil, err
 		}
 	}
+	if !distinct || len(distinctOn) > 0 {
+		joinedData = applyLimit(joinedData, limit, offset)
+	}
 
 	if len(selectColumns) > 0 && selectColumns[0] != "*" {
+		// Элемент выборки — столбец результата или выражение над строкой (индекс -1).
 		var selectedIndexes []int
-		allColumns := columnNames
-
-		for _, col := range selectColumns {
+		for i, item := range items {
 			index := -1
-			for i, ac := range allColumns {
-				if strings.ToLower(ac) == strings.ToLower(col) || (strings.Contains(ac, ".") && strings.ToLower(ac[strings.LastIndex(ac, ".")+1:]) == strings.ToLower(col)) {
-					index = i
-					break
+			switch expr := item.expr; expr.Type {
+			case ColumnExpr:
+				index = findColumnIndex(columnNames, expr.Name)
+				if index == -1 {
+					return nil, fmt.Errorf("столбец '%s' не найден в результате", expr.Name)
+				}
+			case LiteralExpr:
+				// Имя столбца можно записать в кавычках: SELECT 'name' FROM users.
+				if name, ok := expr.Value.(string); ok && !item.aliased {
+					if index = findColumnIndex(columnNames, name); index != -1 {
+						items[i].name = name
+					}
 				}
-			}
-			if index == -1 {
-				return nil, fmt.Errorf("столбец '%s' не найден в результате", col)
 			}
 			selectedIndexes = append(selectedIndexes, index)
 		}
 
-		var finalResult [][]interface{}
+		result := &ResultSet{}
+		for i, idx := range selectedIndexes {
+			if idx == -1 {
+				result.Columns = append(result.Columns, ResultColumn{Name: items[i].name})
+				continue
+			}
+			result.Columns = append(result.Columns, sourceResultColumn(items[i].name, columnTypes[idx], sourceColumns, idx))
+		}
 		for _, row := range joinedData {
 			var newRow []interface{}
-			for _, idx := range selectedIndexes {
-				if idx < len(row) {
+			for i, idx := range selectedIndexes {
+				switch {
+				case idx == -1:
+					value, err := evaluateExpression(row, columnNames, items[i].expr)
+					if err != nil {
+						return nil, err
+					}
+					newRow = append(newRow, value)
+				case idx < len(row):
 					newRow = append(newRow, row[idx])
-				} else {
+				default:
 					newRow = append(newRow, nil)
 				}
 			}
-			finalResult = append(finalResult, newRow)
+			result.Rows = append(result.Rows, newRow)
+		}
+		for i, idx := range selectedIndexes {
+			if idx != -1 {
+				continue
+			}
+			dataType, ok := expressionType(items[i].expr, columnNames, columnTypes)
+			if !ok {
+				dataType = inferColumnType(result.Rows, i)
+			}
+			result.Columns[i].Type = dataType
+		}
+		if distinct && len(distinctOn) == 0 {
+			result.Rows = applyLimit(distinctRows(result.Rows), limit, offset)
 		}
-		return finalResult, nil
+		return result, nil
+	}
+
+	result := &ResultSet{Rows: joinedData}
+	if len(windows) > 0 {
+		// Служебные столбцы оконных функций из ORDER BY в результат не попадают.
+		for i, row := range joinedData {
+			joinedData[i] = row[:visibleColumns]
+		}
+	}
+	for i, name := range columnNames[:visibleColumns] {
+		result.Columns = append(result.Columns, sourceResultColumn(name, columnTypes[i], sourceColumns, i))
 	}
+	if distinct && len(distinctOn) == 0 {
+		result.Rows = applyLimit(distinctRows(result.Rows), limit, offset)
+	}
+	return result, nil
+}
+
+// sourceResultColumn описывает столбец результата, взятый из i-го столбца источника.
+func sourceResultColumn(name string, dataType DataType, source []Column, i int) ResultColumn {
+	column := ResultColumn{Name: name, Type: dataType}
+	if i < len(source) && source[i].Type == dataType {
+		column.Precision, column.Scale = source[i].Precision, source[i].Scale
+	}
+	return column
+}
 
-	return joinedData, nil
+// selectValues вычисляет список выборки без FROM и возвращает одну строку.
+func selectValues(items []selectItem) (*ResultSet, error) {
+	result := &ResultSet{Rows: [][]interface{}{nil}}
+	for _, item := range items {
+		value, err := evaluateExpression(nil, nil, item.expr)
+		if err != nil {
+			return nil, err
+		}
+		result.Rows[0] = append(result.Rows[0], value)
+		dataType, ok := valueType(value)
+		if !ok {
+			dataType = STRING
+		}
+		result.Columns = append(result.Columns, ResultColumn{Name: item.name, Type: dataType})
+	}
+	return result, nil
 }
 
 func handleUpdate(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeUpdate(db, tokens)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Данные обновлены успешно (строк: %d).\n", result.RowsAffected)
+	return result.Rows, nil
+}
+
+// executeUpdate выполняет UPDATE и возвращает строки RETURNING и число изменённых строк.
+func executeUpdate(db *Database, tokens []string) (*ResultSet, error) {
+	returningIndex := findClause(tokens, 0, "RETURNING")
+	returning, err := parseReturning(db, tokens, returningIndex)
+	if err != nil {
+		return nil, err
+	}
+	tokens = tokens[:returningIndex]
 	if len(tokens) < 4 || strings.ToUpper(tokens[2]) != "SET" {
 		return nil, errors.New("неверный синтаксис UPDATE")
 	}
@@ -363,28 +1641,49 @@ func handleUpdate(db *Database, query string, tokens []string) ([][]interface{},
 		return nil, errors.New("поддерживается только одно обновление столбца за раз")
 	}
 
-	setTokens := strings.SplitN(setParts[0], "=", 2)
-	if len(setTokens) != 2 {
+	setTokens := tokenize(setParts[0])
+	if len(setTokens) < 3 || setTokens[1] != "=" {
 		return nil, errors.New("неверный синтаксис SET")
 	}
-	columnName := strings.TrimSpace(setTokens[0])
-	newValue := strings.TrimSpace(setTokens[1])
-	newValue = strings.Trim(newValue, "'")
+	columnName := setTokens[0]
+	value, next, err := parseValueExpression(db, setTokens, 2, len(setTokens))
+	if err != nil {
+		return nil, fmt.Errorf("неверный синтаксис SET: %v", err)
+	}
+	if next != len(setTokens) {
+		return nil, fmt.Errorf("неверный синтаксис SET: неожиданный токен '%s'", setTokens[next])
+	}
 
 	var condition *Condition
 	if whereIndex != -1 {
-		condition, _ = parseWhere(tokens, whereIndex+1)
+		if whereIndex+1 >= len(tokens) {
+			return nil, errors.New("неверный синтаксис WHERE: отсутствует условие")
+		}
+		if condition, err = parseWhere(db, tokens, whereIndex+1); err != nil {
+			return nil, err
+		}
 	}
 
-	err := db.Update(tableName, columnName, newValue, condition)
+	return db.updateValues(tableName, []Assignment{{Column: columnName, Value: value}}, condition, returning)
+}
+
+func handleDelete(db *Database, query string, tokens []string) ([][]interface{}, error) {
+	result, err := executeDelete(db, tokens)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Данные обновлены успешно.")
-	return nil, nil
+	fmt.Printf("Данные удалены успешно (строк: %d).\n", result.RowsAffected)
+	return result.Rows, nil
 }
 
-func handleDelete(db *Database, query string, tokens []string) ([][]interface{}, error) {
+// executeDelete выполняет DELETE и возвращает строки RETURNING и число удалённых строк.
+func executeDelete(db *Database, tokens []string) (*ResultSet, error) {
+	returningIndex := findClause(tokens, 0, "RETURNING")
+	returning, err := parseReturning(db, tokens, returningIndex)
+	if err != nil {
+		return nil, err
+	}
+	tokens = tokens[:returningIndex]
 	if len(tokens) < 3 || strings.ToUpper(tokens[1]) != "FROM" {
 		return nil, errors.New("неверный синтаксис DELETE")
 	}
@@ -400,15 +1699,15 @@ func handleDelete(db *Database, query string, tokens []string) ([][]interface{},
 
 	var condition *Condition
 	if whereIndex != -1 {
-		condition, _ = parseWhere(tokens, whereIndex+1)
+		if whereIndex+1 >= len(tokens) {
+			return nil, errors.New("неверный синтаксис WHERE: отсутствует условие")
+		}
+		if condition, err = parseWhere(db, tokens, whereIndex+1); err != nil {
+			return nil, err
+		}
 	}
 
-	err := db.Delete(tableName, condition)
-	if err != nil {
-		return nil, err
-	}
-	fmt.Println("Данные удалены успешно.")
-	return nil, nil
+	return db.deleteValues(tableName, condition, returning)
 }
 
 func parseJoin(tokens []string) (joinType string, joinTable string, joinCondition string, err error) {
@@ -431,13 +1730,7 @@ func parseJoin(tokens []string) (joinType string, joinTable string, joinConditio
 				if onIndex == -1 {
 					return "", "", "", errors.New("не найдено условие ON для JOIN")
 				}
-				endIndex := len(tokens)
-				for j := onIndex + 1; j < len(tokens); j++ {
-					if strings.ToUpper(tokens[j]) == "WHERE" {
-						endIndex = j
-						break
-					}
-				}
+				endIndex := findClause(tokens, onIndex+1, "WHERE", "GROUP", "ORDER", "LIMIT", "OFFSET")
 				joinCondition = strings.Join(tokens[onIndex+1:endIndex], " ")
 				return joinType, joinTable, joinCondition, nil
 			}
@@ -457,13 +1750,7 @@ func parseJoin(tokens []string) (joinType string, joinTable string, joinConditio
 			if onIndex == -1 {
 				return "", "", "", errors.New("не найдено условие ON для JOIN")
 			}
-			endIndex := len(tokens)
-			for j := onIndex + 1; j < len(tokens); j++ {
-				if strings.ToUpper(tokens[j]) == "WHERE" {
-					endIndex = j
-					break
-				}
-			}
+			endIndex := findClause(tokens, onIndex+1, "WHERE", "GROUP", "ORDER", "LIMIT", "OFFSET")
 			joinCondition = strings.Join(tokens[onIndex+1:endIndex], " ")
 			return joinType, joinTable, joinCondition, nil
 		}
@@ -471,26 +1758,214 @@ func parseJoin(tokens []string) (joinType string, joinTable string, joinConditio
 	return "", "", "", nil
 }
 
-func parseWhere(tokens []string, startIndex int) (*Condition, error) {
-	cond, nextIndex, err := parseCondition(tokens, startIndex, len(tokens))
+func parseWhere(db *Database, tokens []string, startIndex int) (*Condition, error) {
+	return parseWhereRange(db, tokens, startIndex, len(tokens))
+}
+
+func parseWhereRange(db *Database, tokens []string, startIndex, endIndex int) (*Condition, error) {
+	cond, nextIndex, err := parseCondition(db, tokens, startIndex, endIndex)
 	if err != nil {
 		return nil, err
 	}
-	if nextIndex != len(tokens) && tokens[nextIndex] != ";" {
+	if nextIndex != endIndex && tokens[nextIndex] != ";" {
 		return nil, fmt.Errorf("неверный синтаксис WHERE: неожиданный токен '%s'", tokens[nextIndex])
 	}
 	return cond, nil
 }
 
-func parseCondition(tokens []string, start, end int) (*Condition, int, error) {
+// findClause возвращает индекс первого ключевого слова из keywords вне скобок,
+// начиная с start, или len(tokens), если ни одно не найдено.
+func findClause(tokens []string, start int, keywords ...string) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+			continue
+		case ")":
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		upperToken := strings.ToUpper(tokens[i])
+		for _, keyword := range keywords {
+			if upperToken == keyword {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+type OrderBy struct {
+	Expr *Expr
+	Desc bool
+}
+
+// parseSelectTail разбирает завершающие предложения SELECT: ORDER BY, LIMIT и OFFSET.
+// limit == -1 означает отсутствие ограничения.
+func parseSelectTail(db *Database, tokens []string, start int) ([]OrderBy, int, int, error) {
+	var orderBy []OrderBy
+	limit, offset := -1, 0
+	i := start
+	for i < len(tokens) {
+		switch strings.ToUpper(tokens[i]) {
+		case "ORDER":
+			if i+1 >= len(tokens) || strings.ToUpper(tokens[i+1]) != "BY" {
+				return nil, 0, 0, errors.New("неверный синтаксис ORDER BY")
+			}
+			end := findClause(tokens, i+2, "LIMIT", "OFFSET")
+			var err error
+			orderBy, err = parseOrderBy(db, tokens, i+2, end)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			i = end
+		case "LIMIT", "OFFSET":
+			if i+1 >= len(tokens) {
+				return nil, 0, 0, fmt.Errorf("неверный синтаксис %s: отсутствует значение", strings.ToUpper(tokens[i]))
+			}
+			n, ok := parseLiteralToken(tokens[i+1])
+			count, isInt := n.(int)
+			if !ok || !isInt || count < 0 {
+				return nil, 0, 0, fmt.Errorf("неверное значение %s '%s'", strings.ToUpper(tokens[i]), tokens[i+1])
+			}
+			if strings.ToUpper(tokens[i]) == "LIMIT" {
+				limit = count
+			} else {
+				offset = count
+			}
+			i += 2
+		default:
+			return nil, 0, 0, fmt.Errorf("неверный синтаксис SELECT: неожиданный токен '%s'", tokens[i])
+		}
+	}
+	return orderBy, limit, offset, nil
+}
+
+// parseExpressionList разбирает список выражений через запятую, например GROUP BY.
+func parseExpressionList(db *Database, tokens []string, start, end int, clause string) ([]*Expr, error) {
+	var exprs []*Expr
+	current := start
+	for current < end {
+		expr, next, err := parseExpression(db, tokens, current, end)
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис %s: %v", clause, err)
+		}
+		if next < end && tokens[next] != "," {
+			return nil, fmt.Errorf("неверный синтаксис %s: неожиданный токен '%s'", clause, tokens[next])
+		}
+		exprs = append(exprs, expr)
+		current = next + 1
+	}
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("неверный синтаксис %s: отсутствуют выражения", clause)
+	}
+	return exprs, nil
+}
+
+func parseOrderBy(db *Database, tokens []string, start, end int) ([]OrderBy, error) {
+	var orderBy []OrderBy
+	current := start
+	for current < end {
+		expr, next, err := parseExpression(db, tokens, current, end)
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис ORDER BY: %v", err)
+		}
+		item := OrderBy{Expr: expr}
+		if next < end {
+			switch strings.ToUpper(tokens[next]) {
+			case "ASC":
+				next++
+			case "DESC":
+				item.Desc = true
+				next++
+			}
+		}
+		orderBy = append(orderBy, item)
+		if next < end && tokens[next] != "," {
+			return nil, fmt.Errorf("неверный синтаксис ORDER BY: неожиданный токен '%s'", tokens[next])
+		}
+		current = next + 1
+	}
+	if len(orderBy) == 0 {
+		return nil, errors.New("неверный синтаксис ORDER BY: отсутствуют выражения")
+	}
+	return orderBy, nil
+}
+
+// sortRows сортирует строки по ORDER BY; NULL идут последними при ASC и первыми при DESC.
+func sortRows(rows [][]interface{}, columnNames []string, orderBy []OrderBy) error {
+	keys := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		keys[i] = make([]interface{}, len(orderBy))
+		for j, item := range orderBy {
+			value, err := evaluateExpression(row, columnNames, item.Expr)
+			if err != nil {
+				return err
+			}
+			keys[i][j] = value
+		}
+	}
+	indexes := make([]int, len(rows))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return compareOrderKeys(keys[indexes[a]], keys[indexes[b]], orderBy) < 0
+	})
+	sorted := make([][]interface{}, len(rows))
+	for i, idx := range indexes {
+		sorted[i] = rows[idx]
+	}
+	copy(rows, sorted)
+	return nil
+}
+
+// compareOrderKeys сравнивает значения ключей ORDER BY двух строк с учётом направления.
+func compareOrderKeys(a, b []interface{}, orderBy []OrderBy) int {
+	for j, item := range orderBy {
+		cmp := compareValues(a[j], b[j])
+		if cmp == 0 {
+			continue
+		}
+		if item.Desc {
+			return -cmp
+		}
+		return cmp
+	}
+	return 0
+}
+
+func applyLimit(rows [][]interface{}, limit, offset int) [][]interface{} {
+	if offset >= len(rows) {
+		return nil
+	}
+	rows = rows[offset:]
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+func parseCondition(db *Database, tokens []string, start, end int) (*Condition, int, error) {
 	var left *Condition
 	current := start
 
 	for current < end {
 		token := tokens[current]
 
-		if token == "(" {
-			subCond, nextIndex, err := parseCondition(tokens, current+1, end)
+		if token == "(" && left == nil && startsPredicate(db, tokens, current, end) {
+			cond, nextIndex, err := parsePredicate(db, tokens, current, end)
+			if err != nil {
+				return nil, current, err
+			}
+			left = cond
+			current = nextIndex
+		} else if token == "(" {
+			subCond, nextIndex, err := parseCondition(db, tokens, current+1, end)
 			if err != nil {
 				return nil, current, err
 			}
@@ -505,7 +1980,7 @@ func parseCondition(tokens []string, start, end int) (*Condition, int, error) {
 			}
 			logicalOp := strings.ToUpper(token)
 			current++
-			rightCond, nextIndex, err := parseCondition(tokens, current, end)
+			rightCond, nextIndex, err := parseCondition(db, tokens, current, end)
 			if err != nil {
 				return nil, current, err
 			}
@@ -517,33 +1992,11 @@ func parseCondition(tokens []string, start, end int) (*Condition, int, error) {
 			}
 			current = nextIndex
 		} else {
-			if current+2 >= end {
-				return nil, current, errors.New("неверный синтаксис WHERE: недостаточно токенов для условия")
-			}
-			column := token
-			operator := tokens[current+1]
-			valueToken := tokens[current+2]
-			current += 3
-
-			var value interface{}
-			if strings.HasPrefix(valueToken, "'") && strings.HasSuffix(valueToken, "'") {
-				value = strings.Trim(valueToken, "'")
-			} else {
-				if intVal, err := strconv.Atoi(valueToken); err == nil {
-					value = intVal
-				} else if floatVal, err := strconv.ParseFloat(valueToken, 64); err == nil {
-					value = floatVal
-				} else {
-					return nil, current, fmt.Errorf("неизвестный тип значения '%s' в WHERE", valueToken)
-				}
-			}
-
-			cond := &Condition{
-				Type:     Simple,
-				Column:   column,
-				Operator: operator,
-				Value:    value,
+			cond, nextIndex, err := parsePredicate(db, tokens, current, end)
+			if err != nil {
+				return nil, current, err
 			}
+			current = nextIndex
 
 			if left == nil {
 				left = cond
@@ -571,6 +2024,91 @@ func parseCondition(tokens []string, start, end int) (*Condition, int, error) {
 	return left, current, nil
 }
 
+// startsPredicate проверяет, что скобка в начале условия открывает выражение,
+// а не группу условий: (price * 2) > 10, (doc ->> 'a')::int = 1.
+func startsPredicate(db *Database, tokens []string, start, end int) bool {
+	_, _, err := parseExpression(db, tokens, start, end)
+	return err == nil
+}
+
+// parsePredicate разбирает одиночное условие: сравнение столбца или выражения с константой,
+// проверку IS [NOT] TRUE|FALSE|NULL, логический столбец или NOT <условие>.
+func parsePredicate(db *Database, tokens []string, start, end int) (*Condition, int, error) {
+	if strings.ToUpper(tokens[start]) == "NOT" {
+		if start+1 >= end {
+			return nil, start, errors.New("неверный синтаксис WHERE: отсутствует условие после NOT")
+		}
+		var operand *Condition
+		var next int
+		var err error
+		if tokens[start+1] == "(" {
+			operand, next, err = parseCondition(db, tokens, start+2, end)
+		} else {
+			operand, next, err = parsePredicate(db, tokens, start+1, end)
+		}
+		if err != nil {
+			return nil, start, err
+		}
+		return &Condition{Type: Compound, LogicalOp: "NOT", Left: operand}, next, nil
+	}
+
+	left, current, err := parseExpression(db, tokens, start, end)
+	if err != nil {
+		return nil, current, err
+	}
+	cond := &Condition{Type: Simple, Column: left.Name}
+	if left.Type != ColumnExpr {
+		cond.Column, cond.Expr = exprString(left), left
+	}
+	// Логическое выражение без оператора означает выражение = TRUE.
+	if current >= end || (!isComparisonOperator(tokens[current]) && strings.ToUpper(tokens[current]) != "IS") {
+		cond.Operator, cond.Value = "=", true
+		return cond, current, nil
+	}
+
+	if strings.ToUpper(tokens[current]) == "IS" {
+		current++
+		operator := "IS "
+		if current < end && strings.ToUpper(tokens[current]) == "NOT" {
+			operator += "NOT "
+			current++
+		}
+		if current >= end {
+			return nil, current, errors.New("неверный синтаксис WHERE: ожидалось TRUE, FALSE или NULL после IS")
+		}
+		switch test := strings.ToUpper(tokens[current]); test {
+		case "TRUE", "FALSE", "NULL":
+			operator += test
+		case "UNKNOWN":
+			operator += "NULL"
+		default:
+			return nil, current, errors.New("неверный синтаксис WHERE: ожидалось TRUE, FALSE или NULL после IS")
+		}
+		cond.Operator = operator
+		return cond, current + 1, nil
+	}
+
+	if current+1 >= end {
+		return nil, start, errors.New("неверный синтаксис WHERE: недостаточно токенов для условия")
+	}
+	cond.Operator = tokens[current]
+	// Константная правая часть (литерал, DATE '...', now() - INTERVAL '1 day')
+	// вычисляется сразу и может использоваться индексом; остальные — для каждой строки.
+	expr, next, err := parseExpression(db, tokens, current+1, end)
+	if err != nil {
+		return nil, next, err
+	}
+	if !isConstantExpression(expr) {
+		cond.ValueExpr = expr
+		return cond, next, nil
+	}
+	cond.Value, err = evaluateExpression(nil, nil, expr)
+	if err != nil {
+		return nil, next, err
+	}
+	return cond, next, nil
+}
+
 func tokenize(query string) []string {
 	var tokens []string
 	var current strings.Builder
@@ -578,19 +2116,29 @@ func tokenize(query string) []string {
 	var quoteChar rune
 
 	operatorChars := "=!<>"
+	arithmeticChars := "+-*/%"
 
 	for i := 0; i < len(query); i++ {
 		r := rune(query[i])
+		if op := symbolOperatorAt(query[i:]); op != "" && !inQuotes {
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			tokens = append(tokens, op)
+			i += len(op) - 1
+			continue
+		}
 		switch {
 		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
 			if inQuotes {
-				current.WriteRune(r)
+				current.WriteByte(query[i])
 			} else if current.Len() > 0 {
 				tokens = append(tokens, current.String())
 				current.Reset()
 			}
 		case r == '\'' || r == '"':
-			current.WriteRune(r)
+			current.WriteByte(query[i])
 			if inQuotes && r == quoteChar {
 				inQuotes = false
 			} else if !inQuotes {
@@ -599,7 +2147,7 @@ func tokenize(query string) []string {
 			}
 		case strings.ContainsRune(operatorChars, r):
 			if inQuotes {
-				current.WriteRune(r)
+				current.WriteByte(query[i])
 			} else {
 				if current.Len() > 0 {
 					tokens = append(tokens, current.String())
@@ -616,9 +2164,20 @@ func tokenize(query string) []string {
 				}
 				tokens = append(tokens, string(r))
 			}
+		case strings.ContainsRune(arithmeticChars, r):
+			// Знак порядка в записи числа 1e-5 остаётся частью числа.
+			if inQuotes || isExponentSign(current.String(), r) {
+				current.WriteByte(query[i])
+			} else {
+				if current.Len() > 0 {
+					tokens = append(tokens, current.String())
+					current.Reset()
+				}
+				tokens = append(tokens, string(r))
+			}
 		case r == '(', r == ')', r == ',', r == ';':
 			if inQuotes {
-				current.WriteRune(r)
+				current.WriteByte(query[i])
 			} else {
 				if current.Len() > 0 {
 					tokens = append(tokens, current.String())
@@ -627,7 +2186,7 @@ func tokenize(query string) []string {
 				tokens = append(tokens, string(r))
 			}
 		default:
-			current.WriteRune(r)
+			current.WriteByte(query[i])
 		}
 	}
 	if current.Len() > 0 {
@@ -636,19 +2195,55 @@ func tokenize(query string) []string {
 	return tokens
 }
 
+// symbolOperatorAt возвращает оператор приведения ::, конкатенацию || или оператор JSON,
+// с которого начинается строка.
+func symbolOperatorAt(s string) string {
+	for _, op := range []string{"::", "||"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	for _, op := range jsonOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isExponentSign(number string, r rune) bool {
+	if r != '+' && r != '-' || len(number) < 2 || !strings.HasSuffix(strings.ToLower(number), "e") {
+		return false
+	}
+	if number[0] != '.' && (number[0] < '0' || number[0] > '9') {
+		return false
+	}
+	_, err := strconv.ParseFloat(number[:len(number)-1], 64)
+	return err == nil
+}
+
 func splitCSV(input string) []string {
 	var result []string
 	var current strings.Builder
 	inQuotes := false
+	depth := 0
 
 	for _, r := range input {
 		switch r {
 		case ',':
-			if !inQuotes {
+			if !inQuotes && depth == 0 {
 				result = append(result, current.String())
 				current.Reset()
 				continue
 			}
+		case '(':
+			if !inQuotes {
+				depth++
+			}
+		case ')':
+			if !inQuotes && depth > 0 {
+				depth--
+			}
 		case '\'':
 			inQuotes = !inQuotes
 		}
@@ -663,109 +2258,110 @@ func evaluateJoinedCondition(row []interface{}, columnNames []string, condition
 }
 
 func evaluateCondition(row []interface{}, columnNames []string, condition *Condition) (bool, error) {
+	result, unknown, err := evaluateConditionNull(row, columnNames, condition)
+	if err != nil {
+		return false, err
+	}
+	return result && !unknown, nil
+}
+
+// evaluateConditionNull вычисляет условие по трёхзначной логике SQL:
+// unknown == true означает, что результат не определён из-за NULL.
+func evaluateConditionNull(row []interface{}, columnNames []string, condition *Condition) (result bool, unknown bool, err error) {
 	if condition.Type == Simple {
-		var colIndex int = -1
-		for i, col := range columnNames {
-			if strings.ToLower(col) == strings.ToLower(condition.Column) || (strings.Contains(col, ".") && strings.ToLower(col[strings.LastIndex(col, ".")+1:]) == strings.ToLower(condition.Column)) {
-				colIndex = i
-				break
+		var value interface{}
+		if condition.Expr != nil {
+			value, err = evaluateExpression(row, columnNames, condition.Expr)
+			if err != nil {
+				return false, false, err
+			}
+		} else {
+			colIndex := findColumnIndex(columnNames, condition.Column)
+			if colIndex == -1 {
+				return false, false, fmt.Errorf("столбец '%s' не найден в результате", condition.Column)
 			}
+			value = row[colIndex]
 		}
-
-		if colIndex == -1 {
-			return false, fmt.Errorf("столбец '%s' не найден в результате", condition.Column)
+		if strings.HasPrefix(condition.Operator, "IS ") {
+			result, err := evaluateIsPredicate(value, condition.Operator)
+			return result, false, err
+		}
+		operand := condition.Value
+		if condition.ValueExpr != nil {
+			operand, err = evaluateExpression(row, columnNames, condition.ValueExpr)
+			if err != nil {
+				return false, false, err
+			}
+		}
+		if value == nil || operand == nil {
+			return false, true, nil
 		}
 
-		value := row[colIndex]
-
-		return evaluateSimpleCondition(value, condition.Operator, condition.Value)
+		result, err := evaluateSimpleCondition(value, condition.Operator, operand)
+		return result, false, err
 	} else if condition.Type == Compound {
-		leftResult, err := evaluateCondition(row, columnNames, condition.Left)
+		leftResult, leftUnknown, err := evaluateConditionNull(row, columnNames, condition.Left)
 		if err != nil {
-			return false, err
+			return false, false, err
+		}
+		if condition.LogicalOp == "NOT" {
+			return !leftResult && !leftUnknown, leftUnknown, nil
 		}
 
-		rightResult, err := evaluateCondition(row, columnNames, condition.Right)
+		rightResult, rightUnknown, err := evaluateConditionNull(row, columnNames, condition.Right)
 		if err != nil {
-			return false, err
+			return false, false, err
 		}
 
 		switch condition.LogicalOp {
 		case "AND":
-			return leftResult && rightResult, nil
+			if (!leftResult && !leftUnknown) || (!rightResult && !rightUnknown) {
+				return false, false, nil
+			}
+			return !leftUnknown && !rightUnknown, leftUnknown || rightUnknown, nil
 		case "OR":
-			return leftResult || rightResult, nil
+			if (leftResult && !leftUnknown) || (rightResult && !rightUnknown) {
+				return true, false, nil
+			}
+			return false, leftUnknown || rightUnknown, nil
 		default:
-			return false, fmt.Errorf("неизвестный логический оператор '%s'", condition.LogicalOp)
+			return false, false, fmt.Errorf("неизвестный логический оператор '%s'", condition.LogicalOp)
 		}
 	}
 
-	return false, errors.New("неизвестный тип условия")
+	return false, false, errors.New("неизвестный тип условия")
 }
 
+// evaluateSimpleCondition сравнивает значение с константой условия; строковая
+// константа приводится к типу значения.
 func evaluateSimpleCondition(value interface{}, operator string, target interface{}) (bool, error) {
-	switch v := value.(type) {
-	case int:
-		targetVal, ok := target.(int)
-		if !ok {
-			return false, fmt.Errorf("несоответствие типов: сравнение INTEGER с %T", target)
-		}
-		switch operator {
-		case "=":
-			return v == targetVal, nil
-		case "!=":
-			return v != targetVal, nil
-		case "<":
-			return v < targetVal, nil
-		case ">":
-			return v > targetVal, nil
-		case "<=":
-			return v <= targetVal, nil
-		case ">=":
-			return v >= targetVal, nil
-		default:
-			return false, fmt.Errorf("неподдерживаемый оператор '%s'", operator)
-		}
-	case float64:
-		var targetVal float64
-		switch tv := target.(type) {
-		case float64:
-			targetVal = tv
-		case int:
-			targetVal = float64(tv)
-		default:
-			return false, fmt.Errorf("несоответствие типов: сравнение FLOAT с %T", target)
-		}
-		switch operator {
-		case "=":
-			return v == targetVal, nil
-		case "!=":
-			return v != targetVal, nil
-		case "<":
-			return v < targetVal, nil
-		case ">":
-			return v > targetVal, nil
-		case "<=":
-			return v <= targetVal, nil
-		case ">=":
-			return v >= targetVal, nil
-		default:
-			return false, fmt.Errorf("неподдерживаемый оператор '%s'", operator)
-		}
-	case string:
-		targetVal, ok := target.(string)
-		if !ok {
-			return false, fmt.Errorf("несоответствие типов: сравнение STRING с %T", target)
-		}
-		switch operator {
-		case "=":
-			return v == targetVal, nil
-		case "!=":
-			return v != targetVal, nil
-		default:
-			return false, fmt.Errorf("неподдерживаемый оператор '%s' для строк", operator)
+	target, err := coerceLiteral(value, target)
+	if err != nil {
+		return false, err
+	}
+	cmp, err := compareTyped(value, target)
+	if err != nil {
+		return false, err
+	}
+	return applyComparison(operator, cmp)
+}
+
+// evaluateIsPredicate проверяет IS [NOT] TRUE|FALSE|NULL. В отличие от сравнений
+// результат никогда не бывает неизвестным.
+func evaluateIsPredicate(value interface{}, operator string) (bool, error) {
+	negated := strings.HasPrefix(operator, "IS NOT ")
+	var result bool
+	switch test := operator[strings.LastIndex(operator, " ")+1:]; test {
+	case "NULL":
+		result = value == nil
+	case "TRUE", "FALSE":
+		if value != nil {
+			b, ok := value.(bool)
+			if !ok {
+				return false, fmt.Errorf("оператор IS %s применим только к BOOLEAN, получено %T", test, value)
+			}
+			result = b == (test == "TRUE")
 		}
-	default:
-		return false, fmt.Errorf("неподдерживаемый тип данных '%T' в WHERE", value)
 	}
+	return result != negated, nil
 }
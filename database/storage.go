@@ -25,12 +25,59 @@ func (db *Database) saveTableToDisk(tableName string) error {
 	return nil
 }
 
+func (db *Database) saveViewToDisk(viewName string) error {
+	view, exists := db.Views[viewName]
+	if !exists {
+		return fmt.Errorf("представление '%s' не существует", viewName)
+	}
+	data, err := json.MarshalIndent(view, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга представления '%s': %v", viewName, err)
+	}
+	err = os.WriteFile(fmt.Sprintf("%s.view", viewName), data, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка записи представления '%s' на диск: %v", viewName, err)
+	}
+	return nil
+}
+
+func (db *Database) removeViewFromDisk(viewName string) error {
+	err := os.Remove(fmt.Sprintf("%s.view", viewName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления файла представления '%s': %v", viewName, err)
+	}
+	return nil
+}
+
+func (db *Database) removeTableFromDisk(tableName string) error {
+	err := os.Remove(fmt.Sprintf("%s.json", tableName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка удаления файла таблицы '%s': %v", tableName, err)
+	}
+	return nil
+}
+
 func (db *Database) LoadFromDisk() error {
 	files, err := os.ReadDir(".")
 	if err != nil {
 		return fmt.Errorf("ошибка чтения директории: %v", err)
 	}
+	db.loading = true
+	defer func() { db.loading = false }()
 	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".view") {
+			data, err := os.ReadFile(file.Name())
+			if err != nil {
+				return fmt.Errorf("ошибка чтения файла '%s': %v", file.Name(), err)
+			}
+			var view View
+			err = json.Unmarshal(data, &view)
+			if err != nil {
+				return fmt.Errorf("ошибка маршалинга файла '%s': %v", file.Name(), err)
+			}
+			db.Views[strings.ToLower(view.Name)] = &view
+			continue
+		}
 		if strings.HasSuffix(file.Name(), ".json") {
 			data, err := os.ReadFile(file.Name())
 			if err != nil {
@@ -53,6 +100,25 @@ func (db *Database) LoadFromDisk() error {
 				}
 			}
 
+			table.autoIncrementID = make(map[string]int)
+			for j, col := range table.Columns {
+				if !col.AutoIncrement {
+					continue
+				}
+				maxID := 0
+				for _, row := range table.Rows {
+					if id, ok := row[j].(int); ok && id > maxID {
+						maxID = id
+					}
+				}
+				table.autoIncrementID[col.Name] = maxID
+			}
+
+			err = table.rebuildIndexes(db)
+			if err != nil {
+				return fmt.Errorf("ошибка построения индексов таблицы '%s': %v", table.Name, err)
+			}
+
 			db.Tables[strings.ToLower(table.Name)] = &table
 		}
 	}
@@ -60,6 +126,9 @@ func (db *Database) LoadFromDisk() error {
 }
 
 func correctType(value interface{}, dataType DataType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
 	switch dataType {
 	case INTEGER:
 		switch v := value.(type) {
@@ -67,6 +136,16 @@ func correctType(value interface{}, dataType DataType) (interface{}, error) {
 			return int(v), nil
 		case int:
 			return v, nil
+		case Decimal:
+			if n, ok := v.toInt(); ok {
+				return n, nil
+			}
+			return nil, fmt.Errorf("значение %v вне диапазона INTEGER", v)
+		case bool:
+			if v {
+				return 1, nil
+			}
+			return 0, nil
 		case string:
 			intVal, err := strconv.Atoi(v)
 			if err != nil {
@@ -82,6 +161,13 @@ func correctType(value interface{}, dataType DataType) (interface{}, error) {
 			return v, nil
 		case int:
 			return float64(v), nil
+		case Decimal:
+			return v.float64(), nil
+		case bool:
+			if v {
+				return 1.0, nil
+			}
+			return 0.0, nil
 		case string:
 			floatVal, err := strconv.ParseFloat(v, 64)
 			if err != nil {
@@ -91,6 +177,55 @@ func correctType(value interface{}, dataType DataType) (interface{}, error) {
 		default:
 			return nil, fmt.Errorf("неподдерживаемый тип '%T' для FLOAT", v)
 		}
+	case BOOLEAN:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int:
+			return v != 0, nil
+		case string:
+			return parseBool(v)
+		default:
+			return nil, fmt.Errorf("неподдерживаемый тип '%T' для BOOLEAN", v)
+		}
+	case DATE, TIME, TIMESTAMP, TIMESTAMPTZ, INTERVAL:
+		return convertTemporal(value, dataType)
+	case DECIMAL:
+		if s, ok := value.(string); ok {
+			return parseValue(s, DECIMAL)
+		}
+		if d, ok := toDecimal(value); ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("неподдерживаемый тип '%s' для DECIMAL", typeNameOf(value))
+	case JSON:
+		switch v := value.(type) {
+		case JSONValue:
+			return v, nil
+		case string:
+			return parseJSON(v)
+		default:
+			return nil, fmt.Errorf("неподдерживаемый тип '%s' для JSON", typeNameOf(value))
+		}
+	case BYTEA:
+		// В файле таблицы байты записаны в base64.
+		switch v := value.(type) {
+		case Bytes:
+			return v, nil
+		case string:
+			return decodeBase64(v)
+		default:
+			return nil, fmt.Errorf("неподдерживаемый тип '%s' для BYTEA", typeNameOf(value))
+		}
+	case UUID:
+		switch v := value.(type) {
+		case UUIDValue:
+			return v, nil
+		case string:
+			return loadUUID(v)
+		default:
+			return nil, fmt.Errorf("неподдерживаемый тип '%s' для UUID", typeNameOf(value))
+		}
 	case STRING:
 		return fmt.Sprintf("%v", value), nil
 	default:
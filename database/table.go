@@ -10,3 +10,35 @@ func getColumnIndex(table *Table, columnName string) int {
 	}
 	return -1
 }
+
+func (table *Table) columnNames() []string {
+	names := make([]string, len(table.Columns))
+	for i, col := range table.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// snapshot копирует определение и список строк таблицы. Сами строки общие:
+// изменения данных всегда заменяют строку целиком, а не правят её на месте.
+func (table *Table) snapshot() *Table {
+	copied := &Table{
+		Name:            table.Name,
+		Columns:         append([]Column(nil), table.Columns...),
+		Rows:            append([][]interface{}{}, table.Rows...),
+		Constraints:     append([]Constraint(nil), table.Constraints...),
+		autoIncrementID: make(map[string]int),
+	}
+	for _, idx := range table.Indexes {
+		copied.Indexes = append(copied.Indexes, &Index{
+			Name:    idx.Name,
+			Columns: append([]string(nil), idx.Columns...),
+			Unique:  idx.Unique,
+			Method:  idx.Method,
+		})
+	}
+	for name, id := range table.autoIncrementID {
+		copied.autoIncrementID[name] = id
+	}
+	return copied
+}
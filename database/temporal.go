@@ -0,0 +1,680 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Значения временных типов. DATE и TIMESTAMP хранят «настенное» время в UTC,
+// TIMESTAMPTZ — момент времени, который выводится в локальном часовом поясе,
+// TIME — смещение от полуночи. Точность, как в PostgreSQL, — микросекунды.
+type Date struct{ t time.Time }
+
+type TimeOfDay struct{ d time.Duration }
+
+type Timestamp struct{ t time.Time }
+
+type TimestampTZ struct{ t time.Time }
+
+// Interval хранит месяцы, дни и время раздельно: длина месяца и дня
+// определяется только при прибавлении интервала к дате.
+type Interval struct {
+	months   int
+	days     int
+	duration time.Duration
+}
+
+const (
+	dateLayout        = "2006-01-02"
+	timeLayout        = "15:04:05.999999"
+	timestampLayout   = "2006-01-02 15:04:05.999999"
+	timestampTZLayout = "2006-01-02 15:04:05.999999-07:00"
+	day               = 24 * time.Hour
+)
+
+func (v Date) String() string        { return v.t.Format(dateLayout) }
+func (v TimeOfDay) String() string   { return time.Time{}.Add(v.d).Format(timeLayout) }
+func (v Timestamp) String() string   { return v.t.Format(timestampLayout) }
+func (v TimestampTZ) String() string { return v.t.Local().Format(timestampTZLayout) }
+
+func (v Interval) String() string {
+	var parts []string
+	if years := v.months / 12; years != 0 {
+		parts = append(parts, formatIntervalUnit(years, "year"))
+	}
+	if months := v.months % 12; months != 0 {
+		parts = append(parts, formatIntervalUnit(months, "mon"))
+	}
+	if v.days != 0 {
+		parts = append(parts, formatIntervalUnit(v.days, "day"))
+	}
+	if v.duration != 0 || len(parts) == 0 {
+		d, sign := v.duration, ""
+		if d < 0 {
+			d, sign = -d, "-"
+		}
+		clock := fmt.Sprintf("%02d:%02d:%02d", d/time.Hour, d/time.Minute%60, d/time.Second%60)
+		if frac := d % time.Second; frac != 0 {
+			clock += strings.TrimRight(fmt.Sprintf(".%06d", frac/time.Microsecond), "0")
+		}
+		parts = append(parts, sign+clock)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatIntervalUnit(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return strconv.Itoa(n) + " " + unit + "s"
+}
+
+// Значения сохраняются на диск в текстовом виде и читаются обратно через parseValue.
+func (v Date) MarshalText() ([]byte, error)        { return []byte(v.String()), nil }
+func (v TimeOfDay) MarshalText() ([]byte, error)   { return []byte(v.String()), nil }
+func (v Timestamp) MarshalText() ([]byte, error)   { return []byte(v.String()), nil }
+func (v TimestampTZ) MarshalText() ([]byte, error) { return []byte(v.String()), nil }
+func (v Interval) MarshalText() ([]byte, error)    { return []byte(v.String()), nil }
+
+func isTemporalType(dataType DataType) bool {
+	switch dataType {
+	case DATE, TIME, TIMESTAMP, TIMESTAMPTZ, INTERVAL:
+		return true
+	}
+	return false
+}
+
+// temporalType возвращает тип данных временного значения.
+func temporalType(value interface{}) (DataType, bool) {
+	switch value.(type) {
+	case Date:
+		return DATE, true
+	case TimeOfDay:
+		return TIME, true
+	case Timestamp:
+		return TIMESTAMP, true
+	case TimestampTZ:
+		return TIMESTAMPTZ, true
+	case Interval:
+		return INTERVAL, true
+	}
+	return 0, false
+}
+
+var dateTimeLayouts = func() []string {
+	var layouts []string
+	for _, clock := range []string{"15:04:05", "15:04"} {
+		for _, zone := range []string{"Z07:00", "Z0700", "Z07", ""} {
+			layouts = append(layouts, "2006-01-02 "+clock+zone)
+		}
+	}
+	return append(layouts, "2006-01-02")
+}()
+
+// parseDateTime разбирает дату и время в формате ISO 8601. Если часовой пояс
+// не указан, время считается заданным в loc.
+func parseDateTime(value string, loc *time.Location) (time.Time, bool) {
+	s := strings.TrimSpace(value)
+	if len(s) > 10 && (s[10] == 'T' || s[10] == 't') {
+		s = s[:10] + " " + s[11:]
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.Round(time.Microsecond), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseTemporal(value string, dataType DataType) (interface{}, error) {
+	switch dataType {
+	case DATE:
+		if t, ok := parseDateTime(value, time.UTC); ok {
+			return dateOf(t), nil
+		}
+	case TIME:
+		if d, ok := parseTimeOfDay(value); ok {
+			return TimeOfDay{d}, nil
+		}
+	case TIMESTAMP:
+		// Как и в PostgreSQL, часовой пояс во входной строке TIMESTAMP игнорируется.
+		if t, ok := parseDateTime(value, time.UTC); ok {
+			return Timestamp{wallClock(t)}, nil
+		}
+	case TIMESTAMPTZ:
+		if t, ok := parseDateTime(value, time.Local); ok {
+			return TimestampTZ{t}, nil
+		}
+	case INTERVAL:
+		if iv, ok := parseInterval(value); ok {
+			return iv, nil
+		}
+	}
+	return nil, fmt.Errorf("не удалось преобразовать '%s' в %s", value, dataType)
+}
+
+func parseTimeOfDay(value string) (time.Duration, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return clockOf(t), true
+		}
+	}
+	return 0, false
+}
+
+// parseInterval понимает запись PostgreSQL ('1 year 2 mons 3 days 04:05:06',
+// '90 minutes ago') и ISO 8601 ('P1Y2M3DT4H5M6S').
+func parseInterval(value string) (Interval, bool) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	if strings.HasPrefix(s, "p") {
+		return parseISOInterval(s[1:])
+	}
+	fields := strings.Fields(s)
+	ago := len(fields) > 0 && fields[len(fields)-1] == "ago"
+	if ago {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) == 0 {
+		return Interval{}, false
+	}
+	var iv Interval
+	for i := 0; i < len(fields); i++ {
+		if strings.Contains(fields[i], ":") {
+			d, ok := parseClock(fields[i])
+			if !ok {
+				return Interval{}, false
+			}
+			iv.duration += d
+			continue
+		}
+		n, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil || i+1 >= len(fields) || !iv.addUnit(n, fields[i+1]) {
+			return Interval{}, false
+		}
+		i++
+	}
+	if ago {
+		iv = iv.negate()
+	}
+	return iv, true
+}
+
+func parseISOInterval(s string) (Interval, bool) {
+	var iv Interval
+	inTime := false
+	number := ""
+	for _, r := range s {
+		switch {
+		case r == 't':
+			inTime = true
+		case r == '-' || r == '.' || (r >= '0' && r <= '9'):
+			number += string(r)
+		default:
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return Interval{}, false
+			}
+			units := map[rune]string{'y': "year", 'm': "month", 'w': "week", 'd': "day"}
+			if inTime {
+				units = map[rune]string{'h': "hour", 'm': "minute", 's': "second"}
+			}
+			if unit, ok := units[r]; !ok || !iv.addUnit(n, unit) {
+				return Interval{}, false
+			}
+			number = ""
+		}
+	}
+	return iv, number == ""
+}
+
+// parseClock разбирает время вида [-]hh:mm[:ss[.ffffff]].
+func parseClock(s string) (time.Duration, bool) {
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	var seconds float64
+	if len(parts) == 3 {
+		var err error
+		if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return 0, false
+		}
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + fractionOf(seconds, time.Second)
+	return sign * d, true
+}
+
+func (iv *Interval) addUnit(n float64, unit string) bool {
+	switch unit {
+	case "ms":
+		unit = "millisecond"
+	case "us":
+		unit = "microsecond"
+	case "s":
+		unit = "second"
+	}
+	switch strings.TrimSuffix(unit, "s") {
+	case "year", "y", "yr":
+		iv.months += int(math.Round(n * 12))
+	case "month", "mon":
+		whole := math.Trunc(n)
+		iv.months += int(whole)
+		iv.days += int(math.Round((n - whole) * 30))
+	case "week", "w":
+		iv.addDays(n * 7)
+	case "day", "d":
+		iv.addDays(n)
+	case "hour", "h", "hr":
+		iv.duration += fractionOf(n, time.Hour)
+	case "minute", "min", "m":
+		iv.duration += fractionOf(n, time.Minute)
+	case "second", "sec":
+		iv.duration += fractionOf(n, time.Second)
+	case "millisecond":
+		iv.duration += fractionOf(n, time.Millisecond)
+	case "microsecond":
+		iv.duration += fractionOf(n, time.Microsecond)
+	default:
+		return false
+	}
+	return true
+}
+
+func (iv *Interval) addDays(n float64) {
+	whole := math.Trunc(n)
+	iv.days += int(whole)
+	iv.duration += fractionOf(n-whole, day)
+}
+
+// fractionOf переводит дробное количество единиц в длительность с точностью до микросекунд.
+func fractionOf(n float64, unit time.Duration) time.Duration {
+	return time.Duration(math.Round(n*float64(unit)/float64(time.Microsecond))) * time.Microsecond
+}
+
+func (iv Interval) negate() Interval {
+	return Interval{months: -iv.months, days: -iv.days, duration: -iv.duration}
+}
+
+// approximate приводит интервал к длительности для сравнения: месяц считается
+// равным 30 дням, день — 24 часам.
+func (iv Interval) approximate() time.Duration {
+	return time.Duration(iv.months)*30*day + time.Duration(iv.days)*day + iv.duration
+}
+
+func dateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{time.Date(y, m, d, 0, 0, 0, 0, time.UTC)}
+}
+
+func clockOf(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()).Round(time.Microsecond)
+}
+
+// wallClock переносит показания часов t в UTC, отбрасывая часовой пояс.
+func wallClock(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// inLocal трактует «настенное» время как локальное.
+func inLocal(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+// addMonths прибавляет месяцы, ограничивая день последним днём месяца:
+// 31 января + 1 месяц = 28 (29) февраля.
+func addMonths(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+func addInterval(t time.Time, iv Interval) time.Time {
+	return addMonths(t, iv.months).AddDate(0, 0, iv.days).Add(iv.duration)
+}
+
+// commonTimestamp приводит пару значений DATE/TIMESTAMP/TIMESTAMPTZ к общему типу:
+// если одно из них TIMESTAMPTZ, оба переводятся в моменты времени, иначе —
+// в «настенное» время. tz сообщает, что результат — моменты времени.
+func commonTimestamp(a, b interface{}) (ta, tb time.Time, tz, ok bool) {
+	wall := func(v interface{}) (time.Time, bool, bool) {
+		switch v := v.(type) {
+		case Date:
+			return v.t, false, true
+		case Timestamp:
+			return v.t, false, true
+		case TimestampTZ:
+			return v.t, true, true
+		}
+		return time.Time{}, false, false
+	}
+	ta, aTZ, okA := wall(a)
+	tb, bTZ, okB := wall(b)
+	if !okA || !okB {
+		return ta, tb, false, false
+	}
+	if aTZ != bTZ {
+		if !aTZ {
+			ta = inLocal(ta)
+		}
+		if !bTZ {
+			tb = inLocal(tb)
+		}
+	}
+	return ta, tb, aTZ || bTZ, true
+}
+
+// compareTemporal сравнивает временные значения; DATE, TIMESTAMP и TIMESTAMPTZ
+// сравнимы между собой.
+func compareTemporal(a, b interface{}) (int, error) {
+	switch av := a.(type) {
+	case TimeOfDay:
+		if bv, ok := b.(TimeOfDay); ok {
+			return compareInts(int(av.d), int(bv.d)), nil
+		}
+	case Interval:
+		if bv, ok := b.(Interval); ok {
+			return compareInts(int(av.approximate()), int(bv.approximate())), nil
+		}
+	default:
+		if ta, tb, _, ok := commonTimestamp(a, b); ok {
+			return ta.Compare(tb), nil
+		}
+	}
+	return 0, fmt.Errorf("несоответствие типов: сравнение %s с %s", typeNameOf(a), typeNameOf(b))
+}
+
+// temporalArithmetic вычисляет a op b, если хотя бы один операнд временной.
+// handled = false означает, что операнды не временные.
+func temporalArithmetic(op string, a, b interface{}) (result interface{}, handled bool, err error) {
+	_, aTemporal := temporalType(a)
+	_, bTemporal := temporalType(b)
+	if !aTemporal && !bTemporal {
+		return nil, false, nil
+	}
+	if op != "+" && op != "-" {
+		return nil, true, fmt.Errorf("оператор '%s' не применим к типам %s и %s", op, typeNameOf(a), typeNameOf(b))
+	}
+	if op == "+" {
+		// Сложение коммутативно: интервал или число переносим вправо.
+		switch a.(type) {
+		case Interval, int:
+			if _, isInterval := b.(Interval); !isInterval {
+				a, b = b, a
+			}
+		}
+	}
+	iv, bIsInterval := b.(Interval)
+	if op == "-" && bIsInterval {
+		iv = iv.negate()
+	}
+	switch av := a.(type) {
+	case Date:
+		switch bv := b.(type) {
+		case int:
+			if op == "-" {
+				bv = -bv
+			}
+			return Date{av.t.AddDate(0, 0, bv)}, true, nil
+		case Interval:
+			return Timestamp{addInterval(av.t, iv)}, true, nil
+		case TimeOfDay:
+			if op == "+" {
+				return Timestamp{av.t.Add(bv.d)}, true, nil
+			}
+		case Date:
+			if op == "-" {
+				return int(av.t.Sub(bv.t) / day), true, nil
+			}
+		}
+	case Timestamp:
+		if bIsInterval {
+			return Timestamp{addInterval(av.t, iv)}, true, nil
+		}
+	case TimestampTZ:
+		if bIsInterval {
+			return TimestampTZ{addInterval(av.t.Local(), iv)}, true, nil
+		}
+	case TimeOfDay:
+		switch bv := b.(type) {
+		case Interval:
+			d := (av.d + iv.duration) % day
+			if d < 0 {
+				d += day
+			}
+			return TimeOfDay{d}, true, nil
+		case TimeOfDay:
+			if op == "-" {
+				return Interval{duration: av.d - bv.d}, true, nil
+			}
+		}
+	case Interval:
+		if bIsInterval {
+			return Interval{months: av.months + iv.months, days: av.days + iv.days, duration: av.duration + iv.duration}, true, nil
+		}
+	}
+	if op == "-" {
+		if ta, tb, _, ok := commonTimestamp(a, b); ok {
+			d := ta.Sub(tb)
+			days := d / day
+			return Interval{days: int(days), duration: d - days*day}, true, nil
+		}
+	}
+	return nil, true, fmt.Errorf("оператор '%s' не применим к типам %s и %s", op, typeNameOf(a), typeNameOf(b))
+}
+
+// convertTemporal приводит значение к временному типу dataType.
+func convertTemporal(value interface{}, dataType DataType) (interface{}, error) {
+	if s, ok := value.(string); ok {
+		return parseTemporal(s, dataType)
+	}
+	switch dataType {
+	case DATE:
+		switch v := value.(type) {
+		case Date:
+			return v, nil
+		case Timestamp:
+			return dateOf(v.t), nil
+		case TimestampTZ:
+			return dateOf(v.t.Local()), nil
+		}
+	case TIME:
+		switch v := value.(type) {
+		case TimeOfDay:
+			return v, nil
+		case Timestamp:
+			return TimeOfDay{clockOf(v.t)}, nil
+		case TimestampTZ:
+			return TimeOfDay{clockOf(v.t.Local())}, nil
+		}
+	case TIMESTAMP:
+		switch v := value.(type) {
+		case Date:
+			return Timestamp{v.t}, nil
+		case Timestamp:
+			return v, nil
+		case TimestampTZ:
+			return Timestamp{wallClock(v.t.Local())}, nil
+		}
+	case TIMESTAMPTZ:
+		switch v := value.(type) {
+		case Date:
+			return TimestampTZ{inLocal(v.t)}, nil
+		case Timestamp:
+			return TimestampTZ{inLocal(v.t)}, nil
+		case TimestampTZ:
+			return v, nil
+		}
+	case INTERVAL:
+		switch v := value.(type) {
+		case Interval:
+			return v, nil
+		case TimeOfDay:
+			return Interval{duration: v.d}, nil
+		}
+	}
+	return nil, fmt.Errorf("невозможно преобразовать %s в %s", typeNameOf(value), dataType)
+}
+
+// truncateTime отбрасывает у t все поля младше field (для date_trunc).
+func truncateTime(t time.Time, field string) (time.Time, error) {
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch strings.ToLower(field) {
+	case "microseconds":
+		return t, nil
+	case "milliseconds":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/1e6*1e6, loc), nil
+	case "second":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+	case "minute":
+		return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc), nil
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, loc), nil
+	case "week":
+		// Неделя начинается с понедельника (ISO 8601).
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc), nil
+	case "quarter":
+		return time.Date(y, (m-1)/3*3+1, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, loc), nil
+	case "decade":
+		return time.Date(y-y%10, 1, 1, 0, 0, 0, 0, loc), nil
+	case "century":
+		return time.Date((y-1)/100*100+1, 1, 1, 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, fmt.Errorf("неизвестное поле '%s' для date_trunc", field)
+}
+
+// extractField возвращает поле field значения для EXTRACT и date_part.
+// Дробные поля (second, milliseconds, epoch) возвращаются как FLOAT.
+func extractField(field string, value interface{}) (interface{}, error) {
+	field = strings.ToLower(field)
+	switch v := value.(type) {
+	case Interval:
+		switch field {
+		case "year":
+			return v.months / 12, nil
+		case "month":
+			return v.months % 12, nil
+		case "day":
+			return v.days, nil
+		case "epoch":
+			years := float64(v.months/12) * 365.25 * float64(day)
+			months := float64(v.months%12) * 30 * float64(day)
+			return (years + months + float64(time.Duration(v.days)*day+v.duration)) / float64(time.Second), nil
+		}
+		return extractClock(field, v.duration)
+	case TimeOfDay:
+		if field == "epoch" {
+			return v.d.Seconds(), nil
+		}
+		return extractClock(field, v.d)
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case Date:
+		t = v.t
+	case Timestamp:
+		t = v.t
+	case TimestampTZ:
+		t = v.t.Local()
+		if field == "epoch" {
+			return float64(v.t.UnixMicro()) / 1e6, nil
+		}
+		if field == "timezone" {
+			_, offset := t.Zone()
+			return offset, nil
+		}
+	default:
+		return nil, fmt.Errorf("EXTRACT не применим к типу %s", typeNameOf(value))
+	}
+	switch field {
+	case "century":
+		return (t.Year()-1)/100 + 1, nil
+	case "decade":
+		return t.Year() / 10, nil
+	case "year":
+		return t.Year(), nil
+	case "isoyear":
+		year, _ := t.ISOWeek()
+		return year, nil
+	case "quarter":
+		return (int(t.Month())-1)/3 + 1, nil
+	case "month":
+		return int(t.Month()), nil
+	case "week":
+		_, week := t.ISOWeek()
+		return week, nil
+	case "day":
+		return t.Day(), nil
+	case "dow":
+		return int(t.Weekday()), nil
+	case "isodow":
+		return (int(t.Weekday())+6)%7 + 1, nil
+	case "doy":
+		return t.YearDay(), nil
+	case "epoch":
+		return float64(t.UnixMicro()) / 1e6, nil
+	}
+	return extractClock(field, clockOf(t))
+}
+
+func extractClock(field string, d time.Duration) (interface{}, error) {
+	switch field {
+	case "hour":
+		return int(d / time.Hour), nil
+	case "minute":
+		return int(d / time.Minute % 60), nil
+	case "second":
+		return float64(d%time.Minute) / float64(time.Second), nil
+	case "milliseconds":
+		return float64(d%time.Minute) / float64(time.Millisecond), nil
+	case "microseconds":
+		return int(d % time.Minute / time.Microsecond), nil
+	}
+	return nil, fmt.Errorf("неизвестное поле '%s' для EXTRACT", field)
+}
+
+// age вычисляет разность a - b в годах, месяцах и днях, как функция age() в PostgreSQL.
+func age(a, b time.Time) Interval {
+	negative := a.Before(b)
+	if negative {
+		a, b = b, a
+	}
+	months := (a.Year()-b.Year())*12 + int(a.Month()-b.Month())
+	anchor := addMonths(b, months)
+	if anchor.After(a) {
+		months--
+		anchor = addMonths(b, months)
+	}
+	d := a.Sub(anchor)
+	days := d / day
+	iv := Interval{months: months, days: int(days), duration: d - days*day}
+	if negative {
+		return iv.negate()
+	}
+	return iv
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTemporalArithmetic(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db, "CREATE TABLE one (id INTEGER)", "INSERT INTO one VALUES (1)")
+	cases := map[string]string{
+		"SELECT DATE '2023-01-31' + 1 FROM one":                                                     "[[2023-02-01]]",
+		"SELECT DATE '2023-03-01' - DATE '2023-02-01' FROM one":                                     "[[28]]",
+		"SELECT DATE '2023-01-31' + INTERVAL '1 month' FROM one":                                    "[[2023-02-28 00:00:00]]",
+		"SELECT TIMESTAMP '2023-01-15 10:30:00' - INTERVAL '1 day 02:00:00' FROM one":               "[[2023-01-14 08:30:00]]",
+		"SELECT TIME '23:30:00' + INTERVAL '90 minutes' FROM one":                                   "[[01:00:00]]",
+		"SELECT TIMESTAMP '2023-01-15 10:30:00' - TIMESTAMP '2023-01-14 09:00:00' FROM one":         "[[1 day 01:30:00]]",
+		"SELECT INTERVAL '1 year 2 mons' + INTERVAL '3 days' FROM one":                              "[[1 year 2 mons 3 days]]",
+		"SELECT DATE '2023-01-15' + TIME '10:00:00' FROM one":                                       "[[2023-01-15 10:00:00]]",
+		"SELECT date_trunc('month', TIMESTAMP '2023-05-17 12:00:00') FROM one":                      "[[2023-05-01 00:00:00]]",
+		"SELECT EXTRACT(year FROM DATE '2023-01-15'), date_part('dow', DATE '2023-01-15') FROM one": "[[2023 0]]",
+		"SELECT age(TIMESTAMP '2024-03-20 00:00:00', TIMESTAMP '2023-01-15 00:00:00') FROM one":     "[[1 year 2 mons 5 days]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT DATE '2023-02-30' FROM one")
+	mustFail(t, db, "SELECT DATE '2023-01-15' + DATE '2023-01-16' FROM one")
+}
+
+func TestTemporalColumnsCompareAndPersist(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, order_date DATE, at TIME, created TIMESTAMP, stamped TIMESTAMPTZ, wait INTERVAL)",
+		"INSERT INTO orders VALUES (1, '2023-01-15', '10:30:00.5', '2023-01-15 10:30:00', '2023-01-15T10:30:00+03:00', '1 day 02:00:00')",
+		"INSERT INTO orders VALUES (2, DATE '2023-03-10', '08:00:00', '2023-03-10T08:00:00.123456', '2023-03-10T08:00:00Z', 'P1DT2H')",
+		"INSERT INTO orders VALUES (3, '2022-12-31', NULL, NULL, NULL, '90 minutes ago')",
+	)
+	mustFail(t, db, "INSERT INTO orders (id, order_date) VALUES (4, 'yesterday')")
+
+	cases := map[string]string{
+		"SELECT id FROM orders WHERE order_date >= '2023-02-01'":                  "[[2]]",
+		"SELECT id FROM orders ORDER BY order_date":                               "[[3] [1] [2]]",
+		"SELECT id FROM orders WHERE stamped = '2023-01-15 07:30:00Z'":            "[[1]]",
+		"SELECT id FROM orders WHERE wait = INTERVAL '26 hours' ORDER BY id":      "[[1] [2]]",
+		"SELECT id FROM orders WHERE created < TIMESTAMP '2023-03-10 08:00:00.2'": "[[1] [2]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	before := fmt.Sprint(mustQuery(t, db, "SELECT * FROM orders ORDER BY id"))
+	reopened := NewDatabase()
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT * FROM orders ORDER BY id")); got != before {
+		t.Fatalf("после загрузки: %s, ожидалось %s", got, before)
+	}
+	rows := mustQuery(t, reopened, "SELECT order_date, at, created, stamped, wait FROM orders WHERE id = 2")
+	for i, want := range []interface{}{Date{}, TimeOfDay{}, Timestamp{}, TimestampTZ{}, Interval{}} {
+		if fmt.Sprintf("%T", rows[0][i]) != fmt.Sprintf("%T", want) {
+			t.Errorf("столбец %d после загрузки: %T", i, rows[0][i])
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package database
 
+import "strings"
+
 type Transaction struct {
 	operations []Operation
+	// implicit — служебная транзакция одного оператора, а не BEGIN пользователя
+	implicit        bool
+	constraintModes map[string]bool
+	deferredChecks  map[string]bool
 }
 
 type Operation struct {
@@ -12,3 +18,93 @@ type Operation struct {
 	ColumnName string
 	NewValue   interface{}
 }
+
+// atomic выполняет fn как единый оператор: при ошибке все изменения,
+// сделанные внутри fn, отменяются, даже если явная транзакция не начата.
+func (db *Database) atomic(fn func() error) error {
+	implicit := db.transaction == nil
+	if implicit {
+		db.transaction = &Transaction{implicit: true}
+	}
+	savepoint := len(db.transaction.operations)
+	err := fn()
+	if err != nil {
+		db.undoOperations(savepoint)
+	}
+	if implicit {
+		db.transaction = nil
+	}
+	return err
+}
+
+func (db *Database) undoOperations(savepoint int) {
+	touched := make(map[string]bool)
+	for i := len(db.transaction.operations) - 1; i >= savepoint; i-- {
+		op := db.transaction.operations[i]
+		if op.Type == "SCHEMA" {
+			if op.Data == nil {
+				delete(db.Tables, op.TableName)
+				delete(touched, op.TableName)
+				db.removeTableFromDisk(op.TableName)
+			} else {
+				db.Tables[op.TableName] = op.Data.(*Table)
+				touched[op.TableName] = true
+			}
+			continue
+		}
+		if op.Type == "VIEW" {
+			if op.Data == nil {
+				delete(db.Views, op.TableName)
+				db.removeViewFromDisk(op.TableName)
+			} else {
+				db.Views[op.TableName] = op.Data.(*View)
+				db.saveViewToDisk(op.TableName)
+			}
+			continue
+		}
+		table, exists := db.Tables[op.TableName]
+		if !exists {
+			continue
+		}
+		switch op.Type {
+		case "INSERT":
+			if op.RowIndex < len(table.Rows) {
+				table.Rows = append(table.Rows[:op.RowIndex], table.Rows[op.RowIndex+1:]...)
+			}
+		case "DELETE":
+			if op.RowIndex > len(table.Rows) {
+				op.RowIndex = len(table.Rows)
+			}
+			table.Rows = append(table.Rows, nil)
+			copy(table.Rows[op.RowIndex+1:], table.Rows[op.RowIndex:])
+			table.Rows[op.RowIndex] = op.Data.([]interface{})
+		case "UPDATE":
+			if op.RowIndex < len(table.Rows) {
+				table.Rows[op.RowIndex] = op.Data.([]interface{})
+			}
+		}
+		touched[op.TableName] = true
+	}
+	db.transaction.operations = db.transaction.operations[:savepoint]
+	for tableName := range touched {
+		db.Tables[tableName].rebuildIndexes(db)
+		db.saveTableToDisk(tableName)
+	}
+}
+
+// logSchemaChange запоминает состояние таблицы перед изменением схемы, чтобы откат
+// мог его восстановить. Data == nil означает, что таблицы ещё не было.
+func (db *Database) logSchemaChange(tableName string) {
+	if db.transaction == nil {
+		return
+	}
+	op := Operation{Type: "SCHEMA", TableName: tableName}
+	if table, exists := db.Tables[tableName]; exists {
+		op.Data = table.snapshot()
+	}
+	db.transaction.operations = append(db.transaction.operations, op)
+}
+
+func constraintKey(tableName, constraintName string) string {
+	return strings.ToLower(tableName) + "." + strings.ToLower(constraintName)
+}
@@ -0,0 +1,345 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Правила приведения типов, общие для WHERE, JOIN, индексов, ORDER BY и выражений:
+//
+//   - числа приводятся к общему типу по цепочке INTEGER → FLOAT → DECIMAL, поэтому
+//     1 = 1.0, а 0.1 в столбце DECIMAL равно литералу 0.1;
+//   - строковая константа в условии читается как значение типа другого операнда:
+//     order_date >= '2023-01-01', id = 'a0eebc99-...', amount < '10.5';
+//   - остальные типы сравниваются только с собой (DATE, TIMESTAMP и TIMESTAMPTZ
+//     считаются одним семейством), иначе — ошибка «несоответствие типов»;
+//   - при записи в столбец и в CAST значение приводится функцией coerceValue;
+//     ошибка приведения — CoercionError с исходным значением и целевым типом.
+
+// CoercionError — ошибка приведения значения к типу.
+type CoercionError struct {
+	Value  interface{}
+	Target string
+	Reason string
+}
+
+func (e *CoercionError) Error() string {
+	message := fmt.Sprintf("невозможно привести значение %s типа %s к типу %s", formatValue(e.Value), typeNameOf(e.Value), e.Target)
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	return message
+}
+
+// formatValue записывает значение для сообщений: строки — в кавычках.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return "'" + v + "'"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// coerceValue приводит значение к типу dataType. Строка разбирается как запись
+// значения этого типа, дробное число не приводится к INTEGER неявно, а логические
+// значения и числа, как и при сравнении, не приводятся друг к другу.
+func coerceValue(value interface{}, dataType DataType) (interface{}, error) {
+	var converted interface{}
+	var err error
+	if _, isBool := value.(bool); (isBool && isNumericType(dataType)) || (dataType == BOOLEAN && isNumericValue(value)) {
+		return nil, &CoercionError{Value: value, Target: dataType.String(), Reason: "требуется явное приведение CAST"}
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		converted, err = parseValue(v, dataType)
+	case float64:
+		if dataType == INTEGER && v != math.Trunc(v) {
+			return nil, &CoercionError{Value: value, Target: dataType.String(), Reason: "число не целое"}
+		}
+		converted, err = correctType(value, dataType)
+	case Decimal:
+		if dataType == INTEGER && !v.isInteger() {
+			return nil, &CoercionError{Value: value, Target: dataType.String(), Reason: "число не целое"}
+		}
+		converted, err = correctType(value, dataType)
+	default:
+		converted, err = correctType(value, dataType)
+	}
+	if err != nil {
+		var coercionErr *CoercionError
+		if errors.As(err, &coercionErr) {
+			return nil, err
+		}
+		return nil, &CoercionError{Value: value, Target: dataType.String()}
+	}
+	return converted, nil
+}
+
+// coerceColumnValue приводит значение к типу столбца; для DECIMAL(p, s) значение
+// округляется до s знаков после запятой и проверяется на переполнение.
+func coerceColumnValue(value interface{}, col Column) (interface{}, error) {
+	value, err := coerceValue(value, col.Type)
+	if d, ok := value.(Decimal); ok && err == nil {
+		return fitDecimal(d, col.Precision, col.Scale)
+	}
+	return value, err
+}
+
+// castValue приводит значение к типу target. В отличие от неявного приведения
+// при вставке дробное число округляется до целого, а логические значения
+// и числа приводятся друг к другу.
+func castValue(value interface{}, target Column) (interface{}, error) {
+	if b, ok := value.(bool); ok && isNumericType(target.Type) {
+		value = boolToInt(b)
+	}
+	if target.Type == BOOLEAN && isNumericValue(value) {
+		return !isZero(value), nil
+	}
+	if target.Type == INTEGER {
+		switch v := value.(type) {
+		case float64:
+			return int(math.Round(v)), nil
+		case Decimal:
+			if n, ok := v.toInt(); ok {
+				return n, nil
+			}
+		}
+	}
+	return coerceColumnValue(value, target)
+}
+
+func parseValue(value string, dataType DataType) (interface{}, error) {
+	switch dataType {
+	case INTEGER:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if errors.Is(err, strconv.ErrRange) {
+			return nil, &CoercionError{Value: value, Target: dataType.String(), Reason: "значение вне диапазона"}
+		}
+		return n, err
+	case FLOAT:
+		return strconv.ParseFloat(strings.TrimSpace(value), 64)
+	case STRING:
+		return value, nil
+	case BOOLEAN:
+		return parseBool(value)
+	case DATE, TIME, TIMESTAMP, TIMESTAMPTZ, INTERVAL:
+		return parseTemporal(value, dataType)
+	case DECIMAL:
+		if d, ok := parseDecimal(value); ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("не удалось преобразовать '%s' в DECIMAL", value)
+	case JSON:
+		return parseJSON(value)
+	case BYTEA:
+		return parseBytea(value)
+	case UUID:
+		return parseUUID(value)
+	default:
+		return nil, fmt.Errorf("неизвестный тип данных %v", dataType)
+	}
+}
+
+// parseBool принимает те же записи логических значений, что и PostgreSQL.
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "t", "yes", "y", "on", "1":
+		return true, nil
+	case "false", "f", "no", "n", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("не удалось преобразовать '%s' в BOOLEAN", value)
+}
+
+// valueType возвращает тип данных значения; для NULL ok = false.
+func valueType(value interface{}) (DataType, bool) {
+	switch value.(type) {
+	case int:
+		return INTEGER, true
+	case float64:
+		return FLOAT, true
+	case string:
+		return STRING, true
+	case bool:
+		return BOOLEAN, true
+	case Decimal:
+		return DECIMAL, true
+	case JSONValue:
+		return JSON, true
+	case Bytes:
+		return BYTEA, true
+	case UUIDValue:
+		return UUID, true
+	}
+	return temporalType(value)
+}
+
+// typeNameOf возвращает имя SQL-типа значения для сообщений об ошибках.
+func typeNameOf(value interface{}) string {
+	if value == nil {
+		return "NULL"
+	}
+	if dataType, ok := valueType(value); ok {
+		return dataType.String()
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func isNumericType(dataType DataType) bool {
+	return dataType == INTEGER || dataType == FLOAT || dataType == DECIMAL
+}
+
+func isNumericValue(value interface{}) bool {
+	switch value.(type) {
+	case int, float64, Decimal:
+		return true
+	}
+	return false
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case Decimal:
+		return v.float64()
+	}
+	return value.(float64)
+}
+
+// promoteNumeric приводит два числа к общему типу по цепочке INTEGER → FLOAT → DECIMAL;
+// ok = false, если один из операндов не число.
+func promoteNumeric(a, b interface{}) (interface{}, interface{}, bool) {
+	if !isNumericValue(a) || !isNumericValue(b) {
+		return nil, nil, false
+	}
+	_, aDecimal := a.(Decimal)
+	_, bDecimal := b.(Decimal)
+	if aDecimal || bDecimal {
+		ad, okA := toDecimal(a)
+		bd, okB := toDecimal(b)
+		return ad, bd, okA && okB
+	}
+	_, aFloat := a.(float64)
+	_, bFloat := b.(float64)
+	if aFloat || bFloat {
+		return toFloat(a), toFloat(b), true
+	}
+	return a, b, true
+}
+
+// promotableTypes возвращает типы, значения которых неявно приводятся к dataType:
+// сам тип и числовые типы меньшей точности (INTEGER → FLOAT → DECIMAL).
+func promotableTypes(dataType DataType) []DataType {
+	switch dataType {
+	case FLOAT:
+		return []DataType{INTEGER, FLOAT}
+	case DECIMAL:
+		return []DataType{INTEGER, FLOAT, DECIMAL}
+	}
+	return []DataType{dataType}
+}
+
+// coerceLiteral приводит строковую константу условия к типу значения столбца.
+func coerceLiteral(value, literal interface{}) (interface{}, error) {
+	s, ok := literal.(string)
+	if !ok {
+		return literal, nil
+	}
+	dataType, known := valueType(value)
+	if !known || dataType == STRING {
+		return literal, nil
+	}
+	return coerceValue(s, dataType)
+}
+
+// compareTyped сравнивает два значения не NULL и возвращает -1, 0 или 1.
+// Несравнимые типы — ошибка.
+func compareTyped(a, b interface{}) (int, error) {
+	if pa, pb, ok := promoteNumeric(a, b); ok {
+		switch av := pa.(type) {
+		case int:
+			return compareInts(av, pb.(int)), nil
+		case float64:
+			return compareFloats(av, pb.(float64)), nil
+		case Decimal:
+			return av.Cmp(pb.(Decimal)), nil
+		}
+	}
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return compareInts(boolToInt(av), boolToInt(bv)), nil
+		}
+	case Date, TimeOfDay, Timestamp, TimestampTZ, Interval:
+		return compareTemporal(av, b)
+	case Bytes, UUIDValue:
+		return compareBinary(av, b)
+	case JSONValue:
+		if bv, ok := b.(JSONValue); ok {
+			if jsonEqual(av.doc, bv.doc) {
+				return 0, nil
+			}
+			return strings.Compare(jsonKey(av), jsonKey(bv)), nil
+		}
+	}
+	return 0, fmt.Errorf("несоответствие типов: сравнение %s с %s", typeNameOf(a), typeNameOf(b))
+}
+
+// applyComparison переводит результат сравнения cmp (-1, 0, 1) в значение оператора.
+func applyComparison(operator string, cmp int) (bool, error) {
+	switch operator {
+	case "=":
+		return cmp == 0, nil
+	case "!=", "<>":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("неподдерживаемый оператор '%s'", operator)
+	}
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
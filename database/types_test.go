@@ -0,0 +1,106 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBooleanNumberCoercionIsExplicit(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE f (id INTEGER, active BOOLEAN, n INTEGER)",
+		"INSERT INTO f VALUES (1, '1', 5)",
+	)
+	for _, query := range []string{
+		"INSERT INTO f VALUES (2, 1, 0)",
+		"INSERT INTO f VALUES (2, TRUE, TRUE)",
+		"UPDATE f SET n = active WHERE id = 1",
+		"UPDATE f SET active = 0 WHERE id = 1",
+		"SELECT id FROM f WHERE active = 1",
+	} {
+		mustFail(t, db, query)
+	}
+	mustExec(t, db, "INSERT INTO f VALUES (2, CAST(0 AS BOOLEAN), TRUE::int)")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, active, n FROM f WHERE active = TRUE OR n = 1 ORDER BY id")); got != "[[1 true 5] [2 false 1]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT CAST(0.0 AS BOOLEAN), CAST(TRUE AS DECIMAL(4,1)), 2::boolean FROM f WHERE id = 1")); got != "[[false 1.0 true]]" {
+		t.Fatalf("CAST: %s", got)
+	}
+}
+
+func TestBooleanColumn(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE tasks (id INTEGER PRIMARY KEY, title STRING, done BOOL DEFAULT FALSE)",
+		"INSERT INTO tasks (id, title, done) VALUES (1, 'bread', TRUE)",
+		"INSERT INTO tasks (id, title) VALUES (2, 'call')",
+		"INSERT INTO tasks (id, title, done) VALUES (3, 'report', NULL)",
+		"INSERT INTO tasks (id, title, done) VALUES (4, 'mail', 'yes')",
+		"INSERT INTO tasks (id, title, done) VALUES (5, 'walk', 'off')",
+	)
+	mustFail(t, db, "INSERT INTO tasks (id, title, done) VALUES (6, 'x', 'maybe')")
+
+	cases := map[string]string{
+		"SELECT id FROM tasks WHERE done ORDER BY id":                "[[1] [4]]",
+		"SELECT id FROM tasks WHERE NOT done AND id > 1 ORDER BY id": "[[2] [5]]",
+		"SELECT id FROM tasks WHERE done IS NOT TRUE ORDER BY id":    "[[2] [3] [5]]",
+		"SELECT id FROM tasks WHERE done IS FALSE ORDER BY id":       "[[2] [5]]",
+		"SELECT id FROM tasks WHERE done = FALSE ORDER BY id":        "[[2] [5]]",
+		"SELECT done FROM tasks ORDER BY done, id":                   "[[false] [false] [true] [true] [<nil>]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	reopened := NewDatabase()
+	rows := mustQuery(t, reopened, "SELECT done FROM tasks WHERE id = 1")
+	if _, ok := rows[0][0].(bool); !ok || fmt.Sprint(rows) != "[[true]]" {
+		t.Fatalf("после загрузки: %#v", rows)
+	}
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT id FROM tasks WHERE done IS NULL")); got != "[[3]]" {
+		t.Fatalf("NULL после загрузки: %s", got)
+	}
+}
+
+func TestCastAndImplicitCoercion(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, price FLOAT, code STRING, added DATE)",
+		"INSERT INTO items VALUES (30, 2.345, '42', '2023-01-15')",
+		"INSERT INTO items VALUES (31, 7.5, 'x', '2023-02-01')",
+	)
+	cases := map[string]string{
+		"SELECT CAST(price AS DECIMAL(8, 2)) FROM items WHERE id = 30":                  "[[2.35]]",
+		"SELECT CAST(price AS INTEGER), price::int FROM items WHERE id = 31":            "[[8 8]]",
+		"SELECT code::int + 1 FROM items WHERE id = 30":                                 "[[43]]",
+		"SELECT CAST(added AS STRING), CAST(id AS STRING) FROM items WHERE id = 30":     "[[2023-01-15 30]]",
+		"SELECT CAST(added AS TIMESTAMP) FROM items WHERE id = 30":                      "[[2023-01-15 00:00:00]]",
+		"SELECT CAST(FALSE AS STRING), CAST('yes' AS BOOLEAN) FROM items WHERE id = 30": "[[false true]]",
+		"SELECT CAST(NULL AS INTEGER) FROM items WHERE id = 30":                         "[[<nil>]]",
+		"SELECT id FROM items WHERE id = 30.0":                                          "[[30]]",
+		"SELECT id FROM items WHERE added >= '2023-01-20'":                              "[[31]]",
+		"SELECT id FROM items ORDER BY price + id DESC":                                 "[[31] [30]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	for _, query := range []string{
+		"SELECT 'abc'::int FROM items",
+		"SELECT CAST(added AS INTEGER) FROM items",
+		"SELECT id FROM items WHERE code = 42",
+		"SELECT id FROM items WHERE added = 5",
+		"INSERT INTO items (id) VALUES (2.5)",
+		"UPDATE items SET added = price",
+	} {
+		mustFail(t, db, query)
+	}
+	mustExec(t, db, "UPDATE items SET id = CAST(32.4 AS INTEGER) WHERE id = 31")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id FROM items ORDER BY id")); got != "[[30] [32]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OnConflict — действие INSERT ... ON CONFLICT для InsertOnConflict. Конфликт
+// определяется уникальным индексом или ограничением PRIMARY KEY / UNIQUE: по
+// имени Constraint, по набору столбцов (или выражений индекса) Columns, а если
+// не задано ни то ни другое — любым из них. Пустой Update означает DO NOTHING.
+// В выражениях Update и Where текущая строка доступна по именам столбцов,
+// а предлагаемая к вставке — как excluded.столбец.
+type OnConflict struct {
+	Columns    []string
+	Constraint string
+	Update     []Assignment
+	Where      *Condition
+}
+
+// resolveConflict ищет строку, с которой конфликтует row, и выполняет для неё
+// действие conflict. handled сообщает, что конфликт найден и вставлять row не нужно;
+// updated — новая версия строки, если она была обновлена.
+func (db *Database) resolveConflict(tableName string, table *Table, row []interface{}, conflict *OnConflict) ([]interface{}, bool, error) {
+	arbiters, err := conflictIndexes(table, conflict)
+	if err != nil {
+		return nil, false, err
+	}
+	pos := -1
+	for _, idx := range arbiters {
+		values := idx.keyValues(table, row)
+		if hasNullValue(values) {
+			continue
+		}
+		if positions := idx.lookup(values); len(positions) > 0 {
+			pos = positions[0]
+			break
+		}
+	}
+	if pos == -1 {
+		return nil, false, nil
+	}
+	if len(conflict.Update) == 0 {
+		return nil, true, nil
+	}
+
+	colIndexes, err := assignmentColumns(tableName, table, conflict.Update)
+	if err != nil {
+		return nil, false, err
+	}
+	env := append(append([]interface{}{}, table.Rows[pos]...), row...)
+	var columnNames []string
+	for _, col := range table.Columns {
+		columnNames = append(columnNames, table.Name+"."+col.Name)
+	}
+	for _, col := range table.Columns {
+		columnNames = append(columnNames, "excluded."+col.Name)
+	}
+	if conflict.Where != nil {
+		match, err := evaluateCondition(env, columnNames, conflict.Where)
+		if err != nil || !match {
+			return nil, true, err
+		}
+	}
+	newRow, err := db.assignValues(table, env, columnNames, conflict.Update, colIndexes)
+	if err != nil {
+		return nil, false, err
+	}
+	return newRow, true, db.applyRowChanges(tableName, table, []rowChange{{pos: pos, row: newRow}})
+}
+
+// conflictIndexes возвращает уникальные индексы, по которым ищется конфликт.
+// Ограничения PRIMARY KEY и UNIQUE хранятся как одноимённые уникальные индексы.
+func conflictIndexes(table *Table, conflict *OnConflict) ([]*Index, error) {
+	var arbiters []*Index
+	switch {
+	case conflict.Constraint != "":
+		for _, idx := range table.Indexes {
+			if strings.EqualFold(idx.Name, conflict.Constraint) {
+				if !idx.Unique {
+					return nil, fmt.Errorf("индекс '%s' не является уникальным и не может определять конфликт", idx.Name)
+				}
+				return []*Index{idx}, nil
+			}
+		}
+		return nil, fmt.Errorf("ограничение '%s' не найдено в таблице '%s'", conflict.Constraint, table.Name)
+	case len(conflict.Columns) > 0:
+		for _, idx := range table.Indexes {
+			if idx.Unique && sameColumnSet(idx.Columns, conflict.Columns) {
+				arbiters = append(arbiters, idx)
+			}
+		}
+		if len(arbiters) == 0 {
+			return nil, fmt.Errorf("в таблице '%s' нет ограничения уникальности или уникального индекса по (%s)", table.Name, strings.Join(conflict.Columns, ", "))
+		}
+	case len(conflict.Update) > 0:
+		return nil, errors.New("ON CONFLICT DO UPDATE требует указать столбцы или ограничение")
+	default:
+		for _, idx := range table.Indexes {
+			if idx.Unique {
+				arbiters = append(arbiters, idx)
+			}
+		}
+	}
+	return arbiters, nil
+}
+
+// parseOnConflict разбирает ON CONFLICT [(столбцы) | ON CONSTRAINT имя]
+// DO NOTHING | DO UPDATE SET столбец = выражение [, ...] [WHERE условие],
+// начиная с токена ON.
+func parseOnConflict(db *Database, tokens []string, start, end int) (*OnConflict, error) {
+	if start+1 >= end || strings.ToUpper(tokens[start]) != "ON" || strings.ToUpper(tokens[start+1]) != "CONFLICT" {
+		return nil, fmt.Errorf("неверный синтаксис INSERT: неожиданный токен '%s'", tokens[start])
+	}
+	conflict := &OnConflict{}
+	i := start + 2
+	switch {
+	case i < end && tokens[i] == "(":
+		closing := matchingParen(tokens, i, end)
+		if closing == -1 {
+			return nil, errors.New("неверный синтаксис ON CONFLICT: отсутствует закрывающая скобка")
+		}
+		exprs, err := parseExpressionList(db, tokens, i+1, closing, "ON CONFLICT")
+		if err != nil {
+			return nil, err
+		}
+		for _, expr := range exprs {
+			conflict.Columns = append(conflict.Columns, exprString(expr))
+		}
+		i = closing + 1
+	case i+2 < end && strings.ToUpper(tokens[i]) == "ON" && strings.ToUpper(tokens[i+1]) == "CONSTRAINT":
+		conflict.Constraint = tokens[i+2]
+		i += 3
+	}
+
+	if i+1 >= end || strings.ToUpper(tokens[i]) != "DO" {
+		return nil, errors.New("неверный синтаксис ON CONFLICT: ожидалось DO NOTHING или DO UPDATE")
+	}
+	switch strings.ToUpper(tokens[i+1]) {
+	case "NOTHING":
+		if i+2 < end {
+			return nil, fmt.Errorf("неверный синтаксис ON CONFLICT: неожиданный токен '%s'", tokens[i+2])
+		}
+		return conflict, nil
+	case "UPDATE":
+	default:
+		return nil, errors.New("неверный синтаксис ON CONFLICT: ожидалось DO NOTHING или DO UPDATE")
+	}
+	i += 2
+	if i >= end || strings.ToUpper(tokens[i]) != "SET" {
+		return nil, errors.New("неверный синтаксис ON CONFLICT DO UPDATE: ожидалось SET")
+	}
+	whereIndex := findClause(tokens, i+1, "WHERE")
+	if whereIndex > end {
+		whereIndex = end
+	}
+	assignments, err := parseAssignments(db, tokens, i+1, whereIndex)
+	if err != nil {
+		return nil, err
+	}
+	conflict.Update = assignments
+	if whereIndex < end {
+		if whereIndex+1 >= end {
+			return nil, errors.New("неверный синтаксис WHERE: отсутствует условие")
+		}
+		if conflict.Where, err = parseWhereRange(db, tokens, whereIndex+1, end); err != nil {
+			return nil, err
+		}
+	}
+	return conflict, nil
+}
+
+// parseAssignments разбирает список столбец = выражение [, ...].
+func parseAssignments(db *Database, tokens []string, start, end int) ([]Assignment, error) {
+	var assignments []Assignment
+	for i := start; i < end; {
+		if i+2 >= end || tokens[i+1] != "=" {
+			return nil, errors.New("неверный синтаксис SET: ожидалось 'столбец = выражение'")
+		}
+		value, next, err := parseValueExpression(db, tokens, i+2, end)
+		if err != nil {
+			return nil, fmt.Errorf("неверный синтаксис SET: %v", err)
+		}
+		if next < end && tokens[next] != "," {
+			return nil, fmt.Errorf("неверный синтаксис SET: неожиданный токен '%s'", tokens[next])
+		}
+		assignments = append(assignments, Assignment{Column: tokens[i], Value: value})
+		i = next + 1
+	}
+	if len(assignments) == 0 {
+		return nil, errors.New("неверный синтаксис SET: отсутствуют присваивания")
+	}
+	return assignments, nil
+}
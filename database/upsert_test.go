@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOnConflictUpdateWhereComparesExcluded(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE prices (sku STRING PRIMARY KEY, price DECIMAL(6,2), updated DATE)",
+		"INSERT INTO prices (sku, price, updated) VALUES ('A-1', 8.50, '2024-04-01')",
+		// Пример из examples/insert_data.md.
+		`INSERT INTO prices (sku, price, updated) VALUES ('A-1', 9.90, '2024-05-01')
+		ON CONFLICT ON CONSTRAINT prices_pkey
+		DO UPDATE SET price = excluded.price, updated = excluded.updated WHERE excluded.updated > prices.updated`,
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT price, CAST(updated AS STRING) FROM prices")); got != "[[9.90 2024-05-01]]" {
+		t.Fatalf("после свежего обновления: %s", got)
+	}
+
+	mustExec(t, db, `INSERT INTO prices (sku, price, updated) VALUES ('A-1', 7.00, '2024-03-01')
+		ON CONFLICT ON CONSTRAINT prices_pkey
+		DO UPDATE SET price = excluded.price, updated = excluded.updated WHERE excluded.updated > prices.updated`)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT price, CAST(updated AS STRING) FROM prices")); got != "[[9.90 2024-05-01]]" {
+		t.Fatalf("устаревшие данные изменили строку: %s", got)
+	}
+}
+
+func TestOnConflictReturningInsertedAndConflictingRows(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE stats (page STRING PRIMARY KEY, views INTEGER, note STRING)",
+		"INSERT INTO stats VALUES ('/home', 1, NULL)",
+	)
+
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO stats VALUES ('/x', 1, NULL) ON CONFLICT (page) DO NOTHING RETURNING page, views")); got != "[[/x 1]]" {
+		t.Fatalf("вставка без конфликта: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO stats VALUES ('/home', 5, NULL) ON CONFLICT (page) DO NOTHING RETURNING page")); got != "[]" {
+		t.Fatalf("DO NOTHING при конфликте: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO stats VALUES ('/y', 2, NULL) ON CONFLICT (page) DO UPDATE SET views = stats.views + excluded.views RETURNING page, views")); got != "[[/y 2]]" {
+		t.Fatalf("DO UPDATE без конфликта: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "INSERT INTO stats VALUES ('/home', 5, NULL) ON CONFLICT (page) DO UPDATE SET views = stats.views + excluded.views RETURNING page, views")); got != "[[/home 6]]" {
+		t.Fatalf("DO UPDATE при конфликте: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT page, views FROM stats ORDER BY page")); got != "[[/home 6] [/x 1] [/y 2]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestOnConflictDoNothingAndDoUpdate(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER AUTO_INCREMENT PRIMARY KEY, email STRING UNIQUE, name STRING)",
+		"CREATE UNIQUE INDEX users_email_ci ON users ((lower(email)))",
+		"CREATE TABLE stats (page STRING PRIMARY KEY, views INTEGER)",
+		"INSERT INTO users (email, name) VALUES ('alice@example.com', 'Alice')",
+		"INSERT INTO users (email, name) VALUES ('alice@example.com', 'Twin') ON CONFLICT (email) DO NOTHING",
+		"INSERT INTO users (email, name) VALUES ('ALICE@example.com', 'Upper') ON CONFLICT DO NOTHING",
+		"INSERT INTO users (email, name) VALUES ('Alice@Example.com', 'Mixed') ON CONFLICT ((lower(email))) DO UPDATE SET name = excluded.name",
+		"INSERT INTO stats (page, views) VALUES ('/home', 1) ON CONFLICT (page) DO UPDATE SET views = stats.views + excluded.views",
+		"INSERT INTO stats (page, views) VALUES ('/home', 5) ON CONFLICT (page) DO UPDATE SET views = stats.views + excluded.views",
+	)
+	mustFail(t, db, "INSERT INTO users (email, name) VALUES ('alice@example.com', 'X') ON CONFLICT (name) DO NOTHING")
+	mustFail(t, db, "INSERT INTO users (email, name) VALUES ('alice@example.com', 'X') ON CONFLICT DO UPDATE SET name = 'X'")
+	mustFail(t, db, "INSERT INTO users (id, email, name) VALUES (1, 'bob@example.com', 'Bob') ON CONFLICT (email) DO NOTHING")
+
+	cases := map[string]string{
+		"SELECT id, email, name FROM users": "[[1 alice@example.com Mixed]]",
+		"SELECT views FROM stats":           "[[6]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	mustExec(t, db,
+		"BEGIN",
+		"INSERT INTO stats (page, views) VALUES ('/home', 10) ON CONFLICT (page) DO UPDATE SET views = stats.views + excluded.views",
+		"ROLLBACK",
+	)
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT views FROM stats")); got != "[[6]]" {
+		t.Fatalf("после ROLLBACK: %s", got)
+	}
+}
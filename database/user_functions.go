@@ -0,0 +1,282 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Function — скалярная функция на Go для RegisterFunction. Args задаёт типы
+// аргументов, Return — тип результата. Аргументы INTEGER и FLOAT неявно
+// приводятся к объявленному FLOAT или DECIMAL, NULL передаётся в Call как nil.
+type Function struct {
+	Args   []DataType
+	Return DataType
+	Call   func(args []interface{}) (interface{}, error)
+}
+
+// Aggregate — агрегатная функция на Go для RegisterAggregate. Init создаёт
+// начальное состояние (nil, если не задана), Step добавляет к нему значение
+// аргумента типа Arg (NULL пропускаются), Final возвращает результат типа Return
+// (без Final результатом будет само состояние).
+type Aggregate struct {
+	Arg    DataType
+	Return DataType
+	Init   func() interface{}
+	Step   func(state, value interface{}) (interface{}, error)
+	Final  func(state interface{}) (interface{}, error)
+}
+
+// RegisterFunction регистрирует скалярную функцию, доступную в любых выражениях
+// запросов этой базы. Повторная регистрация заменяет прежнюю функцию; встроенные
+// функции переопределить нельзя.
+func (db *Database) RegisterFunction(name string, fn Function) error {
+	name, err := userFunctionName(name)
+	if err != nil {
+		return err
+	}
+	if fn.Call == nil {
+		return fmt.Errorf("функция '%s': не задана реализация Call", name)
+	}
+	declared := append([]DataType(nil), fn.Args...)
+	argTypes := make([][]DataType, len(declared))
+	for i, dataType := range declared {
+		argTypes[i] = promotableTypes(dataType)
+	}
+	call, returnType := fn.Call, fn.Return
+	f := &builtinFunction{
+		fn: func(args []interface{}) (interface{}, error) {
+			converted := make([]interface{}, len(args))
+			for i, arg := range args {
+				value, err := coerceValue(arg, declared[i])
+				if err != nil {
+					return nil, fmt.Errorf("функция '%s': %v", name, err)
+				}
+				converted[i] = value
+			}
+			result, err := call(converted)
+			if err != nil {
+				return nil, err
+			}
+			return coerceResult(name, result, returnType)
+		},
+		minArgs:    len(declared),
+		maxArgs:    len(declared),
+		argTypes:   argTypes,
+		returnType: returnType,
+		typed:      true,
+	}
+
+	return db.register(name, f, nil)
+}
+
+// RegisterAggregate регистрирует агрегатную функцию, доступную в запросах этой
+// базы так же, как sum или avg. Повторная регистрация заменяет прежнюю функцию;
+// встроенные функции переопределить нельзя.
+func (db *Database) RegisterAggregate(name string, agg Aggregate) error {
+	name, err := userFunctionName(name)
+	if err != nil {
+		return err
+	}
+	if agg.Step == nil {
+		return fmt.Errorf("агрегатная функция '%s': не задана реализация Step", name)
+	}
+	init, step, final := agg.Init, agg.Step, agg.Final
+	if init == nil {
+		init = initNull
+	}
+	if final == nil {
+		final = finalIdentity
+	}
+	argType, returnType := agg.Arg, agg.Return
+	allowed := promotableTypes(argType)
+	f := &aggregateFunction{
+		init: init,
+		step: func(state, value interface{}) (interface{}, error) {
+			if actual, ok := valueType(value); ok {
+				if err := checkArgType(name, 0, allowed, actual); err != nil {
+					return nil, err
+				}
+			}
+			value, err := coerceValue(value, argType)
+			if err != nil {
+				return nil, fmt.Errorf("функция '%s': %v", name, err)
+			}
+			return step(state, value)
+		},
+		final: func(state interface{}) (interface{}, error) {
+			result, err := final(state)
+			if err != nil {
+				return nil, err
+			}
+			return coerceResult(name, result, returnType)
+		},
+		resultType: func(DataType) DataType { return returnType },
+		argTypes:   allowed,
+	}
+
+	return db.register(name, nil, f)
+}
+
+// register сохраняет скалярную (fn) или агрегатную (agg) функцию под именем name
+// и связывает с ней выражения схемы. Если схему связать не удалось, прежняя
+// регистрация восстанавливается.
+func (db *Database) register(name string, fn *builtinFunction, agg *aggregateFunction) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.functionsMu.Lock()
+	prevFn, prevAgg := db.functions[name], db.aggregates[name]
+	db.setFunction(name, fn, agg)
+	db.functionsMu.Unlock()
+
+	if err := db.rebindSchema(); err != nil {
+		db.functionsMu.Lock()
+		db.setFunction(name, prevFn, prevAgg)
+		db.functionsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (db *Database) setFunction(name string, fn *builtinFunction, agg *aggregateFunction) {
+	if db.functions == nil {
+		db.functions = make(map[string]*builtinFunction)
+	}
+	if db.aggregates == nil {
+		db.aggregates = make(map[string]*aggregateFunction)
+	}
+	delete(db.functions, name)
+	delete(db.aggregates, name)
+	if fn != nil {
+		db.functions[name] = fn
+	}
+	if agg != nil {
+		db.aggregates[name] = agg
+	}
+}
+
+// rebindSchema связывает выражения схемы с зарегистрированными функциями:
+// индексы по выражениям перестраиваются, а разобранные условия CHECK сбрасываются.
+// Так индекс, прочитанный с диска до регистрации функции, начинает её использовать.
+// Индексы заменяются, только если все они построены и уникальные индексы не
+// содержат повторяющихся ключей; иначе схема остаётся прежней.
+func (db *Database) rebindSchema() error {
+	rebuilt := make(map[*Index]*Index)
+	for _, table := range db.Tables {
+		for _, idx := range table.Indexes {
+			if !idx.hasExpressions() {
+				continue
+			}
+			fresh := &Index{Name: idx.Name, Columns: append([]string(nil), idx.Columns...), Unique: idx.Unique, Method: idx.Method}
+			if err := fresh.rebuild(db, table); err != nil {
+				return fmt.Errorf("ошибка построения индекса '%s' таблицы '%s': %v", idx.Name, table.Name, err)
+			}
+			if values := fresh.duplicateKey(table); values != nil {
+				return fmt.Errorf("уникальный индекс '%s' таблицы '%s' нарушен: значение (%s)=(%s) повторяется", idx.Name, table.Name, strings.Join(idx.Columns, ", "), formatKeyValues(values))
+			}
+			rebuilt[idx] = fresh
+		}
+	}
+	for _, table := range db.Tables {
+		for i := range table.Constraints {
+			table.Constraints[i].check = nil
+		}
+		for i, idx := range table.Indexes {
+			if fresh, ok := rebuilt[idx]; ok {
+				table.Indexes[i] = fresh
+			}
+		}
+	}
+	return nil
+}
+
+// userFunctionName проверяет имя регистрируемой функции и приводит его к нижнему
+// регистру: имена функций, как и в запросах, не зависят от регистра.
+func userFunctionName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "", errors.New("имя функции не может быть пустым")
+	}
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return "", fmt.Errorf("недопустимое имя функции '%s'", name)
+		}
+	}
+	_, scalar := scalarFunctions[name]
+	_, aggregate := aggregateFunctions[name]
+	_, window := windowFunctions[name]
+	_, niladic := niladicFunctions[strings.ToUpper(name)]
+	switch {
+	case scalar || aggregate || window:
+		return "", fmt.Errorf("функция '%s' встроенная и не может быть переопределена", name)
+	case niladic || name == "cast" || name == "extract" || name == "case":
+		return "", fmt.Errorf("имя '%s' зарезервировано", name)
+	}
+	return name, nil
+}
+
+func coerceResult(name string, result interface{}, returnType DataType) (interface{}, error) {
+	value, err := coerceValue(result, returnType)
+	if err != nil {
+		return nil, fmt.Errorf("функция '%s' вернула значение, несовместимое с типом %s: %v", name, returnType, err)
+	}
+	return value, nil
+}
+
+// lookupFunction ищет функцию по имени среди встроенных и зарегистрированных в базе.
+func (db *Database) lookupFunction(name string) (*builtinFunction, *aggregateFunction) {
+	if fn, exists := scalarFunctions[name]; exists {
+		return fn, nil
+	}
+	if agg, exists := aggregateFunctions[name]; exists {
+		return nil, agg
+	}
+	db.functionsMu.RLock()
+	defer db.functionsMu.RUnlock()
+	return db.functions[name], db.aggregates[name]
+}
+
+// bindFunction находит функцию вызова и проверяет его при разборе запроса:
+// функция должна существовать, число аргументов — соответствовать сигнатуре,
+// а аргументы, тип которых известен без данных (литералы, CAST, функции
+// с объявленным типом), — подходить по типу. Функция сохраняется в expr.Value.
+func (db *Database) bindFunction(expr *Expr) error {
+	fn, agg := db.lookupFunction(expr.Name)
+	switch {
+	case fn != nil:
+		if err := fn.checkArity(expr.Name, len(expr.Args)); err != nil {
+			return err
+		}
+		for i, arg := range expr.Args {
+			if actual, ok := expressionType(arg, nil, nil); ok {
+				if err := checkArgType(expr.Name, i, fn.argType(i), actual); err != nil {
+					return err
+				}
+			}
+		}
+		expr.Value = fn
+	case agg != nil:
+		if len(expr.Args) != 1 {
+			return fmt.Errorf("агрегатная функция '%s' ожидает 1 аргумент, получено %d", expr.Name, len(expr.Args))
+		}
+		if actual, ok := expressionType(expr.Args[0], nil, nil); ok {
+			if err := checkArgType(expr.Name, 0, agg.argTypes, actual); err != nil {
+				return err
+			}
+		}
+		expr.Value = agg
+	default:
+		if db.loading {
+			// Выражения схемы читаются с диска до регистрации функций: вызов
+			// остаётся несвязанным, а индексы перестраиваются при RegisterFunction.
+			return nil
+		}
+		if _, exists := windowFunctions[expr.Name]; exists {
+			return fmt.Errorf("оконная функция '%s' требует предложения OVER", expr.Name)
+		}
+		return fmt.Errorf("неизвестная функция '%s'", expr.Name)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func registerSlugify(t *testing.T, db *Database) {
+	t.Helper()
+	err := db.RegisterFunction("slugify", Function{
+		Args:   []DataType{STRING},
+		Return: STRING,
+		Call: func(args []interface{}) (interface{}, error) {
+			if args[0] == nil {
+				return nil, nil
+			}
+			return strings.ReplaceAll(strings.ToLower(args[0].(string)), " ", "-"), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction: %v", err)
+	}
+}
+
+func TestRegisteredFunctionInSchemaExpressions(t *testing.T) {
+	db := newTestDatabase(t)
+	registerSlugify(t, db)
+	mustExec(t, db,
+		"CREATE TABLE posts (id INTEGER, title STRING CHECK (slugify(title) <> 'draft'), slug STRING DEFAULT slugify('Un Titled'))",
+		"CREATE UNIQUE INDEX posts_slug ON posts ((slugify(title)))",
+		"INSERT INTO posts (id, title) VALUES (1, 'Hello World')",
+	)
+	mustFail(t, db, "INSERT INTO posts (id, title) VALUES (2, 'Draft')")
+	mustFail(t, db, "INSERT INTO posts (id, title) VALUES (3, 'hello world')")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, slug FROM posts WHERE slugify(title) = 'hello-world'")); got != "[[1 un-titled]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestExpressionIndexRebuiltAfterRegistration(t *testing.T) {
+	db := newTestDatabase(t)
+	registerSlugify(t, db)
+	mustExec(t, db,
+		"CREATE TABLE posts (id INTEGER, title STRING)",
+		"INSERT INTO posts VALUES (1, 'Hello World')",
+		"CREATE UNIQUE INDEX posts_slug ON posts ((slugify(title)))",
+	)
+
+	reopened := NewDatabase()
+	if reopened.Tables["posts"] == nil {
+		t.Fatal("таблица posts не загружена с диска")
+	}
+	registerSlugify(t, reopened)
+	mustFail(t, reopened, "INSERT INTO posts VALUES (2, 'hello world')")
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT id FROM posts WHERE slugify(title) = 'hello-world'")); got != "[[1]]" {
+		t.Fatalf("строки таблицы: %s", got)
+	}
+}
+
+func TestReregisterFunctionKeepsUniqueExpressionIndex(t *testing.T) {
+	db := newTestDatabase(t)
+	registerSlugify(t, db)
+	mustExec(t, db,
+		"CREATE TABLE posts (id INTEGER, title STRING)",
+		"INSERT INTO posts VALUES (1, 'Hello World')",
+		"INSERT INTO posts VALUES (2, 'Goodbye')",
+		"CREATE UNIQUE INDEX posts_slug ON posts ((slugify(title)))",
+	)
+	err := db.RegisterFunction("slugify", Function{
+		Args:   []DataType{STRING},
+		Return: STRING,
+		Call:   func(args []interface{}) (interface{}, error) { return "same", nil },
+	})
+	if err == nil {
+		t.Fatal("повторная регистрация нарушила уникальный индекс без ошибки")
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT slugify(title) FROM posts ORDER BY id")); got != "[[hello-world] [goodbye]]" {
+		t.Fatalf("прежняя функция не восстановлена: %s", got)
+	}
+	mustFail(t, db, "INSERT INTO posts VALUES (3, 'hello world')")
+	mustExec(t, db, "INSERT INTO posts VALUES (4, 'Another')")
+}
+
+func TestRegisteredScalarAndAggregateFunctions(t *testing.T) {
+	db := newTestDatabase(t)
+	registerSlugify(t, db)
+	err := db.RegisterAggregate("Product", Aggregate{
+		Arg:    INTEGER,
+		Return: INTEGER,
+		Init:   func() interface{} { return 1 },
+		Step: func(state, value interface{}) (interface{}, error) {
+			return state.(int) * value.(int), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterAggregate: %v", err)
+	}
+	err = db.RegisterFunction("half", Function{
+		Args:   []DataType{FLOAT},
+		Return: DECIMAL,
+		Call: func(args []interface{}) (interface{}, error) {
+			if args[0] == nil {
+				return nil, nil
+			}
+			return args[0].(float64) / 2, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction: %v", err)
+	}
+	if err := db.RegisterFunction("upper", Function{Return: STRING, Call: func([]interface{}) (interface{}, error) { return "", nil }}); err == nil {
+		t.Fatal("встроенная функция переопределена")
+	}
+	if err := db.RegisterAggregate("broken", Aggregate{Arg: INTEGER, Return: INTEGER}); err == nil {
+		t.Fatal("агрегат без Step зарегистрирован")
+	}
+
+	mustExec(t, db,
+		"CREATE TABLE posts (id INTEGER, author STRING, title STRING, rating INTEGER)",
+		"INSERT INTO posts VALUES (1, 'ann', 'Hello World', 2)",
+		"INSERT INTO posts VALUES (2, 'ann', 'Second Post', 3)",
+		"INSERT INTO posts VALUES (3, 'bob', 'Other', NULL)",
+		"INSERT INTO posts VALUES (4, 'bob', 'More', 5)",
+	)
+	cases := map[string]string{
+		"SELECT id FROM posts WHERE SLUGIFY(title) = 'hello-world'":                                                        "[[1]]",
+		"SELECT author, product(rating) FROM posts GROUP BY author ORDER BY author":                                        "[[ann 6] [bob 5]]",
+		"SELECT half(rating), half(NULL) FROM posts WHERE id = 2":                                                          "[[1.5 <nil>]]",
+		"SELECT author, CASE WHEN slugify(author) = 'ann' THEN product(id) END FROM posts GROUP BY author ORDER BY author": "[[ann 2] [bob <nil>]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT slugify(id) FROM posts")
+	mustFail(t, db, "SELECT slugify(title, title) FROM posts")
+
+	mustExec(t, db, "UPDATE posts SET title = slugify(title) WHERE author = 'bob'")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT title FROM posts WHERE author = 'bob' ORDER BY id")); got != "[[other] [more]]" {
+		t.Fatalf("после UPDATE: %s", got)
+	}
+}
@@ -0,0 +1,316 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// View — сохранённый запрос. Обычное представление выполняется заново при каждом
+// обращении; материализованное хранит результат в одноимённой таблице, которая
+// обновляется командой REFRESH MATERIALIZED VIEW.
+type View struct {
+	Name         string
+	Query        string
+	Columns      []string
+	Materialized bool
+}
+
+// maxViewDepth ограничивает глубину обхода зависимостей представлений.
+const maxViewDepth = 32
+
+func (db *Database) CreateView(viewName string, columnNames []string, query string, materialized, withData bool) error {
+	viewName = strings.ToLower(viewName)
+	tokens := tokenize(query)
+	if len(tokens) == 0 || !isQueryStart(tokens[0]) {
+		return errors.New("определение представления должно быть запросом SELECT")
+	}
+
+	db.mu.RLock()
+	err := db.checkNameFree(viewName)
+	if err == nil && db.viewReferences(tokens, viewName, 0) {
+		err = fmt.Errorf("определение представления '%s' содержит циклическую ссылку", viewName)
+	}
+	db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	result, err := executeSelect(db, tokens)
+	if err != nil {
+		return err
+	}
+	columns, err := resultTableColumns(columnNames, result)
+	if err != nil {
+		return err
+	}
+	view := &View{Name: viewName, Query: strings.Join(tokens, " "), Columns: columnNames, Materialized: materialized}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.atomic(func() error {
+		if err := db.checkNameFree(viewName); err != nil {
+			return err
+		}
+		if materialized {
+			if err := db.createTable(viewName, columns, nil, nil); err != nil {
+				return err
+			}
+			if withData {
+				if err := db.insertResultRows(db.Tables[viewName], result.Rows); err != nil {
+					return err
+				}
+			}
+		}
+		db.logViewChange(viewName)
+		db.Views[viewName] = view
+		return db.saveViewToDisk(viewName)
+	})
+}
+
+func (db *Database) DropView(viewName string, materialized bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	viewName = strings.ToLower(viewName)
+	view, exists := db.Views[viewName]
+	if !exists || view.Materialized != materialized {
+		if materialized {
+			return fmt.Errorf("материализованное представление '%s' не существует", viewName)
+		}
+		return fmt.Errorf("представление '%s' не существует", viewName)
+	}
+	return db.atomic(func() error {
+		err := db.dropDependentViews(viewName, "", false,
+			"невозможно удалить представление '%s': от него зависит представление '%s'")
+		if err != nil {
+			return err
+		}
+		return db.dropView(viewName)
+	})
+}
+
+// dropView удаляет представление, а у материализованного — и его таблицу.
+func (db *Database) dropView(viewName string) error {
+	view, exists := db.Views[viewName]
+	if !exists {
+		return nil
+	}
+	if view.Materialized {
+		db.logSchemaChange(viewName)
+		delete(db.Tables, viewName)
+		if err := db.removeTableFromDisk(viewName); err != nil {
+			return err
+		}
+	}
+	db.logViewChange(viewName)
+	delete(db.Views, viewName)
+	return db.removeViewFromDisk(viewName)
+}
+
+// dependentViews возвращает представления, запрос которых ссылается на отношение
+// relation, а если column не пуст — и на его столбец. Представления хранятся
+// текстом запроса, поэтому зависимость от столбца определяется по упоминанию его
+// имени или выборке *.
+func (db *Database) dependentViews(relation, column string) []string {
+	var names []string
+	for name, view := range db.Views {
+		tokens := tokenize(view.Query)
+		if name == relation || !referencesRelation(tokens, relation) {
+			continue
+		}
+		if column != "" && !mentionsColumn(tokens, column) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dropDependentViews при cascade удаляет представления, зависящие от отношения
+// (и от них — рекурсивно), иначе возвращает ошибку по шаблону message
+// с именами объекта и представления.
+func (db *Database) dropDependentViews(relation, column string, cascade bool, message string) error {
+	for _, name := range db.dependentViews(relation, column) {
+		if !cascade {
+			object := relation
+			if column != "" {
+				object = column
+			}
+			return fmt.Errorf(message, object, name)
+		}
+		if err := db.dropDependentViews(name, "", true, message); err != nil {
+			return err
+		}
+		if err := db.dropView(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mentionsColumn сообщает, упоминается ли в запросе столбец (в том числе
+// с префиксом таблицы) или выборка всех столбцов: SELECT *, t.*.
+func mentionsColumn(tokens []string, column string) bool {
+	column = strings.ToLower(column)
+	for i, tok := range tokens {
+		lower := strings.ToLower(tok)
+		if lower == column || strings.HasSuffix(lower, "."+column) {
+			return true
+		}
+		if tok == "*" && i > 0 {
+			switch prev := strings.ToUpper(tokens[i-1]); {
+			case prev == "SELECT", prev == "DISTINCT", prev == ",", strings.HasSuffix(prev, "."):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RefreshMaterializedView заново выполняет запрос представления и заменяет
+// содержимое его таблицы.
+func (db *Database) RefreshMaterializedView(viewName string) error {
+	_, err := db.refreshMaterializedView(viewName)
+	return err
+}
+
+// refreshMaterializedView обновляет представление и возвращает число его строк.
+func (db *Database) refreshMaterializedView(viewName string) (int, error) {
+	viewName = strings.ToLower(viewName)
+	db.mu.RLock()
+	view, exists := db.Views[viewName]
+	db.mu.RUnlock()
+	if !exists || !view.Materialized {
+		return 0, fmt.Errorf("материализованное представление '%s' не существует", viewName)
+	}
+
+	result, err := executeSelect(db, tokenize(view.Query))
+	if err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	err = db.atomic(func() error {
+		err := db.alterTable(viewName, func(table *Table) error {
+			if len(result.Columns) != len(table.Columns) {
+				return fmt.Errorf("запрос представления '%s' возвращает %d столбцов вместо %d", viewName, len(result.Columns), len(table.Columns))
+			}
+			table.Rows = [][]interface{}{}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return db.insertResultRows(db.Tables[viewName], result.Rows)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(result.Rows), nil
+}
+
+// resolveRelation возвращает таблицу по имени из FROM или JOIN. Обычное представление
+// раскрывается во временную таблицу с результатом его запроса.
+func (db *Database) resolveRelation(name string) (*Table, error) {
+	name = strings.ToLower(name)
+	if isInformationSchema(name) {
+		return db.informationSchemaTable(name)
+	}
+	db.mu.RLock()
+	table, isTable := db.Tables[name]
+	view, isView := db.Views[name]
+	db.mu.RUnlock()
+	if isTable {
+		return table, nil
+	}
+	if !isView {
+		return nil, fmt.Errorf("таблица '%s' не существует", name)
+	}
+	result, err := executeSelect(db, tokenize(view.Query))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения представления '%s': %v", name, err)
+	}
+	columns, err := resultTableColumns(view.Columns, result)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка выполнения представления '%s': %v", name, err)
+	}
+	return &Table{Name: view.Name, Columns: columns, Rows: result.Rows}, nil
+}
+
+// viewReferences сообщает, ссылается ли запрос (напрямую или через другие
+// представления) на отношение name.
+func (db *Database) viewReferences(tokens []string, name string, depth int) bool {
+	if depth >= maxViewDepth {
+		return true
+	}
+	for _, relation := range relationNames(tokens) {
+		relation = strings.ToLower(relation)
+		if relation == name {
+			return true
+		}
+		if view, exists := db.Views[relation]; exists && db.viewReferences(tokenize(view.Query), name, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// relationNames возвращает имена отношений, указанных после FROM и JOIN.
+func relationNames(tokens []string) []string {
+	var names []string
+	for i := 0; i+1 < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i]) {
+		case "FROM", "JOIN":
+			names = append(names, tokens[i+1])
+		}
+	}
+	return names
+}
+
+func (db *Database) checkNameFree(name string) error {
+	if isInformationSchema(name) {
+		return fmt.Errorf("имя '%s' зарезервировано для information_schema", name)
+	}
+	if _, exists := db.Tables[name]; exists {
+		if view, isView := db.Views[name]; isView && view.Materialized {
+			return fmt.Errorf("материализованное представление '%s' уже существует", name)
+		}
+		return fmt.Errorf("таблица '%s' уже существует", name)
+	}
+	if _, exists := db.Views[name]; exists {
+		return fmt.Errorf("представление '%s' уже существует", name)
+	}
+	return nil
+}
+
+// writableTable возвращает таблицу для INSERT, UPDATE и DELETE; таблицы
+// материализованных представлений изменяются только через REFRESH.
+func (db *Database) writableTable(tableName string) (*Table, error) {
+	table, exists := db.Tables[tableName]
+	if !exists {
+		if _, isView := db.Views[tableName]; isView || isInformationSchema(tableName) {
+			return nil, fmt.Errorf("представление '%s' недоступно для изменения", tableName)
+		}
+		return nil, fmt.Errorf("таблица '%s' не существует", tableName)
+	}
+	if view, isView := db.Views[tableName]; isView && view.Materialized {
+		return nil, fmt.Errorf("материализованное представление '%s' недоступно для изменения", tableName)
+	}
+	return table, nil
+}
+
+// logViewChange запоминает определение представления перед изменением для отката.
+func (db *Database) logViewChange(viewName string) {
+	if db.transaction == nil {
+		return
+	}
+	op := Operation{Type: "VIEW", TableName: viewName}
+	if view, exists := db.Views[viewName]; exists {
+		op.Data = view
+	}
+	db.transaction.operations = append(db.transaction.operations, op)
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestViewBlocksDropAndRename(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING, age INTEGER)",
+		"INSERT INTO users VALUES (1, 'Alice', 30)",
+		"CREATE VIEW adults AS SELECT id, name FROM users WHERE age >= 18",
+	)
+	mustFail(t, db, "DROP TABLE users")
+	mustFail(t, db, "ALTER TABLE users RENAME TO people")
+	mustFail(t, db, "ALTER TABLE users RENAME COLUMN name TO full_name")
+	mustFail(t, db, "ALTER TABLE users DROP COLUMN age")
+
+	// Столбец, на который представление не ссылается, можно менять.
+	mustExec(t, db, "ALTER TABLE users ADD COLUMN email STRING", "ALTER TABLE users RENAME COLUMN email TO mail")
+	if rows := mustQuery(t, db, "SELECT name FROM adults"); len(rows) != 1 {
+		t.Fatalf("представление вернуло %d строк вместо 1", len(rows))
+	}
+}
+
+func TestDropTableCascadeDropsViews(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING)",
+		"CREATE VIEW names AS SELECT name FROM users",
+		"CREATE VIEW short_names AS SELECT name FROM names WHERE name = 'Al'",
+	)
+	mustFail(t, db, "DROP VIEW names")
+	mustExec(t, db, "DROP TABLE users CASCADE")
+	if len(db.Views) != 0 {
+		t.Fatalf("после DROP TABLE ... CASCADE остались представления: %v", db.Views)
+	}
+	mustFail(t, db, "SELECT * FROM names")
+}
+
+func TestViewsAndMaterializedViews(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE users (id INTEGER, name STRING, age INTEGER)",
+		"INSERT INTO users VALUES (1, 'Alice', 30)",
+		"INSERT INTO users VALUES (2, 'Bob', 12)",
+		"CREATE VIEW adults (uid, uname) AS SELECT id, name FROM users WHERE age >= 18",
+		"CREATE VIEW IF NOT EXISTS adults AS SELECT id FROM users",
+		"CREATE MATERIALIZED VIEW ages AS SELECT name, age FROM users",
+		"CREATE INDEX ages_name ON ages (name)",
+		"UPDATE users SET age = 20 WHERE id = 2",
+	)
+	mustFail(t, db, "INSERT INTO adults VALUES (3, 'Carol')")
+	mustFail(t, db, "DELETE FROM adults")
+
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT uname FROM adults ORDER BY uid")); got != "[[Alice] [Bob]]" {
+		t.Fatalf("представление adults: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT age FROM ages WHERE name = 'Bob'")); got != "[[12]]" {
+		t.Fatalf("до REFRESH: %s", got)
+	}
+	mustExec(t, db, "REFRESH MATERIALIZED VIEW ages")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT age FROM ages WHERE name = 'Bob'")); got != "[[20]]" {
+		t.Fatalf("после REFRESH: %s", got)
+	}
+
+	mustExec(t, db,
+		"BEGIN",
+		"DROP VIEW adults",
+		"CREATE VIEW kids AS SELECT name FROM users WHERE age < 18",
+		"ROLLBACK",
+	)
+	mustFail(t, db, "SELECT * FROM kids")
+
+	reopened := NewDatabase()
+	if got := fmt.Sprint(mustQuery(t, reopened, "SELECT adults.uname FROM adults JOIN ages ON adults.uname = ages.name WHERE ages.age = 30")); got != "[[Alice]]" {
+		t.Fatalf("после загрузки: %s", got)
+	}
+	mustExec(t, reopened, "DROP MATERIALIZED VIEW ages", "DROP VIEW IF EXISTS adults", "DROP VIEW IF EXISTS adults")
+	mustFail(t, reopened, "SELECT * FROM ages")
+}
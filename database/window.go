@@ -0,0 +1,686 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// windowSpec — окно OVER (...): разбиение на разделы, порядок строк в разделе
+// и рамка. Вычисляется либо функция окна (function), либо агрегатная функция
+// (aggregate). column — имя служебного столбца, в который записаны значения.
+type windowSpec struct {
+	partitionBy []*Expr
+	orderBy     []OrderBy
+	frame       windowFrame
+	function    *windowFunction
+	aggregate   *aggregateFunction
+	column      string
+}
+
+type frameBoundKind int
+
+const (
+	unboundedPreceding frameBoundKind = iota
+	offsetPreceding
+	currentRow
+	offsetFollowing
+	unboundedFollowing
+)
+
+// frameBound — граница рамки; offset задан для N PRECEDING и N FOLLOWING.
+type frameBound struct {
+	kind   frameBoundKind
+	offset interface{}
+}
+
+// windowFrame — рамка окна: ROWS отсчитывает строки, RANGE — значения ключа
+// ORDER BY. Без явной рамки действует RANGE BETWEEN UNBOUNDED PRECEDING AND
+// CURRENT ROW: с ORDER BY это строки до текущей вместе с равными ей, без ORDER BY —
+// весь раздел.
+type windowFrame struct {
+	explicit   bool
+	rows       bool
+	start, end frameBound
+}
+
+// windowFunction — функция, которая вычисляется только как оконная.
+type windowFunction struct {
+	minArgs int
+	maxArgs int
+	compute func(p *windowPartition, pos int, expr *Expr) (interface{}, error)
+}
+
+var windowFunctions = map[string]*windowFunction{
+	"row_number": {
+		compute: func(p *windowPartition, pos int, expr *Expr) (interface{}, error) { return pos + 1, nil },
+	},
+	"rank": {
+		compute: func(p *windowPartition, pos int, expr *Expr) (interface{}, error) { return p.peerStart[pos] + 1, nil },
+	},
+	"dense_rank": {
+		compute: func(p *windowPartition, pos int, expr *Expr) (interface{}, error) { return p.denseRank[pos], nil },
+	},
+	"lag": {
+		minArgs: 1,
+		maxArgs: 3,
+		compute: func(p *windowPartition, pos int, expr *Expr) (interface{}, error) { return p.shifted(pos, expr, -1) },
+	},
+	"lead": {
+		minArgs: 1,
+		maxArgs: 3,
+		compute: func(p *windowPartition, pos int, expr *Expr) (interface{}, error) { return p.shifted(pos, expr, 1) },
+	},
+	"first_value": {
+		minArgs: 1,
+		maxArgs: 1,
+		compute: windowFirstValue,
+	},
+	"last_value": {
+		minArgs: 1,
+		maxArgs: 1,
+		compute: windowLastValue,
+	},
+}
+
+// parseOver разбирает OVER ([PARTITION BY ...] [ORDER BY ...] [рамка]) после
+// вызова функции call, начиная с токена после OVER.
+func parseOver(db *Database, tokens []string, call *Expr, start, end int) (*Expr, int, error) {
+	if start >= end || tokens[start] != "(" {
+		return nil, start, fmt.Errorf("неверный синтаксис OVER: ожидалась открывающая скобка после '%s(...) OVER'", call.Name)
+	}
+	closing := matchingParen(tokens, start, end)
+	if closing == -1 {
+		return nil, start, errors.New("неверный синтаксис OVER: отсутствует закрывающая скобка")
+	}
+	window := tokens[:closing]
+	spec := &windowSpec{frame: windowFrame{start: frameBound{kind: unboundedPreceding}, end: frameBound{kind: currentRow}}}
+	i := start + 1
+	if i < closing && strings.ToUpper(tokens[i]) == "PARTITION" {
+		if i+1 >= closing || strings.ToUpper(tokens[i+1]) != "BY" {
+			return nil, i, errors.New("неверный синтаксис OVER: ожидалось PARTITION BY")
+		}
+		partitionEnd := findClause(window, i+2, "ORDER", "ROWS", "RANGE")
+		partitionBy, err := parseExpressionList(db, tokens, i+2, partitionEnd, "PARTITION BY")
+		if err != nil {
+			return nil, i, err
+		}
+		spec.partitionBy, i = partitionBy, partitionEnd
+	}
+	if i < closing && strings.ToUpper(tokens[i]) == "ORDER" {
+		if i+1 >= closing || strings.ToUpper(tokens[i+1]) != "BY" {
+			return nil, i, errors.New("неверный синтаксис OVER: ожидалось ORDER BY")
+		}
+		orderEnd := findClause(window, i+2, "ROWS", "RANGE")
+		orderBy, err := parseOrderBy(db, tokens, i+2, orderEnd)
+		if err != nil {
+			return nil, i, err
+		}
+		spec.orderBy, i = orderBy, orderEnd
+	}
+	if i < closing {
+		if upper := strings.ToUpper(tokens[i]); upper != "ROWS" && upper != "RANGE" {
+			return nil, i, fmt.Errorf("неверный синтаксис OVER: неожиданный токен '%s'", tokens[i])
+		}
+		frame, err := parseFrame(db, tokens, i, closing)
+		if err != nil {
+			return nil, i, err
+		}
+		if !frame.rows && (frame.start.offset != nil || frame.end.offset != nil) && len(spec.orderBy) != 1 {
+			return nil, i, errors.New("рамка RANGE со смещением требует ровно одного выражения в ORDER BY окна")
+		}
+		spec.frame = frame
+	}
+
+	expr := &Expr{Type: WindowExpr, Name: call.Name, Args: call.Args, Value: spec}
+	if err := db.bindWindow(expr); err != nil {
+		return nil, closing + 1, err
+	}
+	return expr, closing + 1, nil
+}
+
+// matchingParen возвращает позицию скобки, закрывающей скобку на позиции start, или -1.
+func matchingParen(tokens []string, start, end int) int {
+	depth := 0
+	for i := start; i < end; i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseFrame разбирает рамку ROWS | RANGE граница или
+// ROWS | RANGE BETWEEN граница AND граница, занимающую токены start..end.
+func parseFrame(db *Database, tokens []string, start, end int) (windowFrame, error) {
+	frame := windowFrame{explicit: true, rows: strings.ToUpper(tokens[start]) == "ROWS"}
+	i := start + 1
+	var err error
+	if i < end && strings.ToUpper(tokens[i]) == "BETWEEN" {
+		andIndex := findClause(tokens[:end], i+1, "AND")
+		if andIndex == end {
+			return frame, errors.New("неверный синтаксис рамки окна: ожидалось BETWEEN ... AND ...")
+		}
+		if frame.start, err = parseFrameBound(db, tokens, i+1, andIndex, frame.rows); err != nil {
+			return frame, err
+		}
+		if frame.end, err = parseFrameBound(db, tokens, andIndex+1, end, frame.rows); err != nil {
+			return frame, err
+		}
+	} else {
+		if frame.start, err = parseFrameBound(db, tokens, i, end, frame.rows); err != nil {
+			return frame, err
+		}
+		frame.end = frameBound{kind: currentRow}
+	}
+	switch {
+	case frame.start.kind == unboundedFollowing:
+		return frame, errors.New("рамка окна не может начинаться с UNBOUNDED FOLLOWING")
+	case frame.end.kind == unboundedPreceding:
+		return frame, errors.New("рамка окна не может заканчиваться на UNBOUNDED PRECEDING")
+	case frame.start.kind > frame.end.kind:
+		return frame, errors.New("начало рамки окна не может быть позже её конца")
+	}
+	return frame, nil
+}
+
+// parseFrameBound разбирает границу рамки: UNBOUNDED PRECEDING | UNBOUNDED FOLLOWING |
+// CURRENT ROW | смещение PRECEDING | смещение FOLLOWING. Смещение — константа:
+// целое число строк для ROWS, число или интервал для RANGE.
+func parseFrameBound(db *Database, tokens []string, start, end int, rows bool) (frameBound, error) {
+	text := strings.ToUpper(strings.Join(tokens[start:end], " "))
+	switch text {
+	case "UNBOUNDED PRECEDING":
+		return frameBound{kind: unboundedPreceding}, nil
+	case "UNBOUNDED FOLLOWING":
+		return frameBound{kind: unboundedFollowing}, nil
+	case "CURRENT ROW":
+		return frameBound{kind: currentRow}, nil
+	}
+	if end-start < 2 {
+		return frameBound{}, fmt.Errorf("неверная граница рамки окна '%s'", strings.Join(tokens[start:end], " "))
+	}
+	bound := frameBound{kind: offsetPreceding}
+	switch strings.ToUpper(tokens[end-1]) {
+	case "PRECEDING":
+	case "FOLLOWING":
+		bound.kind = offsetFollowing
+	default:
+		return frameBound{}, fmt.Errorf("неверная граница рамки окна '%s'", strings.Join(tokens[start:end], " "))
+	}
+	expr, next, err := parseExpression(db, tokens, start, end-1)
+	if err == nil && next != end-1 {
+		err = fmt.Errorf("неожиданный токен '%s'", tokens[next])
+	}
+	if err != nil {
+		return frameBound{}, fmt.Errorf("неверная граница рамки окна: %v", err)
+	}
+	if !isConstantExpression(expr) {
+		return frameBound{}, errors.New("смещение рамки окна должно быть константой")
+	}
+	offset, err := evaluateExpression(nil, nil, expr)
+	if err != nil {
+		return frameBound{}, err
+	}
+	valid := false
+	switch v := offset.(type) {
+	case int:
+		valid = v >= 0
+	case float64, Decimal:
+		valid = !rows && compareValues(v, 0) >= 0
+	case Interval:
+		valid = !rows
+	}
+	if !valid {
+		if rows {
+			return frameBound{}, fmt.Errorf("смещение рамки ROWS должно быть неотрицательным целым числом, получено '%s'", exprString(expr))
+		}
+		return frameBound{}, fmt.Errorf("смещение рамки RANGE должно быть неотрицательным числом или интервалом, получено '%s'", exprString(expr))
+	}
+	bound.offset = offset
+	return bound, nil
+}
+
+// bindWindow находит функцию окна: собственно оконную функцию или агрегатную,
+// встроенную либо зарегистрированную в базе.
+func (db *Database) bindWindow(expr *Expr) error {
+	spec := expr.Value.(*windowSpec)
+	for _, arg := range windowOperands(expr) {
+		if containsWindow(arg) {
+			return errors.New("оконные функции не могут быть вложенными")
+		}
+	}
+	if fn, exists := windowFunctions[expr.Name]; exists {
+		if len(expr.Args) < fn.minArgs || len(expr.Args) > fn.maxArgs {
+			if fn.minArgs == fn.maxArgs {
+				return fmt.Errorf("функция '%s' ожидает %d аргумент(ов), получено %d", expr.Name, fn.minArgs, len(expr.Args))
+			}
+			return fmt.Errorf("функция '%s' ожидает от %d до %d аргументов, получено %d", expr.Name, fn.minArgs, fn.maxArgs, len(expr.Args))
+		}
+		spec.function = fn
+		return nil
+	}
+	call := &Expr{Type: FunctionExpr, Name: expr.Name, Args: expr.Args}
+	if err := db.bindFunction(call); err != nil {
+		return err
+	}
+	agg, ok := call.Value.(*aggregateFunction)
+	if !ok {
+		return fmt.Errorf("функция '%s' не является оконной или агрегатной и не может использоваться с OVER", expr.Name)
+	}
+	spec.aggregate = agg
+	return nil
+}
+
+// windowOperands возвращает все выражения окна: аргументы функции, PARTITION BY и ORDER BY.
+func windowOperands(expr *Expr) []*Expr {
+	spec := expr.Value.(*windowSpec)
+	operands := append([]*Expr(nil), expr.Args...)
+	operands = append(operands, spec.partitionBy...)
+	for _, item := range spec.orderBy {
+		operands = append(operands, item.Expr)
+	}
+	return operands
+}
+
+func containsWindow(expr *Expr) bool {
+	return len(collectWindows(expr, nil)) > 0
+}
+
+// collectWindows собирает оконные функции выражения, в том числе в условиях CASE
+// и логических выражениях.
+func collectWindows(expr *Expr, windows []*Expr) []*Expr {
+	if expr.Type == WindowExpr {
+		return append(windows, expr)
+	}
+	if expr.Type == CaseExpr {
+		for _, cond := range expr.Value.([]*Condition) {
+			windows = conditionWindows(cond, windows)
+		}
+	}
+	if expr.Type == ConditionExpr {
+		windows = conditionWindows(expr.Value.(*Condition), windows)
+	}
+	for _, arg := range expr.Args {
+		windows = collectWindows(arg, windows)
+	}
+	return windows
+}
+
+func conditionWindows(cond *Condition, windows []*Expr) []*Expr {
+	if cond == nil {
+		return windows
+	}
+	if cond.Type == Compound {
+		return conditionWindows(cond.Right, conditionWindows(cond.Left, windows))
+	}
+	if cond.ValueExpr != nil {
+		windows = collectWindows(cond.ValueExpr, windows)
+	}
+	if cond.Expr != nil {
+		return collectWindows(cond.Expr, windows)
+	}
+	return windows
+}
+
+// computeWindows вычисляет оконные функции по строкам запроса (после WHERE и JOIN,
+// до ORDER BY и LIMIT) и дописывает их значения к строкам служебными столбцами.
+// Исходные строки не изменяются.
+func computeWindows(rows [][]interface{}, columnNames []string, columnTypes []DataType, windows []*Expr) ([][]interface{}, []string, []DataType, error) {
+	extended := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		extended[i] = make([]interface{}, len(columnNames), len(columnNames)+len(windows))
+		copy(extended[i], row)
+	}
+	names := append([]string(nil), columnNames...)
+	types := append([]DataType(nil), columnTypes...)
+	for n, expr := range windows {
+		// Столбцы проверяются заранее: на пустой таблице выражения окна не вычисляются.
+		for i, operand := range windowOperands(expr) {
+			if i == 0 && expr.Name == "count" && operand.Type == ColumnExpr && operand.Name == "*" {
+				continue
+			}
+			for _, name := range expressionColumns(operand, nil) {
+				if findColumnIndex(columnNames, name) == -1 {
+					return nil, nil, nil, fmt.Errorf("столбец '%s' не найден в результате", name)
+				}
+			}
+		}
+		values, err := computeWindow(rows, columnNames, expr)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for i := range extended {
+			extended[i] = append(extended[i], values[i])
+		}
+		dataType, ok := expressionType(expr, columnNames, columnTypes)
+		if !ok {
+			dataType = inferColumnType(extended, len(names))
+		}
+		spec := expr.Value.(*windowSpec)
+		spec.column = fmt.Sprintf("#window%d", n+1)
+		names = append(names, spec.column)
+		types = append(types, dataType)
+	}
+	return extended, names, types, nil
+}
+
+// windowPartition — раздел окна: строки в порядке ORDER BY окна. Строки с равными
+// ключами ORDER BY (равные по порядку) занимают позиции peerStart..peerEnd-1.
+type windowPartition struct {
+	spec        *windowSpec
+	rows        [][]interface{}
+	columnNames []string
+	keys        [][]interface{}
+	peerStart   []int
+	peerEnd     []int
+	denseRank   []int
+}
+
+// computeWindow возвращает значения оконной функции expr для каждой строки rows.
+func computeWindow(rows [][]interface{}, columnNames []string, expr *Expr) ([]interface{}, error) {
+	spec := expr.Value.(*windowSpec)
+	var partitions [][]int
+	positions := make(map[string]int)
+	for i, row := range rows {
+		key := make([]interface{}, len(spec.partitionBy))
+		for j, partExpr := range spec.partitionBy {
+			value, err := evaluateExpression(row, columnNames, partExpr)
+			if err != nil {
+				return nil, err
+			}
+			key[j] = value
+		}
+		encoded := encodeKey(key)
+		pos, exists := positions[encoded]
+		if !exists {
+			pos = len(partitions)
+			positions[encoded] = pos
+			partitions = append(partitions, nil)
+		}
+		partitions[pos] = append(partitions[pos], i)
+	}
+
+	values := make([]interface{}, len(rows))
+	for _, indexes := range partitions {
+		p, err := newWindowPartition(spec, rows, columnNames, indexes)
+		if err != nil {
+			return nil, err
+		}
+		// Соседние строки часто имеют одну и ту же рамку (равные по порядку строки,
+		// окно без ORDER BY), тогда агрегат не пересчитывается.
+		lastLo, lastHi := -1, -1
+		var lastValue interface{}
+		for pos, index := range indexes {
+			if spec.function != nil {
+				if values[index], err = spec.function.compute(p, pos, expr); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			lo, hi, err := p.frame(pos)
+			if err != nil {
+				return nil, err
+			}
+			if lo != lastLo || hi != lastHi {
+				call := &Expr{Type: FunctionExpr, Name: expr.Name, Args: expr.Args, Value: spec.aggregate}
+				if lastValue, err = computeAggregate(call, spec.aggregate, p.rows[lo:hi], columnNames); err != nil {
+					return nil, err
+				}
+				lastLo, lastHi = lo, hi
+			}
+			values[index] = lastValue
+		}
+	}
+	return values, nil
+}
+
+// newWindowPartition упорядочивает строки раздела (indexes — номера строк в rows)
+// по ORDER BY окна и находит группы равных по порядку строк.
+func newWindowPartition(spec *windowSpec, rows [][]interface{}, columnNames []string, indexes []int) (*windowPartition, error) {
+	keys := make([][]interface{}, len(indexes))
+	for i, index := range indexes {
+		keys[i] = make([]interface{}, len(spec.orderBy))
+		for j, item := range spec.orderBy {
+			value, err := evaluateExpression(rows[index], columnNames, item.Expr)
+			if err != nil {
+				return nil, err
+			}
+			keys[i][j] = value
+		}
+	}
+	order := make([]int, len(indexes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return compareOrderKeys(keys[order[a]], keys[order[b]], spec.orderBy) < 0
+	})
+	// Значения пишутся по исходным номерам строк, поэтому indexes переставляется вместе со строками.
+	sortedIndexes := make([]int, len(indexes))
+	p := &windowPartition{spec: spec, columnNames: columnNames}
+	for i, k := range order {
+		sortedIndexes[i] = indexes[k]
+		p.rows = append(p.rows, rows[indexes[k]])
+		p.keys = append(p.keys, keys[k])
+	}
+	copy(indexes, sortedIndexes)
+
+	n := len(p.rows)
+	p.peerStart, p.peerEnd, p.denseRank = make([]int, n), make([]int, n), make([]int, n)
+	for i := 0; i < n; i++ {
+		if i > 0 && compareOrderKeys(p.keys[i-1], p.keys[i], spec.orderBy) == 0 {
+			p.peerStart[i], p.denseRank[i] = p.peerStart[i-1], p.denseRank[i-1]
+		} else {
+			p.peerStart[i] = i
+			if i > 0 {
+				p.denseRank[i] = p.denseRank[i-1] + 1
+			} else {
+				p.denseRank[i] = 1
+			}
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		if i < n-1 && p.peerStart[i+1] == p.peerStart[i] {
+			p.peerEnd[i] = p.peerEnd[i+1]
+		} else {
+			p.peerEnd[i] = i + 1
+		}
+	}
+	return p, nil
+}
+
+// frame возвращает рамку строки pos — позиции раздела lo..hi-1.
+func (p *windowPartition) frame(pos int) (int, int, error) {
+	lo, err := p.boundIndex(p.spec.frame.start, pos, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := p.boundIndex(p.spec.frame.end, pos, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	return lo, max(lo, hi), nil
+}
+
+// boundIndex переводит границу рамки в позицию раздела: для начала рамки — первую
+// строку рамки, для конца — позицию за последней.
+func (p *windowPartition) boundIndex(b frameBound, pos int, start bool) (int, error) {
+	n := len(p.rows)
+	switch b.kind {
+	case unboundedPreceding:
+		return 0, nil
+	case unboundedFollowing:
+		return n, nil
+	case currentRow:
+		switch {
+		case p.spec.frame.rows && start:
+			return pos, nil
+		case p.spec.frame.rows:
+			return pos + 1, nil
+		case start:
+			return p.peerStart[pos], nil
+		}
+		return p.peerEnd[pos], nil
+	}
+	if !p.spec.frame.rows {
+		return p.rangeIndex(b, pos, start)
+	}
+	index := pos + b.offset.(int)
+	if b.kind == offsetPreceding {
+		index = pos - b.offset.(int)
+	}
+	if !start {
+		index++
+	}
+	return max(0, min(index, n)), nil
+}
+
+// rangeIndex находит границу рамки RANGE со смещением: первую строку, ключ которой
+// не раньше значения «ключ текущей строки ± смещение» (для начала рамки), или
+// последнюю строку, ключ которой не позже его (для конца). Строки с ключом NULL
+// входят только в рамки друг друга.
+func (p *windowPartition) rangeIndex(b frameBound, pos int, start bool) (int, error) {
+	key := p.keys[pos][0]
+	if key == nil {
+		if start {
+			return p.peerStart[pos], nil
+		}
+		return p.peerEnd[pos], nil
+	}
+	direction := 1
+	if p.spec.orderBy[0].Desc {
+		direction = -1
+	}
+	op := "+"
+	if (b.kind == offsetPreceding) == (direction == 1) {
+		op = "-"
+	}
+	target, err := applyArithmetic(op, key, b.offset)
+	if err != nil {
+		return 0, fmt.Errorf("смещение рамки RANGE: %v", err)
+	}
+	if start {
+		for i, k := range p.keys {
+			if k[0] != nil && compareValues(k[0], target)*direction >= 0 {
+				return i, nil
+			}
+		}
+		return len(p.keys), nil
+	}
+	for i := len(p.keys) - 1; i >= 0; i-- {
+		if k := p.keys[i][0]; k != nil && compareValues(k, target)*direction <= 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// shifted вычисляет lag и lead: значение выражения в строке на offset позиций
+// раньше (direction = -1) или позже текущей; за пределами раздела — значение
+// по умолчанию (третий аргумент) или NULL.
+func (p *windowPartition) shifted(pos int, expr *Expr, direction int) (interface{}, error) {
+	row := p.rows[pos]
+	offset := 1
+	if len(expr.Args) > 1 {
+		value, err := evaluateExpression(row, p.columnNames, expr.Args[1])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, nil
+		}
+		n, ok := value.(int)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("смещение функции '%s' должно быть неотрицательным целым числом, получено %v", expr.Name, value)
+		}
+		offset = n
+	}
+	target := pos + direction*offset
+	if target < 0 || target >= len(p.rows) {
+		if len(expr.Args) > 2 {
+			return evaluateExpression(row, p.columnNames, expr.Args[2])
+		}
+		return nil, nil
+	}
+	return evaluateExpression(p.rows[target], p.columnNames, expr.Args[0])
+}
+
+func windowFirstValue(p *windowPartition, pos int, expr *Expr) (interface{}, error) {
+	lo, hi, err := p.frame(pos)
+	if err != nil || lo == hi {
+		return nil, err
+	}
+	return evaluateExpression(p.rows[lo], p.columnNames, expr.Args[0])
+}
+
+func windowLastValue(p *windowPartition, pos int, expr *Expr) (interface{}, error) {
+	lo, hi, err := p.frame(pos)
+	if err != nil || lo == hi {
+		return nil, err
+	}
+	return evaluateExpression(p.rows[hi-1], p.columnNames, expr.Args[0])
+}
+
+// windowType определяет тип значений оконной функции.
+func windowType(expr *Expr, columnNames []string, columnTypes []DataType) (DataType, bool) {
+	spec := expr.Value.(*windowSpec)
+	switch {
+	case spec.aggregate != nil:
+		return expressionType(&Expr{Type: FunctionExpr, Name: expr.Name, Args: expr.Args, Value: spec.aggregate}, columnNames, columnTypes)
+	case len(expr.Args) == 0:
+		return INTEGER, true
+	}
+	return expressionType(expr.Args[0], columnNames, columnTypes)
+}
+
+// windowString восстанавливает текст окна OVER (...).
+func windowString(spec *windowSpec) string {
+	var parts []string
+	if len(spec.partitionBy) > 0 {
+		exprs := make([]string, len(spec.partitionBy))
+		for i, expr := range spec.partitionBy {
+			exprs[i] = exprString(expr)
+		}
+		parts = append(parts, "PARTITION BY "+strings.Join(exprs, ", "))
+	}
+	if len(spec.orderBy) > 0 {
+		items := make([]string, len(spec.orderBy))
+		for i, item := range spec.orderBy {
+			items[i] = exprString(item.Expr)
+			if item.Desc {
+				items[i] += " DESC"
+			}
+		}
+		parts = append(parts, "ORDER BY "+strings.Join(items, ", "))
+	}
+	if spec.frame.explicit {
+		mode := "RANGE"
+		if spec.frame.rows {
+			mode = "ROWS"
+		}
+		parts = append(parts, mode+" BETWEEN "+frameBoundString(spec.frame.start)+" AND "+frameBoundString(spec.frame.end))
+	}
+	return "OVER (" + strings.Join(parts, " ") + ")"
+}
+
+func frameBoundString(b frameBound) string {
+	switch b.kind {
+	case unboundedPreceding:
+		return "UNBOUNDED PRECEDING"
+	case offsetPreceding:
+		return exprString(&Expr{Type: LiteralExpr, Value: b.offset}) + " PRECEDING"
+	case currentRow:
+		return "CURRENT ROW"
+	case offsetFollowing:
+		return exprString(&Expr{Type: LiteralExpr, Value: b.offset}) + " FOLLOWING"
+	}
+	return "UNBOUNDED FOLLOWING"
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWindowFunctionsOnEmptyTable(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExec(t, db, "CREATE TABLE o (id INTEGER, j JSON)")
+	for _, query := range []string{
+		"SELECT first_value(NULL) OVER () FROM o",
+		"SELECT lag(j ->> 'a') OVER () FROM o",
+		"SELECT lag(NULL) OVER (ORDER BY id) FROM o",
+		"SELECT id, count(*) OVER () FROM o",
+		"SELECT id, count(*) OVER (ORDER BY id) FROM o",
+	} {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != "[]" {
+			t.Fatalf("%s: %s", query, got)
+		}
+	}
+	mustFail(t, db, "SELECT sum(nosuch) OVER (ORDER BY id) FROM o")
+	mustFail(t, db, "SELECT row_number() OVER (ORDER BY nosuch) FROM o")
+	mustFail(t, db, "SELECT row_number() OVER (PARTITION BY nosuch) FROM o")
+
+	mustExec(t, db, "INSERT INTO o VALUES (1, '{\"a\": \"x\"}')", "INSERT INTO o VALUES (2, '{\"a\": \"y\"}')")
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT lag(j ->> 'a') OVER (ORDER BY id) FROM o ORDER BY id")); got != "[[<nil>] [x]]" {
+		t.Fatalf("lag: %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, count(*) OVER () FROM o ORDER BY id")); got != "[[1 2] [2 2]]" {
+		t.Fatalf("count(*) OVER (): %s", got)
+	}
+	if got := fmt.Sprint(mustQuery(t, db, "SELECT id, count(*) OVER (ORDER BY id) FROM o ORDER BY id")); got != "[[1 1] [2 2]]" {
+		t.Fatalf("count(*) OVER (ORDER BY id): %s", got)
+	}
+}
+
+func newEmployeesDatabase(t *testing.T) *Database {
+	t.Helper()
+	db := newTestDatabase(t)
+	mustExec(t, db,
+		"CREATE TABLE employees (id INTEGER, dept STRING, name STRING, salary INTEGER)",
+		"INSERT INTO employees VALUES (1, 'it', 'Ann', 300)",
+		"INSERT INTO employees VALUES (2, 'it', 'Bob', 200)",
+		"INSERT INTO employees VALUES (3, 'it', 'Cid', 300)",
+		"INSERT INTO employees VALUES (4, 'hr', 'Dan', 100)",
+		"INSERT INTO employees VALUES (5, 'hr', 'Eve', 150)",
+	)
+	return db
+}
+
+func TestWindowRankingAndOffsets(t *testing.T) {
+	db := newEmployeesDatabase(t)
+	cases := map[string]string{
+		"SELECT id, row_number() OVER (PARTITION BY dept ORDER BY salary DESC, id), rank() OVER (PARTITION BY dept ORDER BY salary DESC), dense_rank() OVER (PARTITION BY dept ORDER BY salary DESC) FROM employees ORDER BY id": "[[1 1 1 1] [2 3 3 2] [3 2 1 1] [4 2 2 2] [5 1 1 1]]",
+		"SELECT id, lag(salary) OVER (ORDER BY id), lead(salary, 2, 0) OVER (ORDER BY id) FROM employees ORDER BY id":                                                                                                            "[[1 <nil> 300] [2 300 100] [3 200 150] [4 300 0] [5 100 0]]",
+		"SELECT id, salary - lag(salary) OVER (PARTITION BY dept ORDER BY id) FROM employees WHERE dept = 'hr' ORDER BY id":                                                                                                      "[[4 <nil>] [5 50]]",
+		"SELECT id, first_value(name) OVER (PARTITION BY dept ORDER BY id), last_value(name) OVER (PARTITION BY dept ORDER BY id ROWS BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) FROM employees ORDER BY id":                   "[[1 Ann Cid] [2 Ann Cid] [3 Ann Cid] [4 Dan Eve] [5 Dan Eve]]",
+		"SELECT name FROM employees ORDER BY rank() OVER (ORDER BY salary DESC), id LIMIT 3":                                                                                                                                     "[[Ann] [Cid] [Bob]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+	mustFail(t, db, "SELECT id FROM employees WHERE row_number() OVER (ORDER BY id) = 1")
+	mustFail(t, db, "SELECT dept, count(*), rank() OVER (ORDER BY dept) FROM employees GROUP BY dept")
+}
+
+func TestWindowAggregatesAndFrames(t *testing.T) {
+	db := newEmployeesDatabase(t)
+	cases := map[string]string{
+		"SELECT id, sum(salary) OVER (ORDER BY id) FROM employees ORDER BY id":                                                        "[[1 300] [2 500] [3 800] [4 900] [5 1050]]",
+		"SELECT id, sum(salary) OVER (ORDER BY salary) FROM employees ORDER BY id":                                                    "[[1 1050] [2 450] [3 1050] [4 100] [5 250]]",
+		"SELECT id, sum(salary) OVER (PARTITION BY dept), count(*) OVER (PARTITION BY dept) FROM employees ORDER BY id":               "[[1 800 3] [2 800 3] [3 800 3] [4 250 2] [5 250 2]]",
+		"SELECT id, avg(salary) OVER (ORDER BY id ROWS BETWEEN 1 PRECEDING AND 1 FOLLOWING) FROM employees ORDER BY id":               "[[1 250] [2 266.6666666666666667] [3 200] [4 183.3333333333333333] [5 125]]",
+		"SELECT id, max(salary) OVER (ORDER BY salary RANGE BETWEEN 50 PRECEDING AND CURRENT ROW) FROM employees ORDER BY id":         "[[1 300] [2 200] [3 300] [4 100] [5 150]]",
+		"SELECT id, count(*) OVER (ORDER BY salary RANGE BETWEEN 50 PRECEDING AND 50 FOLLOWING) FROM employees ORDER BY id":           "[[1 2] [2 2] [3 2] [4 2] [5 3]]",
+		"SELECT id, CASE WHEN salary > avg(salary) OVER (PARTITION BY dept) THEN 'above' ELSE 'below' END FROM employees ORDER BY id": "[[1 above] [2 below] [3 above] [4 below] [5 above]]",
+	}
+	for query, want := range cases {
+		if got := fmt.Sprint(mustQuery(t, db, query)); got != want {
+			t.Errorf("%s: %s, ожидалось %s", query, got, want)
+		}
+	}
+}